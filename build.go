@@ -0,0 +1,655 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"loov.dev/lensm/internal/disasm"
+)
+
+// BuildConfig describes how `lensm build` compiles the packages.
+type BuildConfig struct {
+	Packages []string
+	GCFlags  string
+	Tags     string
+	GOARCH   string
+	GOAMD64  string
+	// Test builds test binaries with `go test -c` instead of `go build`.
+	Test bool
+	// Run selects the tests and benchmarks to show, like `go test -run`.
+	Run string
+}
+
+// registerFlags adds the build flags to fs.
+func (config *BuildConfig) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&config.GCFlags, "gcflags", "", "arguments to pass on each go tool compile invocation")
+	fs.StringVar(&config.Tags, "tags", "", "comma-separated list of build tags")
+	fs.StringVar(&config.GOARCH, "goarch", "", "target architecture, defaults to GOARCH")
+	fs.StringVar(&config.GOAMD64, "goamd64", "", "amd64 microarchitecture level, defaults to GOAMD64")
+}
+
+// command returns the go command without the packages.
+func (config *BuildConfig) command() string {
+	args := []string{"go", "build"}
+	if config.Test {
+		args = []string{"go", "test", "-c"}
+	}
+	if config.GCFlags != "" {
+		args = append(args, "-gcflags="+config.GCFlags)
+	}
+	if config.Tags != "" {
+		args = append(args, "-tags="+config.Tags)
+	}
+	return strings.Join(args, " ")
+}
+
+// String describes the build for display.
+func (config *BuildConfig) String() string {
+	var env []string
+	if config.GOARCH != "" {
+		env = append(env, "GOARCH="+config.GOARCH)
+	}
+	if config.GOAMD64 != "" {
+		env = append(env, "GOAMD64="+config.GOAMD64)
+	}
+	return strings.Join(append(env, config.command(), strings.Join(config.Packages, " ")), " ")
+}
+
+// builder compiles packages into an output directory and loads the
+// results. It is used by the loader in place of opening a file, so that
+// watch mode rebuilds when the package sources change.
+//
+// Load and Stamp are called from the loader goroutine only.
+type builder struct {
+	config BuildConfig
+	// workDir is where the go command runs.
+	workDir string
+	// Dir is the output directory, which stands in for the file path.
+	Dir string
+
+	// sourceDirs are the directories of the packages and their local
+	// dependencies from the last go list, modFiles the go.mod and go.sum
+	// of their modules.
+	sourceDirs []string
+	modFiles   []string
+	// listErr is the error of the last go list, which is retried after
+	// listRetry.
+	listErr    error
+	listFailed time.Time
+
+	// mu guards packages, which is read by the UI.
+	mu       sync.Mutex
+	packages []buildPackage
+}
+
+func newBuilder(config BuildConfig) (*builder, error) {
+	workDir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the output in a stable location per build, so comments
+	// and the session are restored when running the same command again.
+	hash := sha256.Sum256([]byte(workDir + "\x00" + config.String()))
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+	dir := filepath.Join(cacheDir, "lensm", "build", hex.EncodeToString(hash[:8]))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &builder{
+		config:  config,
+		workDir: workDir,
+		Dir:     dir,
+	}, nil
+}
+
+// Load builds the packages when path is the output directory and loads
+// them, other paths, e.g. from the file picker, are opened directly.
+func (b *builder) Load(path string) (disasm.File, error) {
+	if path != b.Dir {
+		return loadDisasmFile(path)
+	}
+
+	packages, err := b.list()
+	if err != nil {
+		return nil, err
+	}
+
+	var outputs []string
+	for _, pkg := range packages {
+		output, err := b.build(pkg)
+		if err != nil {
+			return nil, err
+		}
+		if output != "" {
+			outputs = append(outputs, output)
+		}
+	}
+
+	// Remove outputs of packages that are no longer matched, so that
+	// Open only sees the current build.
+	entries, err := os.ReadDir(b.Dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		stale := filepath.Join(b.Dir, entry.Name())
+		if !slices.Contains(outputs, stale) {
+			_ = os.Remove(stale)
+		}
+	}
+
+	return b.open(outputs)
+}
+
+// Open loads the previously built packages without rebuilding, when path
+// is the output directory. Unlike Load, it's safe for concurrent use, e.g.
+// by the MCP server.
+func (b *builder) Open(path string) (disasm.File, error) {
+	if path != b.Dir {
+		return loadDisasmFile(path)
+	}
+
+	entries, err := os.ReadDir(b.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var outputs []string
+	for _, entry := range entries {
+		outputs = append(outputs, filepath.Join(b.Dir, entry.Name()))
+	}
+	return b.open(outputs)
+}
+
+// open loads the outputs as a single file.
+func (b *builder) open(outputs []string) (disasm.File, error) {
+	var files []disasm.File
+	for _, output := range outputs {
+		file, err := loadDisasmFile(output)
+		if err != nil {
+			_ = closeFiles(files)
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s: no code to disassemble", b.config.String())
+	}
+	if len(files) == 1 {
+		return files[0], nil
+	}
+	return newMultiFile(files), nil
+}
+
+// listRetry is how long Stamp waits before running a failed go list again.
+const listRetry = 5 * time.Second
+
+// Stamp returns the stamp of the package sources when path is the output
+// directory. Besides the latest modification time of the sources, their
+// directories and module files, it includes the names of the sources, so
+// that removing or renaming one triggers a rebuild.
+func (b *builder) Stamp(path string) (fileStamp, error) {
+	if path != b.Dir {
+		return statFile(path)
+	}
+	if b.sourceDirs == nil {
+		// go list is too slow to run on every poll.
+		if b.listErr != nil && time.Since(b.listFailed) < listRetry {
+			return fileStamp{}, b.listErr
+		}
+		if _, err := b.list(); err != nil {
+			b.listErr, b.listFailed = err, time.Now()
+			return fileStamp{}, err
+		}
+	}
+
+	var stamp fileStamp
+	names := fnv.New64a()
+	add := func(path string, info os.FileInfo) {
+		if info.ModTime().After(stamp.modTime) {
+			stamp.modTime = info.ModTime()
+		}
+		_, _ = io.WriteString(names, path+"\x00")
+	}
+	for _, file := range b.modFiles {
+		if info, err := os.Stat(file); err == nil {
+			add(file, info)
+		}
+	}
+	for _, dir := range b.sourceDirs {
+		info, err := os.Stat(dir)
+		if err != nil {
+			// The package may have been removed, the build reports it.
+			continue
+		}
+		// Creating, removing and renaming files updates the directory.
+		add(dir, info)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			add(filepath.Join(dir, entry.Name()), info)
+		}
+	}
+	stamp.files = names.Sum64()
+	return stamp, nil
+}
+
+// Filter returns the initial function filter. For test binaries it shows
+// the packages under test, with Run only the matching tests and benchmarks
+// including their closures besides the code of the packages.
+func (b *builder) Filter(funcs []disasm.Func) string {
+	if !b.config.Test {
+		return ""
+	}
+
+	b.mu.Lock()
+	packages := b.packages
+	b.mu.Unlock()
+	if len(packages) == 0 {
+		return ""
+	}
+
+	run, err := regexp.Compile(topLevelRun(b.config.Run))
+	if b.config.Run == "" || err != nil {
+		// Both the internal and the external test package.
+		var quoted []string
+		for _, pkg := range packages {
+			quoted = append(quoted, regexp.QuoteMeta(pkg.ImportPath+"."), regexp.QuoteMeta(pkg.ImportPath+"_test."))
+		}
+		return "^(?:" + strings.Join(quoted, "|") + ")"
+	}
+
+	// The regular expressions can't exclude names, so the top-level
+	// declarations to show are listed: the matching tests and benchmarks
+	// and the rest of the package under test, the other tests declared in
+	// it are left out.
+	shown := map[string]bool{}
+	matched := false
+	for _, fn := range funcs {
+		for _, pkg := range packages {
+			for _, prefix := range []string{pkg.ImportPath + ".", pkg.ImportPath + "_test."} {
+				name, ok := strings.CutPrefix(fn.Name(), prefix)
+				if !ok {
+					continue
+				}
+				decl, _, _ := strings.Cut(name, ".")
+				switch {
+				case isTestFunc(decl, "Test") || isTestFunc(decl, "Benchmark"):
+					if !run.MatchString(decl) {
+						continue
+					}
+					matched = true
+				case prefix != pkg.ImportPath+".":
+					continue
+				}
+				shown[regexp.QuoteMeta(prefix+decl)] = true
+			}
+		}
+	}
+	if !matched {
+		// Nothing matches, show everything rather than an empty list.
+		return ""
+	}
+	decls := slices.Sorted(maps.Keys(shown))
+	return "^(?:" + strings.Join(decls, "|") + ")(?:\\.|$)"
+}
+
+// topLevelRun returns the part of a -run pattern that matches the top-level
+// tests, splitting the subtests off at the slashes outside of brackets and
+// parentheses the way go test does, e.g. "A/x|B/y" matches the tests "A"
+// and "B".
+func topLevelRun(run string) string {
+	var alternatives []string
+	brackets, parens := 0, 0
+	start, inName := 0, true
+	for i := 0; i < len(run); i++ {
+		switch run[i] {
+		case '[':
+			brackets++
+		case ']':
+			// An unmatched ']' is legal.
+			brackets = max(brackets-1, 0)
+		case '(':
+			if brackets == 0 {
+				parens++
+			}
+		case ')':
+			if brackets == 0 {
+				parens--
+			}
+		case '\\':
+			i++
+		case '/':
+			if brackets == 0 && parens == 0 && inName {
+				alternatives = append(alternatives, run[start:i])
+				inName = false
+			}
+		case '|':
+			if brackets == 0 && parens == 0 {
+				if inName {
+					alternatives = append(alternatives, run[start:i])
+				}
+				start, inName = i+1, true
+			}
+		}
+	}
+	if inName {
+		alternatives = append(alternatives, run[start:])
+	}
+	if len(alternatives) == 1 {
+		return alternatives[0]
+	}
+	return "(?:" + strings.Join(alternatives, ")|(?:") + ")"
+}
+
+// isTestFunc reports whether name is a test function with the prefix,
+// using the same rules as go test, e.g. "Testing" is not a test.
+func isTestFunc(name, prefix string) bool {
+	rest, ok := strings.CutPrefix(name, prefix)
+	if !ok {
+		return false
+	}
+	if rest == "" {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(rest)
+	return !unicode.IsLower(r)
+}
+
+// buildPackage is a package matched by the patterns.
+type buildPackage struct {
+	ImportPath string
+	Name       string
+}
+
+// list resolves the package patterns and remembers their sources.
+func (b *builder) list() ([]buildPackage, error) {
+	args := []string{"list", "-e", "-f", "{{.ImportPath}}\t{{.Name}}"}
+	if b.config.Tags != "" {
+		args = append(args, "-tags="+b.config.Tags)
+	}
+	args = append(args, b.config.Packages...)
+
+	out, err := b.run(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []buildPackage
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 2 {
+			continue
+		}
+		packages = append(packages, buildPackage{ImportPath: fields[0], Name: fields[1]})
+	}
+	if err := b.listSources(); err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	b.packages = packages
+	b.mu.Unlock()
+	if len(packages) == 0 {
+		return nil, fmt.Errorf("go list %s: no packages", strings.Join(b.config.Packages, " "))
+	}
+	return packages, nil
+}
+
+// listSources remembers the directories and module files that the build
+// reads. The dependencies in the module cache and GOROOT don't change, only
+// the ones in the main module or replaced by another module are watched.
+func (b *builder) listSources() error {
+	args := []string{"list", "-e", "-deps", "-f",
+		`{{if not .Standard}}{{with .Module}}{{if or .Main .Replace}}{{$.Dir}}{{"\t"}}{{.GoMod}}{{end}}{{end}}{{end}}`}
+	if b.config.Test {
+		args = append(args, "-test")
+	}
+	if b.config.Tags != "" {
+		args = append(args, "-tags="+b.config.Tags)
+	}
+	args = append(args, b.config.Packages...)
+
+	out, err := b.run(args...)
+	if err != nil {
+		return err
+	}
+
+	dirs, modFiles := []string{}, []string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		dir, goMod, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+		if goMod != "" {
+			modFiles = append(modFiles, goMod, filepath.Join(filepath.Dir(goMod), "go.sum"))
+		}
+	}
+	// The test variants list the same directories again.
+	slices.Sort(dirs)
+	slices.Sort(modFiles)
+	b.sourceDirs, b.modFiles = slices.Compact(dirs), slices.Compact(modFiles)
+	return nil
+}
+
+// build compiles a single package and returns the output path, which is
+// empty when there's nothing to build, e.g. a package without tests.
+func (b *builder) build(pkg buildPackage) (string, error) {
+	name := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(pkg.ImportPath)
+	var args []string
+	switch {
+	case b.config.Test:
+		name += ".test"
+		args = []string{"test", "-c"}
+	case pkg.Name == "main":
+		name += ".exe"
+		args = []string{"build"}
+	default:
+		// Non-main packages are written as an archive.
+		name += ".a"
+		args = []string{"build"}
+	}
+	output := filepath.Join(b.Dir, name)
+
+	if b.config.GCFlags != "" {
+		args = append(args, "-gcflags="+b.config.GCFlags)
+	}
+	if b.config.Tags != "" {
+		args = append(args, "-tags="+b.config.Tags)
+	}
+	args = append(args, "-o", output, pkg.ImportPath)
+
+	// Remove the previous output, otherwise a package that no longer
+	// produces one would show stale code.
+	if err := os.Remove(output); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	if _, err := b.run(args...); err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(output); errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	return output, nil
+}
+
+// run runs the go command with the configured environment.
+func (b *builder) run(args ...string) ([]byte, error) {
+	cmd := exec.Command("go", args...)
+	cmd.Dir = b.workDir
+	cmd.Env = os.Environ()
+	if b.config.GOARCH != "" {
+		cmd.Env = append(cmd.Env, "GOARCH="+b.config.GOARCH)
+	}
+	if b.config.GOAMD64 != "" {
+		cmd.Env = append(cmd.Env, "GOAMD64="+b.config.GOAMD64)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go %s: %w\n%s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+var _ disasm.DataFile = (*multiFile)(nil)
+
+// multiFile combines the functions of several files.
+type multiFile struct {
+	files []disasm.File
+	funcs []disasm.Func
+	data  func() []disasm.Func
+}
+
+func newMultiFile(files []disasm.File) *multiFile {
+	multi := &multiFile{files: files}
+	for _, file := range files {
+		multi.funcs = append(multi.funcs, file.Funcs()...)
+	}
+	sortByName(multi.funcs)
+	multi.data = sync.OnceValue(func() []disasm.Func {
+		var data []disasm.Func
+		for _, file := range files {
+			data = append(data, disasm.DataSymbols(file)...)
+		}
+		sortByName(data)
+		return data
+	})
+	return multi
+}
+
+func sortByName(funcs []disasm.Func) {
+	sort.SliceStable(funcs, func(i, k int) bool {
+		return strings.ToLower(funcs[i].Name()) < strings.ToLower(funcs[k].Name())
+	})
+}
+
+func (multi *multiFile) Funcs() []disasm.Func { return multi.funcs }
+
+// Data combines the data symbols of the files, they are read on the first
+// call.
+func (multi *multiFile) Data() []disasm.Func { return multi.data() }
+
+func (multi *multiFile) Close() error {
+	return closeFiles(multi.files)
+}
+
+// InlinedInto combines the inline sites of the files that record them.
+func (multi *multiFile) InlinedInto(name string) ([]disasm.InlineSite, error) {
+	var sites []disasm.InlineSite
+	var errs []error
+	indexed := false
+	for _, file := range multi.files {
+		index, ok := file.(disasm.InlineIndex)
+		if !ok {
+			continue
+		}
+		found, err := index.InlinedInto(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		indexed = true
+		sites = append(sites, found...)
+	}
+	if !indexed {
+		return nil, errors.Join(errs...)
+	}
+	sort.SliceStable(sites, func(i, k int) bool { return sites[i].Size > sites[k].Size })
+	return sites, nil
+}
+
+// IndexCalls indexes the calls of the files that support it.
+func (multi *multiFile) IndexCalls() {
+	for _, file := range multi.files {
+		if index, ok := file.(disasm.CallIndex); ok {
+			index.IndexCalls()
+		}
+	}
+}
+
+// Callers combines the calls to name from all files.
+func (multi *multiFile) Callers(name string) ([]disasm.CallSite, error) {
+	return multi.lookupCalls(disasm.CallIndex.Callers, name)
+}
+
+// Callees combines the calls from name in all files.
+func (multi *multiFile) Callees(name string) ([]disasm.CallSite, error) {
+	return multi.lookupCalls(disasm.CallIndex.Callees, name)
+}
+
+func (multi *multiFile) lookupCalls(lookup func(disasm.CallIndex, string) ([]disasm.CallSite, error), name string) ([]disasm.CallSite, error) {
+	var sites []disasm.CallSite
+	var errs []error
+	for _, file := range multi.files {
+		index, ok := file.(disasm.CallIndex)
+		if !ok {
+			continue
+		}
+		found, err := lookup(index, name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sites = append(sites, found...)
+	}
+	return sites, errors.Join(errs...)
+}
+
+// SymbolSizes combines the sizes of the symbols in all files.
+func (multi *multiFile) SymbolSizes() ([]disasm.SymbolSize, error) {
+	var sizes []disasm.SymbolSize
+	var errs []error
+	for _, file := range multi.files {
+		found, err := disasm.SymbolSizes(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sizes = append(sizes, found...)
+	}
+	return sizes, errors.Join(errs...)
+}
+
+func closeFiles(files []disasm.File) error {
+	var errs []error
+	for _, file := range files {
+		errs = append(errs, file.Close())
+	}
+	return errors.Join(errs...)
+}
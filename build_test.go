@@ -0,0 +1,254 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+
+	"loov.dev/lensm/internal/disasm"
+)
+
+func TestBuilderBuildsPackages(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go build")
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.22\n",
+		"main.go": `package main
+
+import "example.com/m/lib"
+
+func main() { println(lib.Add(1, 2)) }
+`,
+		"lib/lib.go": `package lib
+
+//go:noinline
+func Add(a, b int) int { return a + b }
+`,
+		"lib/doc.go": "// Package lib adds.\npackage lib\n",
+	}
+	setupBuildModule(t, dir, files)
+
+	build, err := newBuilder(BuildConfig{
+		Packages: []string{"./..."},
+		GCFlags:  "-N -l",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := build.Load(build.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+
+	found := map[string]bool{}
+	for _, fn := range file.Funcs() {
+		found[fn.Name()] = true
+	}
+	for _, name := range []string{"main.main", "example.com/m/lib.Add"} {
+		if !found[name] {
+			t.Errorf("%s not found", name)
+		}
+	}
+
+	stamp := func() fileStamp {
+		t.Helper()
+		stamp, err := build.Stamp(build.Dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stamp
+	}
+	// changed runs edit and checks that the stamp differs afterwards.
+	changed := func(what string, edit func() error) {
+		t.Helper()
+		before := stamp()
+		if err := edit(); err != nil {
+			t.Fatal(err)
+		}
+		if after := stamp(); after.equal(before) {
+			t.Errorf("stamp did not change after %s", what)
+		}
+	}
+
+	later := stamp().modTime.Add(time.Second)
+	changed("editing a source", func() error {
+		return os.Chtimes(filepath.Join(dir, "lib", "lib.go"), later, later)
+	})
+	changed("editing go.mod", func() error {
+		later = later.Add(time.Second)
+		return os.Chtimes(filepath.Join(dir, "go.mod"), later, later)
+	})
+	// The directory keeps its time, only the file set changes.
+	changed("removing a source", func() error {
+		libDir := filepath.Join(dir, "lib")
+		info, err := os.Stat(libDir)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(filepath.Join(libDir, "doc.go")); err != nil {
+			return err
+		}
+		return os.Chtimes(libDir, info.ModTime(), info.ModTime())
+	})
+}
+
+func TestBuilderFiltersTests(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test -c")
+	}
+
+	dir := t.TempDir()
+	setupBuildModule(t, dir, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.22\n",
+		"lib.go": `package lib
+
+//go:noinline
+func Add(a, b int) int { return a + b }
+`,
+		"lib_test.go": `package lib
+
+import "testing"
+
+func TestAdd(t *testing.T) {
+	t.Run("sub", func(t *testing.T) { Add(1, 2) })
+}
+
+func BenchmarkAdd(b *testing.B) {
+	for range b.N {
+		Add(1, 2)
+	}
+}
+
+func TestOther(t *testing.T) {}
+`,
+		"lib_ext_test.go": `package lib_test
+
+import (
+	"testing"
+
+	"example.com/m"
+)
+
+func TestAddExternal(t *testing.T) { lib.Add(1, 2) }
+
+func TestOtherExternal(t *testing.T) {}
+`,
+	})
+
+	build, err := newBuilder(BuildConfig{
+		Packages: []string{"."},
+		Test:     true,
+		Run:      "Add",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := build.Load(build.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+
+	filter := regexp.MustCompile(build.Filter(file.Funcs()))
+	for name, want := range map[string]bool{
+		"example.com/m.TestAdd":                true,
+		"example.com/m.TestAdd.func1":          true,
+		"example.com/m.BenchmarkAdd":           true,
+		"example.com/m.Add":                    true,
+		"example.com/m.TestOther":              false,
+		"example.com/m.TestAddNothing":         false,
+		"example.com/m_test.TestAddExternal":   true,
+		"example.com/m_test.TestOtherExternal": false,
+		"example.com/other.TestAddNothing":     false,
+	} {
+		if got := filter.MatchString(name); got != want {
+			t.Errorf("filter %q matches %s = %v, want %v", filter, name, got, want)
+		}
+	}
+
+	// Subtests are matched by go test, the functions are the top-level
+	// tests.
+	build.config.Run = "TestAdd/sub"
+	filter = regexp.MustCompile(build.Filter(file.Funcs()))
+	for name, want := range map[string]bool{
+		"example.com/m.TestAdd":       true,
+		"example.com/m.TestAdd.func1": true,
+		"example.com/m.Add":           true,
+		"example.com/m.BenchmarkAdd":  false,
+		"example.com/m.TestOther":     false,
+	} {
+		if got := filter.MatchString(name); got != want {
+			t.Errorf("filter %q matches %s = %v, want %v", filter, name, got, want)
+		}
+	}
+}
+
+func TestTopLevelRun(t *testing.T) {
+	for run, want := range map[string]string{
+		"Add":         "Add",
+		"Foo/bar":     "Foo",
+		"A/x|B/y":     "(?:A)|(?:B)",
+		"Foo[/]x/bar": "Foo[/]x",
+		"(A/B)/c":     "(A/B)",
+		`Foo\/x/bar`:  `Foo\/x`,
+		"/only":       "",
+	} {
+		if got := topLevelRun(run); got != want {
+			t.Errorf("topLevelRun(%q) = %q, want %q", run, got, want)
+		}
+	}
+}
+
+// setupBuildModule writes files into dir and makes it the working
+// directory, with a separate cache for the build output.
+func setupBuildModule(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+
+	// Keep using the shared Go build cache, it defaults to a directory
+	// inside the user cache, which is replaced below.
+	if os.Getenv("GOCACHE") == "" {
+		if cache, err := os.UserCacheDir(); err == nil {
+			t.Setenv("GOCACHE", filepath.Join(cache, "go-build"))
+		}
+	}
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+}
+
+// dataSymbolsFile is a file with only data symbols.
+type dataSymbolsFile []disasm.Func
+
+func (file dataSymbolsFile) Funcs() []disasm.Func { return nil }
+func (file dataSymbolsFile) Data() []disasm.Func  { return file }
+func (file dataSymbolsFile) Close() error         { return nil }
+
+func TestMultiFileData(t *testing.T) {
+	multi := newMultiFile([]disasm.File{
+		dataSymbolsFile{dataTestFunc{"lib.table"}, dataTestFunc{"go:string.*"}},
+		dataSymbolsFile{dataTestFunc{"main.counter"}},
+	})
+	var names []string
+	for _, data := range disasm.DataSymbols(multi) {
+		names = append(names, data.Name())
+	}
+	if want := []string{"go:string.*", "lib.table", "main.counter"}; !slices.Equal(names, want) {
+		t.Errorf("DataSymbols = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"loov.dev/lensm/internal/asmdiff"
+	"loov.dev/lensm/internal/disasm"
+)
+
+// diffCommand compares two builds of an executable, see `lensm diff -h`.
+type diffCommand struct {
+	OldPath, NewPath string
+	// Func prints the instructions of the function side by side.
+	Func string
+	// Code compares the instructions of the functions with the same size.
+	Code bool
+	// All lists the functions that didn't change too.
+	All bool
+	// GUI opens the diff in a window.
+	GUI   bool
+	Arch  string
+	Width int
+
+	before, after disasm.File
+	funcs         []*asmdiff.Func
+}
+
+// parseDiffCommand parses the arguments of `lensm diff`, it returns the exit
+// code when they are invalid.
+func parseDiffCommand(args []string) (*diffCommand, int) {
+	cmd := &diffCommand{}
+	fs := flag.NewFlagSet("lensm diff", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.StringVar(&cmd.Func, "func", "", "print the instructions of the function side by side")
+	fs.BoolVar(&cmd.Code, "code", false, "compare the instructions of the functions with the same size, slower")
+	fs.BoolVar(&cmd.All, "all", false, "list the functions that didn't change")
+	fs.BoolVar(&cmd.GUI, "gui", false, "open the diff in a window")
+	fs.StringVar(&cmd.Arch, "arch", "", "architecture of an universal binary")
+	fs.IntVar(&cmd.Width, "width", 60, "width of a column with -func")
+	if err := fs.Parse(args); err != nil {
+		return nil, 2
+	}
+	cmd.Width = max(cmd.Width, 16)
+	if fs.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: lensm diff [-func name] [-code] [-all] [-gui] [-arch goarch] <old> <new>")
+		return nil, 2
+	}
+	cmd.OldPath, cmd.NewPath = fs.Arg(0), fs.Arg(1)
+	return cmd, 0
+}
+
+// open loads both builds and matches the functions.
+func (cmd *diffCommand) open() error {
+	var err error
+	if cmd.before, err = cmd.load(cmd.OldPath); err != nil {
+		return err
+	}
+	if cmd.after, err = cmd.load(cmd.NewPath); err != nil {
+		return err
+	}
+	cmd.funcs = asmdiff.Compare(cmd.before.Funcs(), cmd.after.Funcs())
+	if cmd.Code {
+		for _, fn := range cmd.funcs {
+			if fn.Status != asmdiff.Same || fn.Old == nil {
+				continue
+			}
+			before, after, err := fn.Load(disasm.Options{})
+			if err == nil && asmdiff.Differs(asmdiff.Diff(before, after)) {
+				fn.Status = asmdiff.Changed
+			}
+		}
+	}
+	asmdiff.SortByChange(cmd.funcs)
+	return nil
+}
+
+func (cmd *diffCommand) load(path string) (disasm.File, error) {
+	file, err := loadDisasmFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := disasm.SelectArch(file, cmd.Arch); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+func (cmd *diffCommand) Close() error {
+	var errs []error
+	for _, file := range []disasm.File{cmd.before, cmd.after} {
+		if file != nil {
+			errs = append(errs, file.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// find returns the function with the name.
+func (cmd *diffCommand) find(name string) *asmdiff.Func {
+	for _, fn := range cmd.funcs {
+		if fn.Name() == name {
+			return fn
+		}
+	}
+	return nil
+}
+
+// runDiff prints the diff, it returns the exit code.
+func runDiff(cmd *diffCommand, w io.Writer) int {
+	defer func() { _ = cmd.Close() }()
+	if err := cmd.open(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if cmd.Func != "" {
+		fn := cmd.find(cmd.Func)
+		if fn == nil {
+			fmt.Fprintf(os.Stderr, "function %q not found in either build\n", cmd.Func)
+			return 1
+		}
+		if err := printFuncDiff(w, fn, cmd.Width); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+	printDiffSummary(w, cmd.funcs, cmd.All, cmd.Code)
+	return 0
+}
+
+// printDiffSummary prints the totals per status and the functions that
+// changed, compared tells whether the instructions of the functions with
+// the same size were compared.
+func printDiffSummary(w io.Writer, funcs []*asmdiff.Func, all, compared bool) {
+	count := map[asmdiff.Status]int{}
+	delta := map[asmdiff.Status]int64{}
+	var total int64
+	for _, fn := range funcs {
+		count[fn.Status]++
+		delta[fn.Status] += fn.Delta()
+		total += fn.Delta()
+	}
+	var parts []string
+	for _, status := range []asmdiff.Status{asmdiff.Added, asmdiff.Removed, asmdiff.Grown, asmdiff.Shrunk, asmdiff.Changed} {
+		if count[status] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s (%s B)", count[status], status, asmdiff.FormatDelta(delta[status])))
+		}
+	}
+	// Without comparing the instructions a function of the same size may
+	// still have changed.
+	unchecked := ""
+	if !compared && count[asmdiff.Same] > 0 {
+		unchecked = fmt.Sprintf("%d of the same size not compared, -code finds the ones whose instructions changed\n", count[asmdiff.Same])
+	}
+	if len(parts) == 0 {
+		if unchecked != "" {
+			fmt.Fprint(w, "no changes in size, "+unchecked)
+			return
+		}
+		fmt.Fprintln(w, "no changes")
+		return
+	}
+	fmt.Fprintf(w, "%s, code %s B\n", strings.Join(parts, ", "), asmdiff.FormatDelta(total))
+	fmt.Fprint(w, unchecked)
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "STATUS\tOLD\tNEW\tDELTA\t\tFUNCTION")
+	for _, fn := range funcs {
+		if fn.Status == asmdiff.Same && !all {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\t%s\n", fn.Status, formatSize(fn.Old, fn.OldSize), formatSize(fn.New, fn.NewSize), asmdiff.FormatDelta(fn.Delta()), fn.Name())
+	}
+	_ = tw.Flush()
+}
+
+// formatSize formats the size of the function in a build, "-" when it's
+// missing.
+func formatSize(fn disasm.Func, size uint64) string {
+	if fn == nil {
+		return "-"
+	}
+	return strconv.FormatUint(size, 10)
+}
+
+// diffMarks are the markers between the columns, as in `diff -y`.
+var diffMarks = [...]string{
+	asmdiff.Equal:  " ",
+	asmdiff.Delete: "<",
+	asmdiff.Insert: ">",
+	asmdiff.Change: "|",
+}
+
+// printFuncDiff prints the instructions of both builds side by side.
+func printFuncDiff(w io.Writer, fn *asmdiff.Func, width int) error {
+	before, after, err := fn.Load(disasm.Options{})
+	if err != nil {
+		return err
+	}
+	rows := asmdiff.Diff(before, after)
+	if fn.Status == asmdiff.Same && asmdiff.Differs(rows) {
+		fn.Status = asmdiff.Changed
+	}
+	fmt.Fprintf(w, "%s: %s, %s → %s B (%s)\n", fn.Name(), fn.Status, formatSize(fn.Old, fn.OldSize), formatSize(fn.New, fn.NewSize), asmdiff.FormatDelta(fn.Delta()))
+
+	column := func(code *disasm.Code, index int) string {
+		if index < 0 {
+			return ""
+		}
+		inst := code.Insts[index]
+		text, _, _ := strings.Cut(inst.Text, "\t")
+		text = fmt.Sprintf("%6x  %s", inst.PC, text)
+		if runes := []rune(text); len(runes) > width {
+			text = string(runes[:width-1]) + "…"
+		}
+		return text
+	}
+	for _, row := range rows {
+		left, right := column(before, row.Old), column(after, row.New)
+		pad := strings.Repeat(" ", width-utf8.RuneCountInString(left))
+		fmt.Fprintln(w, strings.TrimRight(left+pad+" "+diffMarks[row.Op]+" "+right, " "))
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"loov.dev/lensm/internal/asmdiff"
+	"loov.dev/lensm/internal/disasm"
+)
+
+type sizedTestFunc struct {
+	name string
+	size uint64
+}
+
+func (fn sizedTestFunc) Name() string                              { return fn.name }
+func (fn sizedTestFunc) Size() uint64                              { return fn.size }
+func (fn sizedTestFunc) Load(disasm.Options) (*disasm.Code, error) { return &disasm.Code{}, nil }
+
+func TestPrintDiffSummaryNotesUncomparedFuncs(t *testing.T) {
+	summary := func(compared bool, before, after []disasm.Func) string {
+		var out strings.Builder
+		printDiffSummary(&out, asmdiff.Compare(before, after), false, compared)
+		return out.String()
+	}
+	same := []disasm.Func{sizedTestFunc{"main.same", 8}}
+	grown := []disasm.Func{sizedTestFunc{"main.same", 8}, sizedTestFunc{"main.grow", 16}}
+	grownAfter := []disasm.Func{sizedTestFunc{"main.same", 8}, sizedTestFunc{"main.grow", 32}}
+
+	const note = "1 of the same size not compared"
+	if got := summary(false, same, same); !strings.HasPrefix(got, "no changes in size, "+note) {
+		t.Errorf("unchanged sizes:\n%s", got)
+	}
+	if got := summary(true, same, same); got != "no changes\n" {
+		t.Errorf("compared:\n%s", got)
+	}
+	got := summary(false, grown, grownAfter)
+	if !strings.Contains(got, "1 grown (+16 B)") || !strings.Contains(got, note) || strings.Contains(got, "main.same") {
+		t.Errorf("grown:\n%s", got)
+	}
+	// A single blank line separates the totals from the table.
+	if lines := strings.Split(got, "\n"); len(lines) < 4 || lines[2] != "" || !strings.Contains(lines[3], "STATUS") {
+		t.Errorf("grown layout:\n%s", got)
+	}
+	got = summary(true, grown, grownAfter)
+	if strings.Contains(got, "not compared") {
+		t.Errorf("compared grown:\n%s", got)
+	}
+	if lines := strings.Split(got, "\n"); len(lines) < 3 || lines[1] != "" || !strings.Contains(lines[2], "STATUS") {
+		t.Errorf("compared grown layout:\n%s", got)
+	}
+}
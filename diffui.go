@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+
+	"gioui.org/app"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"gioui.org/x/component"
+
+	"loov.dev/lensm/internal/asmdiff"
+	"loov.dev/lensm/internal/codeview"
+	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/gui"
+	"loov.dev/lensm/internal/syntax"
+)
+
+// DiffUI shows the functions that changed between two builds and the
+// instructions of the selected one side by side.
+type DiffUI struct {
+	Theme *gui.Theme
+	cmd   *diffCommand
+
+	Funcs *gui.FilterList[*asmdiff.Func]
+	// ShowSame lists the functions with the same size too.
+	ShowSame widget.Bool
+	split    component.Resize
+
+	// selected is the function of rows.
+	selected      *asmdiff.Func
+	before, after *disasm.Code
+	rows          []asmdiff.Row
+	err           error
+	list          widget.List
+}
+
+func NewDiffUI(theme *material.Theme, cmd *diffCommand) *DiffUI {
+	settings, err := LoadAppSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to load settings: %v\n", err)
+	}
+	ui := &DiffUI{cmd: cmd}
+	theme.TextSize = unit.Sp(settings.TextSize)
+	ui.Theme = gui.NewTheme(theme, settings.Dark)
+	ui.Funcs = gui.NewFilterList[*asmdiff.Func](ui.Theme)
+	ui.split.Axis = layout.Horizontal
+	ui.split.Ratio = settings.SidebarRatio
+	ui.list.Axis = layout.Vertical
+	ui.updateFuncs()
+	return ui
+}
+
+// updateFuncs lists the functions that changed, or all with ShowSame.
+func (ui *DiffUI) updateFuncs() {
+	var funcs []*asmdiff.Func
+	for _, fn := range ui.cmd.funcs {
+		if fn.Status != asmdiff.Same || ui.ShowSame.Value {
+			funcs = append(funcs, fn)
+		}
+	}
+	ui.Funcs.SetItems(funcs)
+}
+
+func (ui *DiffUI) Run(w *app.Window) error {
+	defer func() { _ = ui.cmd.Close() }()
+	var ops op.Ops
+	for {
+		switch e := w.Event().(type) {
+		case app.DestroyEvent:
+			return e.Err
+		case app.FrameEvent:
+			gtx := app.NewContext(&ops, e)
+			ui.Layout(gtx)
+			e.Frame(gtx.Ops)
+		}
+	}
+}
+
+func (ui *DiffUI) Layout(gtx layout.Context) {
+	colors := ui.Theme.Colors
+	paint.FillShape(gtx.Ops, colors.Background, clip.Rect{Max: gtx.Constraints.Max}.Op())
+
+	if ui.ShowSame.Update(gtx) {
+		ui.updateFuncs()
+	}
+	if fn := ui.Funcs.SelectedItem; fn != ui.selected {
+		ui.selectFunc(fn)
+	}
+
+	ui.split.Layout(gtx,
+		func(gtx layout.Context) layout.Dimensions {
+			gtx.Constraints = layout.Exact(gtx.Constraints.Max)
+			paint.FillShape(gtx.Ops, colors.SecondaryBackground, clip.Rect{Max: gtx.Constraints.Max}.Op())
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					check := material.CheckBox(ui.Theme.Theme, &ui.ShowSame, "Same size")
+					check.Color = colors.MutedText
+					check.IconColor = ui.Theme.ContrastBg
+					check.TextSize = ui.Theme.TextSize * 0.78
+					check.Size = unit.Dp(18)
+					return layout.Inset{Top: 2, Bottom: 2, Left: 4}.Layout(gtx, check.Layout)
+				}),
+				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+					gtx.Constraints = layout.Exact(gtx.Constraints.Max)
+					return ui.Funcs.Layout(ui.Theme, gtx)
+				}),
+			)
+		},
+		func(gtx layout.Context) layout.Dimensions {
+			size := image.Pt(gtx.Metric.Dp(8), gtx.Constraints.Max.Y)
+			paint.FillShape(gtx.Ops, colors.Splitter, clip.Rect{
+				Min: image.Pt(size.X/2, 0),
+				Max: image.Pt(size.X/2+1, size.Y),
+			}.Op())
+			return layout.Dimensions{Size: size}
+		},
+		func(gtx layout.Context) layout.Dimensions {
+			gtx.Constraints = layout.Exact(gtx.Constraints.Max)
+			return ui.layoutDiff(gtx, colors)
+		},
+	)
+}
+
+// selectFunc loads both versions of fn and diffs them.
+func (ui *DiffUI) selectFunc(fn *asmdiff.Func) {
+	ui.selected, ui.before, ui.after, ui.rows, ui.err = fn, nil, nil, nil, nil
+	ui.list.Position = layout.Position{}
+	if fn == nil {
+		return
+	}
+	ui.before, ui.after, ui.err = fn.Load(disasm.Options{})
+	if ui.err == nil {
+		ui.rows = asmdiff.Diff(ui.before, ui.after)
+		if fn.Status == asmdiff.Same && asmdiff.Differs(ui.rows) {
+			fn.Status = asmdiff.Changed
+		}
+	}
+}
+
+func (ui *DiffUI) layoutDiff(gtx layout.Context, colors gui.UIColors) layout.Dimensions {
+	fn := ui.selected
+	if fn == nil {
+		return layout.Dimensions{Size: gtx.Constraints.Max}
+	}
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			title := fmt.Sprintf("%s  %s, %s → %s B (%s)", fn.Name(), fn.Status,
+				formatSize(fn.Old, fn.OldSize), formatSize(fn.New, fn.NewSize), asmdiff.FormatDelta(fn.Delta()))
+			label := ui.Theme.Label(title, 0.85)
+			label.MaxLines = 1
+			return layout.Inset{Top: 4, Right: 6, Bottom: 4, Left: 8}.Layout(gtx, label.Layout)
+		}),
+		layout.Rigid(gui.HorizontalLine{Height: 1, Color: colors.Splitter}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			if ui.err != nil {
+				return layout.UniformInset(8).Layout(gtx, ui.Theme.ErrorLabel(ui.err.Error(), 0.85).Layout)
+			}
+			gtx.Constraints = layout.Exact(gtx.Constraints.Max)
+			return material.List(ui.Theme.Theme, &ui.list).Layout(gtx, len(ui.rows), func(gtx layout.Context, i int) layout.Dimensions {
+				return ui.layoutRow(gtx, colors, ui.rows[i])
+			})
+		}),
+	)
+}
+
+// layoutRow draws the old instruction on the left and the new one on the
+// right.
+func (ui *DiffUI) layoutRow(gtx layout.Context, colors gui.UIColors, row asmdiff.Row) layout.Dimensions {
+	textHeight := ui.Theme.TextSize * 0.85
+	size := image.Pt(gtx.Constraints.Max.X, gtx.Metric.Sp(textHeight*1.4))
+	half := size.X / 2
+	pad := gtx.Metric.Dp(8)
+
+	side := func(code *disasm.Code, index, left int) {
+		if index < 0 {
+			return
+		}
+		if bg := codeview.DiffColors[row.Op]; bg != (color.NRGBA{}) {
+			paint.FillShape(gtx.Ops, bg, clip.Rect{Min: image.Pt(left, 0), Max: image.Pt(left+half, size.Y)}.Op())
+		}
+		inst := code.Insts[index]
+		gui.SourceLine{
+			TopLeft:    image.Pt(left+pad, 0),
+			Width:      half - 2*pad,
+			TextHeight: textHeight,
+			Spans: []syntax.Span{
+				{Text: fmt.Sprintf("%6x  ", inst.PC), Color: colors.MutedText},
+				{Text: inst.Text, Color: colors.Text},
+			},
+		}.Layout(ui.Theme.Theme, gtx)
+	}
+	side(ui.before, row.Old, 0)
+	side(ui.after, row.New, half)
+	paint.FillShape(gtx.Ops, colors.Splitter, clip.Rect{Min: image.Pt(half, 0), Max: image.Pt(half+1, size.Y)}.Op())
+	return layout.Dimensions{Size: size}
+}
@@ -31,16 +31,21 @@ import (
 	"loov.dev/lensm/internal/disasm"
 	"loov.dev/lensm/internal/gui"
 	"loov.dev/lensm/internal/mcp"
+	"loov.dev/lensm/internal/profile"
 	"loov.dev/lensm/internal/syntax"
 )
 
-var workInProgressWASM bool
-
 type FileUIConfig struct {
 	Path         string
 	Watch        bool
 	Context      int
 	CommentsPath string
+	// SourceRules rewrite source paths, in addition to the per binary settings.
+	SourceRules []disasm.PathRule
+	// Build compiles packages for `lensm build`, nil otherwise.
+	Build *builder
+	// Arch selects the architecture of universal binaries.
+	Arch string
 }
 
 type FileUI struct {
@@ -69,12 +74,20 @@ type FileUI struct {
 	SettingsButton widget.Clickable
 	Dark           widget.Bool
 	SyntaxStyle    widget.Enum
+	Arch           widget.Enum
+	Sidebar        widget.Enum
 	ShowNativeAsm  widget.Bool
 	ShowAsmHelp    widget.Bool
+	ShowGraph      widget.Bool
+	ShowCallers    widget.Bool
+	ShowDiff       widget.Bool
+	ShowSize       widget.Bool
+	ProfileButton  widget.Clickable
 	Comment        widget.Editor
 	TextSizeEditor widget.Editor
 	StartMCP       widget.Clickable
 	StopMCP        widget.Clickable
+	SourceRules    widget.Editor
 
 	Comments *comments.Store
 	MCP      *mcp.AppServer
@@ -95,14 +108,26 @@ type FileUI struct {
 	pickerOpen         bool
 	loadGeneration     uint64
 	loadedPath         string
+	loadedAt           time.Time
 	Navigation         NavigationHistory
 	navigatingHistory  bool
+	sourceMap          *disasm.SourceMap
+	sourceRulesError   string
+	inlined            inlinedPanel
+	callers            callersPanel
+	size               sizePanel
+	// profile is shown over the loaded file as overlay.
+	profile     *profile.Profile
+	profilePath string
+	overlay     *profile.Overlay
 }
 
 type pickerResult struct {
 	path string
 	ok   bool
 	err  error
+	// profile is set when a profile was chosen instead of an executable.
+	profile *profile.Profile
 }
 
 func NewFileUI(windows *gui.Windows, theme *material.Theme) *FileUI {
@@ -117,6 +142,7 @@ func NewFileUI(windows *gui.Windows, theme *material.Theme) *FileUI {
 	ui.SyntaxStyle.Value = settings.SyntaxStyle
 	ui.Dark.Value = settings.Dark
 	ui.Funcs = gui.NewFilterList[disasm.Func](ui.Theme)
+	ui.Sidebar.Value = sidebarFuncs
 	ui.ActiveTab = -1
 	ui.Navigation.Reset()
 	ui.Tabs.List.Axis = layout.Horizontal
@@ -128,6 +154,8 @@ func NewFileUI(windows *gui.Windows, theme *material.Theme) *FileUI {
 	ui.TextSizeEditor.SingleLine = true
 	ui.TextSizeEditor.Submit = true
 	ui.TextSizeEditor.SetText(strconv.Itoa(settings.TextSize))
+	ui.SourceRules.SingleLine = true
+	ui.SourceRules.Submit = true
 	ui.Comments, _ = comments.Open("", "")
 	return ui
 }
@@ -143,7 +171,11 @@ func (ui *FileUI) Run(w *app.Window) error {
 		}
 	}()
 
-	loader := newLoader(loadDisasmFile, ui.Config.Watch)
+	loadFile, stamp := loadDisasmFile, statFile
+	if ui.Config.Build != nil {
+		loadFile, stamp = ui.Config.Build.Load, ui.Config.Build.Stamp
+	}
+	loader := newLoader(loadFile, stamp, ui.Config.Watch)
 	ui.loader = loader
 	defer loader.Close()
 	picker := explorer.NewExplorer(w)
@@ -203,7 +235,7 @@ func (ui *FileUI) Run(w *app.Window) error {
 			if result.err != nil {
 				ui.LoadError = result.err
 				if ui.MCP != nil {
-					ui.MCP.SetPath("", ui.Comments)
+					ui.MCP.SetPath("", ui.Comments, nil, "")
 				}
 				w.Invalidate()
 				continue
@@ -219,6 +251,8 @@ func (ui *FileUI) Run(w *app.Window) error {
 			ui.pickerOpen = false
 			if res.err != nil {
 				ui.LoadError = res.err
+			} else if res.profile != nil {
+				ui.setProfile(res.path, res.profile)
 			} else if res.ok {
 				ui.Config.Path = res.path
 				ui.LoadError = nil
@@ -268,12 +302,21 @@ func (ui *FileUI) SetFile(file disasm.File) {
 	if ui.File == nil || path != ui.loadedPath {
 		ui.Navigation.Reset()
 	}
+	// A watch-mode reload keeps the earlier builds of the open tabs.
+	var history map[string][]codeview.Snapshot
+	if ui.File != nil && ui.File != file && path == ui.loadedPath {
+		history = ui.tabHistory()
+	}
 	ui.loadedPath = path
 
 	initialLoad := ui.File == nil
-	if ui.File != nil {
+	// Switching the architecture reloads the same file.
+	if ui.File != nil && ui.File != file {
 		_ = ui.File.Close()
 	}
+	if ui.File != file {
+		ui.loadedAt = time.Now()
+	}
 
 	openNames := make([]string, 0, len(ui.CodeTabs))
 	for _, tab := range ui.CodeTabs {
@@ -293,11 +336,20 @@ func (ui *FileUI) SetFile(file disasm.File) {
 
 	ui.File = file
 	ui.LoadError = nil
+	if err := disasm.SelectArch(file, ui.Config.Arch); err != nil {
+		ui.LoadError = err
+	}
+	ui.Arch.Value = ui.selectedArch()
+	ui.updateSourceMap()
 	ui.loadCommentsForPath(ui.Config.Path)
 	ui.CodeTabs = nil
 	ui.ActiveTab = -1
 	ui.commentKey = ""
-	ui.Funcs.SetItems(file.Funcs())
+	ui.attachProfile()
+	ui.Funcs.SetItems(ui.sidebarItems())
+	if initialLoad && ui.isBuildOutput() && ui.Funcs.Filter.Text() == "" {
+		ui.Funcs.SetFilter(ui.Config.Build.Filter(file.Funcs()))
+	}
 
 	if activeName != "" {
 		ui.selectFuncByName(activeName)
@@ -313,6 +365,7 @@ func (ui *FileUI) SetFile(file disasm.File) {
 			ui.selectFuncByName(tab.Name)
 		}
 	}
+	ui.restoreHistory(history)
 
 	if len(ui.CodeTabs) == 0 {
 		if ui.Funcs.SelectedItem == nil && len(ui.Funcs.Filtered) > 0 {
@@ -330,9 +383,10 @@ func (ui *FileUI) SetFile(file disasm.File) {
 }
 
 func (ui *FileUI) loadOptions() disasm.Options {
-	return disasm.Options{Context: ui.Config.Context}
+	return disasm.Options{Context: ui.Config.Context, SourceMap: ui.sourceMap}
 }
 
+// findFunc finds a function or a data symbol by name.
 func (ui *FileUI) findFunc(name string) disasm.Func {
 	if ui.File == nil {
 		return nil
@@ -342,6 +396,11 @@ func (ui *FileUI) findFunc(name string) disasm.Func {
 			return fn
 		}
 	}
+	for _, data := range disasm.DataSymbols(ui.File) {
+		if data.Name() == name {
+			return data
+		}
+	}
 	return nil
 }
 
@@ -424,9 +483,18 @@ func (ui *FileUI) handleActions(gtx layout.Context) {
 	for ui.BrowseButton.Clicked(gtx) {
 		ui.chooseFile()
 	}
+	for ui.ProfileButton.Clicked(gtx) {
+		ui.chooseProfile()
+	}
 	for ui.SettingsButton.Clicked(gtx) {
 		ui.openSettingsWindow()
 	}
+	if ui.Arch.Update(gtx) {
+		ui.selectArch(ui.Arch.Value)
+	}
+	if ui.Sidebar.Update(gtx) {
+		ui.showSidebar(ui.Sidebar.Value)
+	}
 }
 
 func (ui *FileUI) layoutToolbar(gtx layout.Context, colors gui.UIColors) layout.Dimensions {
@@ -440,14 +508,32 @@ func (ui *FileUI) layoutToolbar(gtx layout.Context, colors gui.UIColors) layout.
 				button.Inset = layout.Inset{Top: 6, Right: 10, Bottom: 6, Left: 10}
 				return layout.Inset{Right: 6}.Layout(gtx, button.Layout)
 			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return ui.layoutProfileButton(gtx)
+			}),
 			layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
 				if ui.Config.Path == "" {
 					return layout.Dimensions{Size: image.Pt(gtx.Constraints.Max.X, 0)}
 				}
-				label := ui.Theme.Muted(ui.Config.Path, 0.8)
+				label := ui.Theme.Muted(ui.pathLabel(), 0.8)
 				label.MaxLines = 1
 				return layout.W.Layout(gtx, label.Layout)
 			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return ui.layoutGraphToggle(gtx, colors)
+			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return ui.layoutDiffToggle(gtx, colors)
+			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return ui.layoutCallersToggle(gtx, colors)
+			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return ui.layoutSizeToggle(gtx, colors)
+			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return ui.layoutArchSelector(gtx, colors)
+			}),
 			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 				button := material.IconButton(ui.Theme.Theme, &ui.SettingsButton, SettingsIcon, "Settings")
 				button.Size = 18
@@ -501,6 +587,81 @@ func (ui *FileUI) layoutSyntaxRadio(gtx layout.Context, colors gui.UIColors, sty
 	return layout.Inset{Left: 2}.Layout(gtx, radio.Layout)
 }
 
+// toolbarToggle draws a switch of the toolbar.
+func (ui *FileUI) toolbarToggle(gtx layout.Context, colors gui.UIColors, value *widget.Bool, label string) layout.Dimensions {
+	check := material.CheckBox(ui.Theme.Theme, value, label)
+	check.Color = colors.MutedText
+	check.IconColor = ui.Theme.ContrastBg
+	check.TextSize = ui.Theme.TextSize * 0.78
+	check.Size = unit.Dp(18)
+	return layout.Inset{Left: 10}.Layout(gtx, check.Layout)
+}
+
+// layoutGraphToggle draws the switch between the assembly and the
+// control-flow graph, it's hidden until a function is open.
+func (ui *FileUI) layoutGraphToggle(gtx layout.Context, colors gui.UIColors) layout.Dimensions {
+	code := ui.activeCode()
+	if code == nil || !code.Loaded() || code.Code.Data != nil {
+		return layout.Dimensions{}
+	}
+	return ui.toolbarToggle(gtx, colors, &ui.ShowGraph, "Graph")
+}
+
+// archSelector returns the selector of the loaded file, nil when it contains
+// a single architecture.
+func (ui *FileUI) archSelector() disasm.ArchSelector {
+	selector, ok := ui.File.(disasm.ArchSelector)
+	if !ok || len(selector.Archs()) < 2 {
+		return nil
+	}
+	return selector
+}
+
+// selectedArch returns the selected architecture of an universal binary.
+func (ui *FileUI) selectedArch() string {
+	if selector := ui.archSelector(); selector != nil {
+		return selector.Arch()
+	}
+	return ""
+}
+
+// selectArch switches the universal binary to arch, the open tabs are
+// reopened with the functions of the new architecture.
+func (ui *FileUI) selectArch(arch string) {
+	if ui.File == nil || arch == ui.selectedArch() {
+		return
+	}
+	ui.Config.Arch = arch
+	ui.SetFile(ui.File)
+}
+
+func (ui *FileUI) layoutArchSelector(gtx layout.Context, colors gui.UIColors) layout.Dimensions {
+	selector := ui.archSelector()
+	if selector == nil {
+		return layout.Dimensions{}
+	}
+
+	children := []layout.FlexChild{
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			label := ui.Theme.Muted("Arch", 0.85)
+			return layout.Inset{Right: 3}.Layout(gtx, label.Layout)
+		}),
+	}
+	for _, arch := range selector.Archs() {
+		children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			radio := material.RadioButton(ui.Theme.Theme, &ui.Arch, arch, arch)
+			radio.Color = colors.MutedText
+			radio.IconColor = ui.Theme.ContrastBg
+			radio.TextSize = ui.Theme.TextSize * 0.78
+			radio.Size = unit.Dp(18)
+			return layout.Inset{Left: 2}.Layout(gtx, radio.Layout)
+		}))
+	}
+	return layout.Inset{Left: 10}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx, children...)
+	})
+}
+
 // scheduleFlush arranges for buffered comment and settings changes to
 // reach disk once the user pauses, instead of on every keystroke or tab
 // switch. Main event loop only.
@@ -539,6 +700,18 @@ func (ui *FileUI) invalidateMain() {
 }
 
 func (ui *FileUI) chooseFile() {
+	ui.pickFile(func(path string) pickerResult {
+		// Reject unsupported files before replacing the open one.
+		if _, err := disasm.Detect(path); err != nil {
+			return pickerResult{err: err}
+		}
+		return pickerResult{path: path, ok: true}
+	})
+}
+
+// pickFile runs the native file picker, open checks the chosen path off the
+// event loop.
+func (ui *FileUI) pickFile(open func(path string) pickerResult) {
 	if ui.pickerOpen || ui.picker == nil || ui.pickerResults == nil {
 		return
 	}
@@ -548,14 +721,15 @@ func (ui *FileUI) chooseFile() {
 	picker, results, exited := ui.picker, ui.pickerResults, ui.exited
 	go func() {
 		var res pickerResult
-		// No extension filter: Go executables usually have none.
+		// No extension filter: Go executables usually have none,
+		// the format is detected from the contents instead.
 		file, err := picker.ChooseFile()
 		switch {
 		case err == nil:
 			// The disassembler needs a path, not a reader; on desktop
 			// platforms the explorer hands back an *os.File.
 			if f, ok := file.(*os.File); ok {
-				res = pickerResult{path: f.Name(), ok: true}
+				res = open(f.Name())
 			} else {
 				res.err = errors.New("file picker did not return a local file path")
 			}
@@ -598,7 +772,16 @@ func (ui *FileUI) layoutContent(gtx layout.Context, colors gui.UIColors) layout.
 	return ui.split.Layout(gtx,
 		func(gtx layout.Context) layout.Dimensions {
 			gtx.Constraints = layout.Exact(gtx.Constraints.Max)
-			return ui.Funcs.Layout(ui.Theme, gtx)
+			paint.FillShape(gtx.Ops, colors.SecondaryBackground, clip.Rect{Max: gtx.Constraints.Max}.Op())
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					return ui.layoutSidebarKind(gtx, colors)
+				}),
+				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+					gtx.Constraints = layout.Exact(gtx.Constraints.Max)
+					return ui.Funcs.Layout(ui.Theme, gtx)
+				}),
+			)
 		},
 		func(gtx layout.Context) layout.Dimensions {
 			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
@@ -624,7 +807,11 @@ func (ui *FileUI) layoutContent(gtx layout.Context, colors gui.UIColors) layout.
 					if code == nil || !code.Loaded() {
 						return layout.Dimensions{}
 					}
-					txt := ui.Theme.Muted("file: "+code.Code.File, 1)
+					label := "file: " + code.Code.File
+					if code.Code.Data != nil {
+						label = dataLabel(code.Code.Data)
+					}
+					txt := ui.Theme.Muted(label, 1)
 					txt.Font.Style = font.Italic
 
 					inset := layout.Inset{Top: 2, Left: 4, Right: 4, Bottom: 4}
@@ -635,20 +822,22 @@ func (ui *FileUI) layoutContent(gtx layout.Context, colors gui.UIColors) layout.
 					if ui.LoadError != nil && ui.File == nil {
 						return layout.Dimensions{}
 					}
+					if ui.ShowSize.Value && ui.File != nil {
+						return ui.layoutSize(gtx, colors)
+					}
 					code := ui.activeCode()
 					if code == nil {
 						return layout.Dimensions{}
 					}
 
 					gtx.Constraints = layout.Exact(gtx.Constraints.Max)
-					return layout.Stack{
-						Alignment: layout.SE,
-					}.Layout(gtx,
-						layout.Expanded(func(gtx layout.Context) layout.Dimensions {
+					return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+						layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
 							return codeview.Style{
 								UI: code,
 
 								TryOpen:    ui.tryOpen,
+								OpenSymbol: ui.openSymbol,
 								OnInteract: ui.keepActiveTab,
 								CopyText: func(gtx layout.Context, text string) {
 									ui.writeClipboardText(gtx, text, "Copied selection")
@@ -663,9 +852,18 @@ func (ui *FileUI) layoutContent(gtx layout.Context, colors gui.UIColors) layout.
 								Syntax:     syntax.PaletteFor(ui.Settings.SyntaxStyle, colors.SyntaxColors()),
 								ShowNative: ui.ShowNativeAsm.Value,
 								ShowHelp:   ui.ShowAsmHelp.Value,
+								ShowGraph:  ui.ShowGraph.Value,
+								ShowDiff:   ui.ShowDiff.Value,
+								Profile:    ui.overlay,
 								TextHeight: ui.Theme.TextSize,
 							}.Layout(gtx)
 						}),
+						layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+							return ui.layoutInlined(gtx, colors)
+						}),
+						layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+							return ui.layoutCallers(gtx, colors)
+						}),
 					)
 				}),
 			)
@@ -692,7 +890,7 @@ func (ui *FileUI) requestLoad(path string) {
 func (ui *FileUI) afterFileLoaded() {
 	ui.saveSessionState()
 	if ui.MCP != nil {
-		ui.MCP.SetPath(ui.Config.Path, ui.Comments)
+		ui.MCP.SetPath(ui.Config.Path, ui.Comments, ui.sourceMap, ui.selectedArch())
 	}
 }
 
@@ -701,7 +899,8 @@ func (ui *FileUI) saveSessionState() {
 		return
 	}
 	path := comments.CleanPath(ui.Config.Path)
-	if path == "" {
+	if path == "" || ui.isBuildOutput() {
+		// The build output can't be reopened without the build flags.
 		return
 	}
 
@@ -734,6 +933,19 @@ func (ui *FileUI) saveSessionState() {
 	ui.scheduleFlush()
 }
 
+// isBuildOutput reports whether the current path is from `lensm build`.
+func (ui *FileUI) isBuildOutput() bool {
+	return ui.Config.Build != nil && ui.Config.Path == ui.Config.Build.Dir
+}
+
+// pathLabel describes the loaded file in the toolbar.
+func (ui *FileUI) pathLabel() string {
+	if ui.isBuildOutput() {
+		return ui.Config.Build.config.String()
+	}
+	return ui.Config.Path
+}
+
 func (ui *FileUI) writeClipboardText(gtx layout.Context, text, status string) {
 	if text == "" {
 		return
@@ -759,15 +971,20 @@ func (ui *FileUI) startMCP() {
 	if ui.MCP != nil {
 		return
 	}
-	server, err := mcp.StartAppServer(loadDisasmFile, ui.Config.CommentsPath)
+	loadFile := loadDisasmFile
+	if ui.Config.Build != nil {
+		loadFile = ui.Config.Build.Open
+	}
+	server, err := mcp.StartAppServer(loadFile, ui.Config.CommentsPath)
 	if err != nil {
 		ui.LoadError = fmt.Errorf("unable to start MCP server: %w", err)
 		ui.invalidateMain()
 		return
 	}
 	ui.MCP = server
+	ui.MCP.SetProfile(ui.profile)
 	if ui.File != nil {
-		ui.MCP.SetPath(ui.Config.Path, ui.Comments)
+		ui.MCP.SetPath(ui.Config.Path, ui.Comments, ui.sourceMap, ui.selectedArch())
 	}
 	fmt.Fprintf(os.Stderr, "lensm MCP server listening at %s\n", server.URL())
 	ui.invalidateMain()
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"gioui.org/widget/material"
+
+	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/gui"
+)
+
+type archTestFunc struct{ name, arch string }
+
+func (fn archTestFunc) Name() string { return fn.name }
+func (fn archTestFunc) Load(disasm.Options) (*disasm.Code, error) {
+	return &disasm.Code{Name: fn.name, Arch: fn.arch}, nil
+}
+
+type archTestFile struct {
+	arches   []string
+	selected string
+	closed   bool
+}
+
+func (file *archTestFile) Close() error { file.closed = true; return nil }
+func (file *archTestFile) Funcs() []disasm.Func {
+	return []disasm.Func{archTestFunc{"main.A", file.selected}, archTestFunc{"main.B", file.selected}}
+}
+func (file *archTestFile) Archs() []string { return file.arches }
+func (file *archTestFile) Arch() string    { return file.selected }
+func (file *archTestFile) SelectArch(arch string) error {
+	if !slices.Contains(file.arches, arch) {
+		return fmt.Errorf("architecture %q not found", arch)
+	}
+	file.selected = arch
+	return nil
+}
+
+func TestFileUISelectArchKeepsTabs(t *testing.T) {
+	theme := gui.NewTheme(material.NewTheme(), false)
+	ui := &FileUI{
+		Theme:     theme,
+		Config:    FileUIConfig{Arch: "arm64"},
+		Funcs:     gui.NewFilterList[disasm.Func](theme),
+		ActiveTab: -1,
+	}
+	ui.Navigation.Reset()
+
+	file := &archTestFile{arches: []string{"amd64", "arm64"}, selected: "amd64"}
+	ui.SetFile(file)
+	if ui.LoadError != nil {
+		t.Fatal(ui.LoadError)
+	}
+	if got := ui.Arch.Value; got != "arm64" {
+		t.Fatalf("selected arch = %q, want arm64", got)
+	}
+
+	ui.openTab(ui.findFunc("main.B"), false)
+	ui.selectArch("amd64")
+	if file.closed {
+		t.Fatal("file closed when switching the architecture")
+	}
+	tab := ui.activeTab()
+	if tab == nil || tab.Name != "main.B" {
+		t.Fatalf("active tab = %v, want main.B", tab)
+	}
+	if fn := ui.Funcs.SelectedItem.(archTestFunc); fn.arch != "amd64" {
+		t.Errorf("selected func arch = %q, want amd64", fn.arch)
+	}
+}
+
+// archCallsTestFile is an universal binary whose call sites depend on the
+// selected architecture.
+type archCallsTestFile struct{ *archTestFile }
+
+func (file archCallsTestFile) IndexCalls() {}
+func (file archCallsTestFile) Callers(name string) ([]disasm.CallSite, error) {
+	return []disasm.CallSite{{Caller: "main.A", Callee: name, File: file.selected}}, nil
+}
+func (file archCallsTestFile) Callees(name string) ([]disasm.CallSite, error) { return nil, nil }
+
+func TestCallersPanelReloadsAfterArchSwitch(t *testing.T) {
+	file := archCallsTestFile{&archTestFile{arches: []string{"amd64", "arm64"}, selected: "amd64"}}
+	var panel callersPanel
+	load := func(arch string) []disasm.CallSite {
+		done := make(chan struct{}, 1)
+		panel.update(file, file, arch, "main.B", func() { done <- struct{}{} })
+		<-done
+		sites, loaded := panel.current("main.B")
+		if !loaded {
+			t.Fatal("callers not loaded")
+		}
+		return sites
+	}
+
+	if sites := load("amd64"); len(sites) != 1 || sites[0].File != "amd64" {
+		t.Fatalf("amd64 callers = %v", sites)
+	}
+	file.selected = "arm64"
+	if sites := load("arm64"); len(sites) != 1 || sites[0].File != "arm64" {
+		t.Errorf("arm64 callers = %v, want the sites of arm64", sites)
+	}
+}
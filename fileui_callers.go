@@ -0,0 +1,179 @@
+package main
+
+import (
+	"image"
+	"path"
+	"strconv"
+	"sync"
+
+	"gioui.org/font"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+
+	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/gui"
+)
+
+// callersPanel lists the calls to the active function. The lookups wait
+// for the call index, so they run in the background.
+type callersPanel struct {
+	// mu guards the lookup results.
+	mu   sync.Mutex
+	file disasm.File
+	// arch is the selected architecture of an universal binary, which
+	// stays the same file after switching.
+	arch   string
+	name   string
+	sites  []disasm.CallSite
+	loaded bool
+
+	list widget.List
+	rows []widget.Clickable
+}
+
+// update starts a lookup when the file, its architecture or the function
+// changes. Main event loop only.
+func (panel *callersPanel) update(index disasm.CallIndex, file disasm.File, arch, name string, invalidate func()) {
+	panel.mu.Lock()
+	defer panel.mu.Unlock()
+	if panel.file == file && panel.arch == arch && panel.name == name {
+		return
+	}
+	panel.file, panel.arch, panel.name, panel.sites, panel.loaded = file, arch, name, nil, false
+
+	go func() {
+		sites, _ := index.Callers(name)
+		panel.mu.Lock()
+		if panel.file == file && panel.arch == arch && panel.name == name {
+			panel.sites, panel.loaded = sites, true
+		}
+		panel.mu.Unlock()
+		invalidate()
+	}()
+}
+
+// current returns the sites for the function and whether the lookup
+// finished.
+func (panel *callersPanel) current(name string) ([]disasm.CallSite, bool) {
+	panel.mu.Lock()
+	defer panel.mu.Unlock()
+	if panel.name != name {
+		return nil, false
+	}
+	return panel.sites, panel.loaded
+}
+
+// callIndex returns the call index of the loaded file and the active
+// function, nil when either is missing.
+func (ui *FileUI) callIndex() (disasm.CallIndex, *disasm.Code) {
+	code := ui.activeCode()
+	if code == nil || !code.Loaded() || code.Code.Data != nil {
+		return nil, nil
+	}
+	index, ok := ui.File.(disasm.CallIndex)
+	if !ok {
+		return nil, nil
+	}
+	return index, code.Code
+}
+
+// layoutCallersToggle draws the switch of the "Callers" panel, it's hidden
+// when the file has no call index.
+func (ui *FileUI) layoutCallersToggle(gtx layout.Context, colors gui.UIColors) layout.Dimensions {
+	if index, _ := ui.callIndex(); index == nil {
+		return layout.Dimensions{}
+	}
+	return ui.toolbarToggle(gtx, colors, &ui.ShowCallers, "Callers")
+}
+
+// layoutCallers draws the "Callers" panel next to the code view when it's
+// enabled.
+func (ui *FileUI) layoutCallers(gtx layout.Context, colors gui.UIColors) layout.Dimensions {
+	index, code := ui.callIndex()
+	if index == nil || !ui.ShowCallers.Value {
+		return layout.Dimensions{}
+	}
+	panel := &ui.callers
+	panel.update(index, ui.File, ui.selectedArch(), code.Name, ui.invalidateMain)
+	sites, loaded := panel.current(code.Name)
+
+	for len(panel.rows) < len(sites) {
+		panel.rows = append(panel.rows, widget.Clickable{})
+	}
+	for i := range sites {
+		for panel.rows[i].Clicked(gtx) {
+			ui.openCallSite(gtx, sites[i])
+		}
+	}
+
+	width := min(gtx.Metric.Dp(280), gtx.Constraints.Max.X/3)
+	gtx.Constraints = layout.Exact(image.Pt(width, gtx.Constraints.Max.Y))
+	paint.FillShape(gtx.Ops, colors.SecondaryBackground, clip.Rect{Max: gtx.Constraints.Max}.Op())
+	paint.FillShape(gtx.Ops, colors.Splitter, clip.Rect{Max: image.Pt(1, gtx.Constraints.Max.Y)}.Op())
+
+	title := "Indexing calls..."
+	if loaded {
+		title = "Called from " + strconv.Itoa(len(sites)) + " calls"
+	}
+
+	panel.list.Axis = layout.Vertical
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			label := ui.Theme.Label(title, 0.85)
+			label.Font.Weight = font.Bold
+			label.MaxLines = 1
+			return layout.Inset{Top: 4, Right: 6, Bottom: 4, Left: 8}.Layout(gtx, label.Layout)
+		}),
+		layout.Rigid(gui.HorizontalLine{Height: 1, Color: colors.Splitter}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return material.List(ui.Theme.Theme, &panel.list).Layout(gtx, len(sites), func(gtx layout.Context, i int) layout.Dimensions {
+				return panel.rows[i].Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					return ui.layoutCallSite(gtx, colors, &panel.rows[i], sites[i])
+				})
+			})
+		}),
+	)
+}
+
+func (ui *FileUI) layoutCallSite(gtx layout.Context, colors gui.UIColors, row *widget.Clickable, site disasm.CallSite) layout.Dimensions {
+	macro := op.Record(gtx.Ops)
+	gtx.Constraints.Min.X = gtx.Constraints.Max.X
+	dims := layout.Inset{Top: 3, Right: 6, Bottom: 3, Left: 8}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				label := ui.Theme.Label(site.Caller, 0.8)
+				label.MaxLines = 1
+				return label.Layout(gtx)
+			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				label := ui.Theme.Muted(path.Base(site.File)+":"+strconv.Itoa(site.Line)+"  "+site.Kind.String(), 0.75)
+				label.MaxLines = 1
+				return label.Layout(gtx)
+			}),
+		)
+	})
+	call := macro.Stop()
+
+	if row.Hovered() {
+		paint.FillShape(gtx.Ops, colors.Selection, clip.Rect{Max: dims.Size}.Op())
+	}
+	call.Add(gtx.Ops)
+	return dims
+}
+
+// openCallSite opens the caller and selects the call.
+func (ui *FileUI) openCallSite(gtx layout.Context, site disasm.CallSite) {
+	fn := ui.findFunc(site.Caller)
+	if fn == nil {
+		return
+	}
+	tab := ui.openTab(fn, true)
+	if tab != nil && tab.Code.Loaded() {
+		tab.Code.SelectPC(site.PC)
+	}
+	gtx.Execute(op.InvalidateCmd{})
+}
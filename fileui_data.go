@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strconv"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+
+	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/gui"
+)
+
+// The sidebar lists either the functions or the data symbols.
+const (
+	sidebarFuncs = "funcs"
+	sidebarData  = "data"
+)
+
+// sidebarItems returns the symbols for the selected kind.
+func (ui *FileUI) sidebarItems() []disasm.Func {
+	if ui.File == nil {
+		return nil
+	}
+	if _, ok := ui.File.(disasm.DataFile); ok && ui.Sidebar.Value == sidebarData {
+		return disasm.DataSymbols(ui.File)
+	}
+	if ui.overlay != nil {
+		return sortByWeight(ui.File.Funcs(), ui.overlay)
+	}
+	return ui.File.Funcs()
+}
+
+// showSidebar switches the sidebar between functions and data symbols.
+func (ui *FileUI) showSidebar(kind string) {
+	ui.Sidebar.Value = kind
+	ui.Funcs.SetItems(ui.sidebarItems())
+	if tab := ui.activeTab(); tab != nil {
+		ui.selectFuncByName(tab.Name)
+	}
+}
+
+// openSymbol opens a referenced function or data symbol and selects the
+// offset in it.
+func (ui *FileUI) openSymbol(gtx layout.Context, name string, offset uint64) {
+	fn := ui.findFunc(name)
+	if fn == nil {
+		return
+	}
+	tab := ui.openTab(fn, true)
+	if tab != nil && tab.Code.Loaded() && len(tab.Code.Insts) > 0 {
+		tab.Code.SelectPC(tab.Code.Insts[0].PC + offset)
+	}
+	gtx.Execute(op.InvalidateCmd{})
+}
+
+// dataLabel describes the data symbol below the tabs.
+func dataLabel(data *disasm.DataSymbol) string {
+	return data.Section + ", " + strconv.FormatUint(data.Size, 10) + " bytes"
+}
+
+// layoutSidebarKind draws the switch between functions and data symbols,
+// it's hidden for files without data symbols.
+func (ui *FileUI) layoutSidebarKind(gtx layout.Context, colors gui.UIColors) layout.Dimensions {
+	if _, ok := ui.File.(disasm.DataFile); !ok {
+		return layout.Dimensions{}
+	}
+	radio := func(kind, label string) layout.FlexChild {
+		return layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			radio := material.RadioButton(ui.Theme.Theme, &ui.Sidebar, kind, label)
+			radio.Color = colors.MutedText
+			radio.IconColor = ui.Theme.ContrastBg
+			radio.TextSize = ui.Theme.TextSize * 0.78
+			radio.Size = unit.Dp(18)
+			return layout.Inset{Right: 6}.Layout(gtx, radio.Layout)
+		})
+	}
+	return layout.Inset{Top: 2, Bottom: 2, Left: 4}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+			radio(sidebarFuncs, "Functions"),
+			radio(sidebarData, "Data"),
+		)
+	})
+}
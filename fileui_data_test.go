@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"gioui.org/widget/material"
+
+	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/gui"
+)
+
+type dataTestFunc struct{ name string }
+
+func (fn dataTestFunc) Name() string { return fn.name }
+func (fn dataTestFunc) Load(disasm.Options) (*disasm.Code, error) {
+	return disasm.DumpData(&disasm.DataSymbol{Name: fn.name, Addr: 0x100, Size: 64, Bytes: make([]byte, 64)}, "amd64"), nil
+}
+
+type dataTestFile struct{}
+
+func (dataTestFile) Close() error { return nil }
+func (dataTestFile) Funcs() []disasm.Func {
+	return []disasm.Func{archTestFunc{name: "main.main"}}
+}
+func (dataTestFile) Data() []disasm.Func {
+	return []disasm.Func{dataTestFunc{"main.table"}}
+}
+
+func TestFileUIDataSymbols(t *testing.T) {
+	theme := gui.NewTheme(material.NewTheme(), false)
+	ui := &FileUI{
+		Theme:     theme,
+		Funcs:     gui.NewFilterList[disasm.Func](theme),
+		ActiveTab: -1,
+	}
+	ui.Sidebar.Value = sidebarFuncs
+	ui.Navigation.Reset()
+	ui.SetFile(dataTestFile{})
+
+	ui.showSidebar(sidebarData)
+	if len(ui.Funcs.All) != 1 || ui.Funcs.All[0].Name() != "main.table" {
+		t.Fatalf("sidebar = %v, want main.table", ui.Funcs.All)
+	}
+	ui.showSidebar(sidebarFuncs)
+	if len(ui.Funcs.All) != 1 || ui.Funcs.All[0].Name() != "main.main" {
+		t.Fatalf("sidebar = %v, want main.main", ui.Funcs.All)
+	}
+
+	// References open the data symbol at the row of the offset.
+	tab := ui.openTab(ui.findFunc("main.table"), false)
+	if tab == nil {
+		t.Fatal("main.table not opened")
+	}
+	tab.Code.SelectPC(tab.Code.Insts[0].PC + 0x18)
+	if got := tab.Code.Insts[tab.Code.SelectedAsm].PC; got != 0x110 {
+		t.Errorf("selected row at %#x, want 0x110", got)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"slices"
+
+	"gioui.org/layout"
+
+	"loov.dev/lensm/internal/asmdiff"
+	"loov.dev/lensm/internal/codeview"
+	"loov.dev/lensm/internal/gui"
+)
+
+// keepBuilds is the number of the earlier builds that are kept for each
+// open tab across the watch-mode reloads.
+const keepBuilds = 8
+
+// tabHistory returns the earlier builds of the open tabs, including the
+// current one, by the name of the function.
+func (ui *FileUI) tabHistory() map[string][]codeview.Snapshot {
+	history := map[string][]codeview.Snapshot{}
+	for _, tab := range ui.CodeTabs {
+		if !tab.Code.Loaded() {
+			continue
+		}
+		builds := append(slices.Clone(tab.Code.History), codeview.Snapshot{
+			Code:   tab.Code.Code,
+			Loaded: ui.loadedAt,
+		})
+		history[tab.Name] = builds[max(len(builds)-keepBuilds, 0):]
+	}
+	return history
+}
+
+// restoreHistory gives the reopened tabs their earlier builds and marks the
+// ones whose instructions changed since the last build.
+func (ui *FileUI) restoreHistory(history map[string][]codeview.Snapshot) {
+	for _, tab := range ui.CodeTabs {
+		builds := history[tab.Name]
+		if len(builds) == 0 || !tab.Code.Loaded() {
+			continue
+		}
+		tab.Code.SetHistory(builds)
+		last := builds[len(builds)-1].Code
+		tab.Changed = asmdiff.Differs(asmdiff.Diff(last, tab.Code.Code))
+	}
+}
+
+// layoutDiffToggle draws the switch of the inline diff against an earlier
+// build, it's hidden until the active tab has one.
+func (ui *FileUI) layoutDiffToggle(gtx layout.Context, colors gui.UIColors) layout.Dimensions {
+	code := ui.activeCode()
+	if code == nil || len(code.History) == 0 {
+		return layout.Dimensions{}
+	}
+	return ui.toolbarToggle(gtx, colors, &ui.ShowDiff, "Diff")
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"gioui.org/widget/material"
+
+	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/gui"
+)
+
+type historyTestFunc struct {
+	name  string
+	texts []string
+}
+
+func (fn historyTestFunc) Name() string { return fn.name }
+func (fn historyTestFunc) Load(disasm.Options) (*disasm.Code, error) {
+	code := &disasm.Code{Name: fn.name}
+	for i, text := range fn.texts {
+		code.Insts = append(code.Insts, disasm.Inst{PC: uint64(i), Text: text})
+	}
+	return code, nil
+}
+
+type historyTestFile struct{ funcs []disasm.Func }
+
+func (*historyTestFile) Close() error              { return nil }
+func (file *historyTestFile) Funcs() []disasm.Func { return file.funcs }
+
+func TestFileUIKeepsHistoryAcrossReloads(t *testing.T) {
+	theme := gui.NewTheme(material.NewTheme(), false)
+	ui := &FileUI{
+		Theme:     theme,
+		Funcs:     gui.NewFilterList[disasm.Func](theme),
+		ActiveTab: -1,
+	}
+	ui.Navigation.Reset()
+
+	build := func(a string) *historyTestFile {
+		return &historyTestFile{[]disasm.Func{
+			historyTestFunc{"main.A", []string{a, "RET"}},
+			historyTestFunc{"main.B", []string{"XORL AX, AX", "RET"}},
+		}}
+	}
+	ui.SetFile(build("MOVL $0x7, AX"))
+	ui.openTab(ui.findFunc("main.A"), false)
+	ui.openTab(ui.findFunc("main.B"), false)
+	first := ui.CodeTabs[0].Code.Code
+
+	ui.SetFile(build("MOVL $0x8, AX"))
+	a, b := ui.CodeTabs[0], ui.CodeTabs[1]
+	if !a.Changed || b.Changed {
+		t.Errorf("changed = %v, %v, want true, false", a.Changed, b.Changed)
+	}
+	if len(a.Code.History) != 1 || a.Code.History[0].Code != first {
+		t.Fatalf("history = %v, want the first build", a.Code.History)
+	}
+
+	for range keepBuilds + 2 {
+		ui.SetFile(build("MOVL $0x8, AX"))
+	}
+	a = ui.CodeTabs[0]
+	if a.Changed {
+		t.Error("unchanged reload marked as changed")
+	}
+	if len(a.Code.History) != keepBuilds || a.Code.Compare != keepBuilds-1 {
+		t.Errorf("history = %d builds comparing %d, want %d comparing the last", len(a.Code.History), a.Code.Compare, keepBuilds)
+	}
+}
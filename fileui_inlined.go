@@ -0,0 +1,165 @@
+package main
+
+import (
+	"image"
+	"path"
+	"strconv"
+	"sync"
+
+	"gioui.org/font"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+
+	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/gui"
+)
+
+// inlinedPanel lists where the active function was inlined. The first
+// lookup indexes the whole binary, so lookups run in the background.
+type inlinedPanel struct {
+	// mu guards the lookup results.
+	mu    sync.Mutex
+	file  disasm.File
+	name  string
+	sites []disasm.InlineSite
+
+	list widget.List
+	rows []widget.Clickable
+}
+
+// update starts a lookup when the file or the function changes.
+// Main event loop only.
+func (panel *inlinedPanel) update(file disasm.File, name string, invalidate func()) {
+	panel.mu.Lock()
+	defer panel.mu.Unlock()
+	if panel.file == file && panel.name == name {
+		return
+	}
+	panel.file, panel.name, panel.sites = file, name, nil
+
+	index, ok := file.(disasm.InlineIndex)
+	if !ok || name == "" {
+		return
+	}
+	go func() {
+		// Functions that were never inlined, or files without inline
+		// information, don't show the panel.
+		sites, _ := index.InlinedInto(name)
+		panel.mu.Lock()
+		if panel.file == file && panel.name == name {
+			panel.sites = sites
+		}
+		panel.mu.Unlock()
+		invalidate()
+	}()
+}
+
+// current returns the sites for the function.
+func (panel *inlinedPanel) current(name string) []disasm.InlineSite {
+	panel.mu.Lock()
+	defer panel.mu.Unlock()
+	if panel.name != name {
+		return nil
+	}
+	return panel.sites
+}
+
+// layoutInlined draws the "Inlined into" panel next to the code view, it
+// takes no space when the function wasn't inlined anywhere.
+func (ui *FileUI) layoutInlined(gtx layout.Context, colors gui.UIColors) layout.Dimensions {
+	code := ui.activeCode()
+	if code == nil || !code.Loaded() || ui.File == nil {
+		return layout.Dimensions{}
+	}
+	ui.inlined.update(ui.File, code.Name, ui.invalidateMain)
+	sites := ui.inlined.current(code.Name)
+	if len(sites) == 0 {
+		return layout.Dimensions{}
+	}
+
+	panel := &ui.inlined
+	for len(panel.rows) < len(sites) {
+		panel.rows = append(panel.rows, widget.Clickable{})
+	}
+	for i := range sites {
+		for panel.rows[i].Clicked(gtx) {
+			ui.openInlineSite(gtx, sites[i])
+		}
+	}
+
+	width := min(gtx.Metric.Dp(280), gtx.Constraints.Max.X/3)
+	gtx.Constraints = layout.Exact(image.Pt(width, gtx.Constraints.Max.Y))
+	paint.FillShape(gtx.Ops, colors.SecondaryBackground, clip.Rect{Max: gtx.Constraints.Max}.Op())
+	paint.FillShape(gtx.Ops, colors.Splitter, clip.Rect{Max: image.Pt(1, gtx.Constraints.Max.Y)}.Op())
+
+	var total uint64
+	for _, site := range sites {
+		total += site.Size
+	}
+
+	panel.list.Axis = layout.Vertical
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			label := ui.Theme.Label("Inlined into "+strconv.Itoa(len(sites))+" calls, "+formatBytes(total), 0.85)
+			label.Font.Weight = font.Bold
+			label.MaxLines = 1
+			return layout.Inset{Top: 4, Right: 6, Bottom: 4, Left: 8}.Layout(gtx, label.Layout)
+		}),
+		layout.Rigid(gui.HorizontalLine{Height: 1, Color: colors.Splitter}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return material.List(ui.Theme.Theme, &panel.list).Layout(gtx, len(sites), func(gtx layout.Context, i int) layout.Dimensions {
+				return panel.rows[i].Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					return ui.layoutInlineSite(gtx, colors, &panel.rows[i], sites[i])
+				})
+			})
+		}),
+	)
+}
+
+func (ui *FileUI) layoutInlineSite(gtx layout.Context, colors gui.UIColors, row *widget.Clickable, site disasm.InlineSite) layout.Dimensions {
+	macro := op.Record(gtx.Ops)
+	gtx.Constraints.Min.X = gtx.Constraints.Max.X
+	dims := layout.Inset{Top: 3, Right: 6, Bottom: 3, Left: 8}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				label := ui.Theme.Label(site.Caller, 0.8)
+				label.MaxLines = 1
+				return label.Layout(gtx)
+			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				label := ui.Theme.Muted(path.Base(site.File)+":"+strconv.Itoa(site.Line)+"  "+formatBytes(site.Size), 0.75)
+				label.MaxLines = 1
+				return label.Layout(gtx)
+			}),
+		)
+	})
+	call := macro.Stop()
+
+	if row.Hovered() {
+		paint.FillShape(gtx.Ops, colors.Selection, clip.Rect{Max: dims.Size}.Op())
+	}
+	call.Add(gtx.Ops)
+	return dims
+}
+
+// openInlineSite opens the caller and selects the inlined code.
+func (ui *FileUI) openInlineSite(gtx layout.Context, site disasm.InlineSite) {
+	fn := ui.findFunc(site.Caller)
+	if fn == nil {
+		return
+	}
+	tab := ui.openTab(fn, true)
+	if tab != nil && tab.Code.Loaded() && len(site.Ranges) > 0 {
+		tab.Code.SelectPC(site.Ranges[0].Start)
+	}
+	gtx.Execute(op.InvalidateCmd{})
+}
+
+// formatBytes formats a size for display.
+func formatBytes(n uint64) string {
+	return strconv.FormatUint(n, 10) + " B"
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/widget/material"
+
+	"loov.dev/lensm/internal/codeview"
+	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/profile"
+)
+
+// setProfile shows the profile over the loaded file and the ones loaded
+// afterwards.
+func (ui *FileUI) setProfile(path string, p *profile.Profile) {
+	ui.profile, ui.profilePath = p, path
+	ui.attachProfile()
+	if ui.File != nil {
+		ui.Funcs.SetItems(ui.sidebarItems())
+		if tab := ui.activeTab(); tab != nil {
+			ui.selectFuncByName(tab.Name)
+		}
+	}
+	if ui.MCP != nil {
+		ui.MCP.SetProfile(p)
+	}
+}
+
+// attachProfile attributes the profile to the functions of the current
+// file, the sidebar then lists them by their weight.
+func (ui *FileUI) attachProfile() {
+	ui.overlay = nil
+	ui.Funcs.Label = nil
+	if ui.profile == nil || ui.File == nil {
+		return
+	}
+	overlay := profile.NewOverlay(ui.profile, ui.File)
+	ui.overlay = overlay
+	ui.Funcs.Label = func(fn disasm.Func) string {
+		w, ok := overlay.Funcs[fn.Name()]
+		if !ok {
+			return fn.Name()
+		}
+		return codeview.FormatShare(overlay, w.Flat) + " / " + codeview.FormatShare(overlay, w.Cum) + "  " + fn.Name()
+	}
+}
+
+// sortByWeight orders the functions by their flat and then cumulative
+// weight, the ones without samples keep their order at the end.
+func sortByWeight(funcs []disasm.Func, overlay *profile.Overlay) []disasm.Func {
+	funcs = slices.Clone(funcs)
+	slices.SortStableFunc(funcs, func(a, b disasm.Func) int {
+		wa, wb := overlay.Funcs[a.Name()], overlay.Funcs[b.Name()]
+		return cmp.Or(cmp.Compare(wb.Flat, wa.Flat), cmp.Compare(wb.Cum, wa.Cum))
+	})
+	return funcs
+}
+
+// chooseProfile loads a profile with the file picker.
+func (ui *FileUI) chooseProfile() {
+	ui.pickFile(func(path string) pickerResult {
+		p, err := profile.Load(path)
+		if err != nil {
+			return pickerResult{err: err}
+		}
+		return pickerResult{path: path, profile: p}
+	})
+}
+
+// profileSummary describes the loaded profile for the toolbar.
+func profileSummary(overlay *profile.Overlay) string {
+	total := strconv.FormatInt(overlay.Total, 10) + " " + overlay.Type.Unit
+	if overlay.Type.Unit == "nanoseconds" {
+		total = time.Duration(overlay.Total).Round(time.Millisecond).String()
+	}
+	return overlay.Type.Type + " " + total
+}
+
+// layoutProfileButton draws the button that loads a profile followed by
+// the summary of the loaded one.
+func (ui *FileUI) layoutProfileButton(gtx layout.Context) layout.Dimensions {
+	return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			button := material.Button(ui.Theme.Theme, &ui.ProfileButton, "Profile...")
+			button.Inset = layout.Inset{Top: 6, Right: 10, Bottom: 6, Left: 10}
+			return layout.Inset{Right: 6}.Layout(gtx, button.Layout)
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if ui.overlay == nil {
+				return layout.Dimensions{}
+			}
+			// Most of the samples outside of the functions means that the
+			// profile is from another build.
+			if ui.overlay.Matched*2 < ui.overlay.Total {
+				label := ui.Theme.ErrorLabel("profile doesn't match the executable", 0.8)
+				label.MaxLines = 1
+				return layout.Inset{Right: 6}.Layout(gtx, label.Layout)
+			}
+			label := ui.Theme.Muted(profileSummary(ui.overlay), 0.8)
+			label.MaxLines = 1
+			return layout.Inset{Right: 6}.Layout(gtx, label.Layout)
+		}),
+	)
+}
@@ -24,7 +24,7 @@ func (ui *FileUI) openSettingsWindow() {
 	}
 	ui.settingsWindowOpen = true
 	events, acks, exited := ui.settingsEvents, ui.settingsAcks, ui.exited
-	ui.Windows.Open("lensm settings", image.Pt(520, 360), func(w *app.Window) error {
+	ui.Windows.Open("lensm settings", image.Pt(520, 440), func(w *app.Window) error {
 		// Only pump events here: the settings window is laid out on the
 		// main event loop, because layout reads and mutates state shared
 		// with the main window (Settings, MCP, Config, widget state) and
@@ -87,6 +87,13 @@ func (ui *FileUI) layoutSettingsWindow(gtx layout.Context) layout.Dimensions {
 					}),
 				})
 			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return layout.Inset{Top: 14}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					return ui.layoutSettingsSection(gtx, "Source paths", []layout.FlexChild{
+						layout.Rigid(ui.layoutSourceRules),
+					})
+				})
+			}),
 			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 				return layout.Inset{Top: 14}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
 					return ui.layoutSettingsSection(gtx, "MCP", []layout.FlexChild{
@@ -177,6 +184,8 @@ func (ui *FileUI) handleSettingsActions(gtx layout.Context) {
 		ui.invalidateMain()
 	}
 
+	ui.handleSourceRulesActions(gtx)
+
 	for ui.StartMCP.Clicked(gtx) {
 		ui.startMCP()
 		gtx.Execute(op.InvalidateCmd{})
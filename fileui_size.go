@@ -0,0 +1,364 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gioui.org/font"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/text"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+
+	"loov.dev/lensm/internal/binsize"
+	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/gui"
+)
+
+// sizePanel shows where the bytes of the loaded file go. Attributing the
+// pclntab reads the whole table, so the report is made in the background.
+type sizePanel struct {
+	// mu guards the report.
+	mu     sync.Mutex
+	file   disasm.File
+	report *binsize.Report
+	err    error
+
+	// previous is the report of the build before a watch-mode reload.
+	previous   *binsize.Report
+	previousAt time.Time
+	path, arch string
+	loadedAt   time.Time
+
+	By      widget.Enum
+	sort    binsize.Key
+	columns [binsize.Name + 1]widget.Clickable
+
+	// entries are the sorted entries of the grouping, with the change
+	// since the previous report.
+	entries []sizeRow
+	sorted  struct {
+		report *binsize.Report
+		by     string
+		sort   binsize.Key
+	}
+
+	list widget.List
+	rows []widget.Clickable
+}
+
+// sizeRow is an entry of the report with its change since the previous
+// build.
+type sizeRow struct {
+	*binsize.Entry
+	Change int64
+}
+
+// update starts a report when the file changes. A reload of the same
+// path keeps the last report for the comparison.
+// Main event loop only.
+func (panel *sizePanel) update(file disasm.File, path, arch string, loadedAt time.Time, invalidate func()) {
+	panel.mu.Lock()
+	defer panel.mu.Unlock()
+	if panel.file == file {
+		return
+	}
+	switch {
+	case panel.report != nil && panel.path == path && panel.arch == arch:
+		panel.previous, panel.previousAt = panel.report, panel.loadedAt
+	case panel.path != path || panel.arch != arch:
+		panel.previous = nil
+	}
+	panel.file, panel.report, panel.err = file, nil, nil
+	panel.path, panel.arch, panel.loadedAt = path, arch, loadedAt
+
+	go func() {
+		sizes, err := disasm.SymbolSizes(file)
+		var report *binsize.Report
+		if err == nil {
+			report = binsize.New(sizes)
+		}
+		panel.mu.Lock()
+		if panel.file == file {
+			panel.report, panel.err = report, err
+		}
+		panel.mu.Unlock()
+		invalidate()
+	}()
+}
+
+// current returns the report and the previous one, nil while it's being
+// made.
+func (panel *sizePanel) current() (report, previous *binsize.Report, err error) {
+	panel.mu.Lock()
+	defer panel.mu.Unlock()
+	return panel.report, panel.previous, panel.err
+}
+
+// sortEntries updates the listed entries when the report, the grouping or
+// the sort column changes.
+func (panel *sizePanel) sortEntries(report, previous *binsize.Report) {
+	if panel.sorted.report == report && panel.sorted.by == panel.By.Value && panel.sorted.sort == panel.sort {
+		return
+	}
+	panel.sorted.report, panel.sorted.by, panel.sorted.sort = report, panel.By.Value, panel.sort
+
+	by, _ := binsize.ParseBy(panel.By.Value)
+	entries := report.Entries(by)
+	binsize.Sort(entries, panel.sort)
+
+	var before map[string]*binsize.Entry
+	if previous != nil {
+		before = map[string]*binsize.Entry{}
+		for _, e := range previous.Entries(by) {
+			before[e.Name] = e
+		}
+	}
+	panel.entries = panel.entries[:0]
+	for _, e := range entries {
+		row := sizeRow{Entry: e}
+		if before != nil {
+			old := binsize.Entry{}
+			if e, ok := before[e.Name]; ok {
+				old = *e
+			}
+			row.Change = (&binsize.Delta{Old: old, New: *e}).Change(binsize.Total)
+		}
+		panel.entries = append(panel.entries, row)
+	}
+}
+
+// layoutSizeToggle draws the switch of the size report, it's hidden until
+// a file is loaded.
+func (ui *FileUI) layoutSizeToggle(gtx layout.Context, colors gui.UIColors) layout.Dimensions {
+	if ui.File == nil {
+		return layout.Dimensions{}
+	}
+	return ui.toolbarToggle(gtx, colors, &ui.ShowSize, "Size")
+}
+
+// sizeColumns are the numeric columns of the size report.
+var sizeColumns = []struct {
+	key   binsize.Key
+	title string
+}{
+	{binsize.Total, "Total"},
+	{binsize.Text, "Text"},
+	{binsize.ROData, "Rodata"},
+	{binsize.Data, "Data"},
+	{binsize.PCLN, "Pclntab"},
+}
+
+// layoutSize draws the size report in place of the code view.
+func (ui *FileUI) layoutSize(gtx layout.Context, colors gui.UIColors) layout.Dimensions {
+	panel := &ui.size
+	if panel.By.Value == "" {
+		panel.By.Value = binsize.ByPackage.String()
+	}
+	panel.update(ui.File, ui.loadedPath, ui.selectedArch(), ui.loadedAt, ui.invalidateMain)
+	report, previous, err := panel.current()
+
+	gtx.Constraints = layout.Exact(gtx.Constraints.Max)
+	paint.FillShape(gtx.Ops, colors.Background, clip.Rect{Max: gtx.Constraints.Max}.Op())
+	switch {
+	case err != nil:
+		return layout.UniformInset(6).Layout(gtx, ui.Theme.ErrorLabel(err.Error(), 1).Layout)
+	case report == nil:
+		return layout.UniformInset(6).Layout(gtx, ui.Theme.Muted("Attributing the size...", 0.85).Layout)
+	}
+
+	for i := range panel.columns {
+		for panel.columns[i].Clicked(gtx) {
+			panel.sort = binsize.Key(i)
+		}
+	}
+	panel.sortEntries(report, previous)
+	for len(panel.rows) < len(panel.entries) {
+		panel.rows = append(panel.rows, widget.Clickable{})
+	}
+	for i := range panel.entries {
+		for panel.rows[i].Clicked(gtx) {
+			ui.openSizeEntry(gtx, panel.entries[i].Name)
+		}
+	}
+
+	panel.list.Axis = layout.Vertical
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return ui.layoutSizeSummary(gtx, colors, report, previous)
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return ui.layoutSizeHeader(gtx, colors, previous != nil)
+		}),
+		layout.Rigid(gui.HorizontalLine{Height: 1, Color: colors.Splitter}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			total := report.Total.Size(binsize.Total)
+			return material.List(ui.Theme.Theme, &panel.list).Layout(gtx, len(panel.entries), func(gtx layout.Context, i int) layout.Dimensions {
+				return panel.rows[i].Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					return ui.layoutSizeRow(gtx, colors, &panel.rows[i], panel.entries[i], total, previous != nil)
+				})
+			})
+		}),
+	)
+}
+
+// layoutSizeSummary draws the grouping and the totals.
+func (ui *FileUI) layoutSizeSummary(gtx layout.Context, colors gui.UIColors, report, previous *binsize.Report) layout.Dimensions {
+	radio := func(by binsize.By, label string) layout.FlexChild {
+		return layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			radio := material.RadioButton(ui.Theme.Theme, &ui.size.By, by.String(), label)
+			radio.Color = colors.MutedText
+			radio.IconColor = ui.Theme.ContrastBg
+			radio.TextSize = ui.Theme.TextSize * 0.78
+			radio.Size = unit.Dp(18)
+			return layout.Inset{Right: 6}.Layout(gtx, radio.Layout)
+		})
+	}
+
+	total := &report.Total
+	summary := humanBytes(total.Size(binsize.Total)) + " in " + strconv.Itoa(total.Count) + " symbols"
+	if previous != nil {
+		delta := &binsize.Delta{Old: previous.Total, New: report.Total}
+		summary += ", " + humanChange(delta.Change(binsize.Total)) +
+			" since the build loaded at " + ui.size.previousAt.Format(time.TimeOnly)
+	}
+
+	return layout.Inset{Top: 2, Right: 6, Bottom: 2, Left: 4}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+			radio(binsize.ByPackage, "Packages"),
+			radio(binsize.BySymbol, "Symbols"),
+			radio(binsize.ByGeneric, "Generics"),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				label := ui.Theme.Muted(summary, 0.8)
+				label.MaxLines = 1
+				return layout.Inset{Left: 6}.Layout(gtx, label.Layout)
+			}),
+		)
+	})
+}
+
+// sizeCellWidth is the width of a numeric column.
+const sizeCellWidth = unit.Dp(72)
+
+// layoutSizeHeader draws the column titles, clicking one sorts by it.
+func (ui *FileUI) layoutSizeHeader(gtx layout.Context, colors gui.UIColors, compare bool) layout.Dimensions {
+	panel := &ui.size
+	title := func(key binsize.Key, name string) string {
+		if panel.sort == key {
+			return name + " ▾"
+		}
+		return name
+	}
+	header := func(key binsize.Key, name string, alignment text.Alignment) layout.Widget {
+		return func(gtx layout.Context) layout.Dimensions {
+			return panel.columns[key].Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				label := ui.Theme.Label(title(key, name), 0.8)
+				label.Font.Weight = font.Bold
+				label.Alignment = alignment
+				label.MaxLines = 1
+				return label.Layout(gtx)
+			})
+		}
+	}
+
+	var cells []layout.FlexChild
+	for _, column := range sizeColumns {
+		cells = append(cells, ui.sizeCell(header(column.key, column.title, text.End)))
+		if column.key == binsize.Total {
+			cells = append(cells, ui.sizeCell(ui.sizeText("%", colors, false)))
+		}
+	}
+	if compare {
+		cells = append(cells, ui.sizeCell(ui.sizeText("Change", colors, false)))
+	}
+	cells = append(cells, layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+		return layout.Inset{Left: 12}.Layout(gtx, header(binsize.Name, "Name", text.Start))
+	}))
+	return layout.Inset{Top: 3, Right: 6, Bottom: 3, Left: 8}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Axis: layout.Horizontal}.Layout(gtx, cells...)
+	})
+}
+
+// layoutSizeRow draws an entry of the report.
+func (ui *FileUI) layoutSizeRow(gtx layout.Context, colors gui.UIColors, row *widget.Clickable, entry sizeRow, total uint64, compare bool) layout.Dimensions {
+	var cells []layout.FlexChild
+	for _, column := range sizeColumns {
+		size := entry.Size(column.key)
+		cells = append(cells, ui.sizeCell(ui.sizeText(humanBytes(size), colors, size == 0)))
+		if column.key == binsize.Total {
+			cells = append(cells, ui.sizeCell(ui.sizeText(formatPercent(size, total), colors, true)))
+		}
+	}
+	if compare {
+		cells = append(cells, ui.sizeCell(ui.sizeText(humanChange(entry.Change), colors, entry.Change == 0)))
+	}
+	cells = append(cells, layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+		label := ui.Theme.Label(entry.Name, 0.8)
+		label.MaxLines = 1
+		return layout.Inset{Left: 12}.Layout(gtx, label.Layout)
+	}))
+
+	macro := op.Record(gtx.Ops)
+	gtx.Constraints.Min.X = gtx.Constraints.Max.X
+	dims := layout.Inset{Top: 2, Right: 6, Bottom: 2, Left: 8}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Axis: layout.Horizontal}.Layout(gtx, cells...)
+	})
+	call := macro.Stop()
+
+	if row.Hovered() {
+		paint.FillShape(gtx.Ops, colors.Selection, clip.Rect{Max: dims.Size}.Op())
+	}
+	call.Add(gtx.Ops)
+	return dims
+}
+
+// sizeCell lays out w as a numeric column.
+func (ui *FileUI) sizeCell(w layout.Widget) layout.FlexChild {
+	return layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+		width := gtx.Metric.Dp(sizeCellWidth)
+		gtx.Constraints.Min.X, gtx.Constraints.Max.X = width, width
+		return w(gtx)
+	})
+}
+
+// sizeText is a right-aligned number, muted when it's zero or secondary.
+func (ui *FileUI) sizeText(s string, colors gui.UIColors, muted bool) layout.Widget {
+	return func(gtx layout.Context) layout.Dimensions {
+		label := ui.Theme.Label(s, 0.8)
+		if muted {
+			label.Color = colors.MutedText
+		}
+		label.Alignment = text.End
+		label.MaxLines = 1
+		return label.Layout(gtx)
+	}
+}
+
+// openSizeEntry opens the symbol of the entry, or filters the sidebar to
+// the functions of a package or the instantiations of a generic function.
+func (ui *FileUI) openSizeEntry(gtx layout.Context, name string) {
+	switch ui.size.By.Value {
+	case binsize.BySymbol.String():
+		if ui.findFunc(name) == nil {
+			return
+		}
+		ui.openSymbol(gtx, name, 0)
+	case binsize.ByGeneric.String():
+		parts := strings.Split(name, "[...]")
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		ui.Funcs.SetFilter("^" + strings.Join(parts, `\[.*\]`) + "$")
+	default:
+		ui.Funcs.SetFilter("^" + regexp.QuoteMeta(name) + `\.`)
+	}
+	ui.ShowSize.Value = false
+	gtx.Execute(op.InvalidateCmd{})
+}
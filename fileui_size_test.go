@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"loov.dev/lensm/internal/binsize"
+	"loov.dev/lensm/internal/disasm"
+)
+
+type sizeTestFile struct{ sizes []disasm.SymbolSize }
+
+func (*sizeTestFile) Close() error                                   { return nil }
+func (*sizeTestFile) Funcs() []disasm.Func                           { return nil }
+func (file *sizeTestFile) SymbolSizes() ([]disasm.SymbolSize, error) { return file.sizes, nil }
+
+func TestSizePanelComparesReloads(t *testing.T) {
+	build := func(mainSize uint64) *sizeTestFile {
+		return &sizeTestFile{[]disasm.SymbolSize{
+			{Name: "main.main", Kind: disasm.InText, Size: mainSize},
+			{Name: "fmt.Println", Kind: disasm.InText, Size: 200},
+			{Name: "fmt.Println", Kind: disasm.InPCLN, Size: 20},
+		}}
+	}
+
+	var panel sizePanel
+	panel.By.Value = binsize.ByPackage.String()
+	loaded := make(chan struct{}, 1)
+	invalidate := func() { loaded <- struct{}{} }
+	load := func(file disasm.File, path string) (report, previous *binsize.Report) {
+		panel.update(file, path, "", time.Now(), invalidate)
+		<-loaded
+		report, previous, err := panel.current()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return report, previous
+	}
+
+	report, previous := load(build(100), "a.exe")
+	if previous != nil || report.Total.Size(binsize.Total) != 320 {
+		t.Fatalf("first load = %v, %v", report.Total, previous)
+	}
+
+	report, previous = load(build(150), "a.exe")
+	if previous == nil {
+		t.Fatal("reload didn't keep the previous report")
+	}
+	panel.sortEntries(report, previous)
+	changes := map[string]int64{}
+	for _, row := range panel.entries {
+		changes[row.Name] = row.Change
+	}
+	if changes["main"] != 50 || changes["fmt"] != 0 {
+		t.Errorf("changes = %v, want main +50 and fmt unchanged", changes)
+	}
+
+	_, previous = load(build(150), "b.exe")
+	if previous != nil {
+		t.Error("another file compared to the previous one")
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"maps"
+	"strings"
+
+	"gioui.org/layout"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+
+	"loov.dev/lensm/internal/comments"
+	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/gui"
+)
+
+// sourceRulesSeparator separates the rules in the settings editor;
+// paths may contain spaces, but rarely semicolons.
+const sourceRulesSeparator = ";"
+
+// updateSourceMap combines the command-line rules with the rules saved
+// for the current binary.
+func (ui *FileUI) updateSourceMap() {
+	saved := ui.Settings.SourceMaps[comments.CleanPath(ui.Config.Path)]
+
+	rules := append([]disasm.PathRule(nil), ui.Config.SourceRules...)
+	for _, s := range saved {
+		if rule, err := disasm.ParsePathRule(s); err == nil {
+			rules = append(rules, rule)
+		}
+	}
+	ui.sourceMap = &disasm.SourceMap{Rules: rules}
+
+	ui.SourceRules.SetText(strings.Join(saved, sourceRulesSeparator+" "))
+	ui.sourceRulesError = ""
+}
+
+// applySourceRules saves the rules from the settings editor for the
+// current binary and reloads it, so the sources are resolved again.
+func (ui *FileUI) applySourceRules() {
+	path := comments.CleanPath(ui.Config.Path)
+	if path == "" {
+		return
+	}
+
+	var saved []string
+	for _, s := range strings.Split(ui.SourceRules.Text(), sourceRulesSeparator) {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		rule, err := disasm.ParsePathRule(s)
+		if err != nil {
+			ui.sourceRulesError = err.Error()
+			return
+		}
+		saved = append(saved, rule.String())
+	}
+	ui.sourceRulesError = ""
+
+	settings := ui.Settings
+	settings.SourceMaps = maps.Clone(settings.SourceMaps)
+	if settings.SourceMaps == nil {
+		settings.SourceMaps = map[string][]string{}
+	}
+	if len(saved) == 0 {
+		delete(settings.SourceMaps, path)
+	} else {
+		settings.SourceMaps[path] = saved
+	}
+	ui.saveSettings(settings)
+	ui.requestLoad(ui.Config.Path)
+}
+
+func (ui *FileUI) handleSourceRulesActions(gtx layout.Context) {
+	for {
+		ev, ok := ui.SourceRules.Update(gtx)
+		if !ok {
+			break
+		}
+		if _, ok := ev.(widget.SubmitEvent); ok {
+			ui.applySourceRules()
+			ui.invalidateMain()
+		}
+	}
+}
+
+func (ui *FileUI) layoutSourceRules(gtx layout.Context) layout.Dimensions {
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return gui.FocusBorder(ui.Theme.Theme, gtx.Focused(&ui.SourceRules)).Layout(gtx,
+				material.Editor(ui.Theme.Theme, &ui.SourceRules, "/src=/home/me/project; ...").Layout)
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			text := "Rewrite source path prefixes for this binary, press Enter to apply."
+			label := ui.Theme.Muted(text, 0.8)
+			if ui.sourceRulesError != "" {
+				label = ui.Theme.ErrorLabel(ui.sourceRulesError, 0.8)
+			}
+			return layout.Inset{Top: 4}.Layout(gtx, label.Layout)
+		}),
+	)
+}
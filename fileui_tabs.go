@@ -20,6 +20,8 @@ type CodeTab struct {
 	Func    disasm.Func
 	Code    codeview.UI
 	Preview bool
+	// Changed marks the code that changed in the last watch-mode reload.
+	Changed bool
 	Tab     widget.Clickable
 	Close   widget.Clickable
 }
@@ -43,6 +45,7 @@ func (ui *FileUI) loadTabCode(tab *CodeTab, fn disasm.Func) {
 	tab.Name = fn.Name()
 	tab.Func = fn
 	tab.Code = codeview.UI{}
+	tab.Changed = false
 	tab.Code.Code, ui.LoadError = fn.Load(ui.loadOptions())
 	tab.Code.SelectedAsm = -1
 	tab.Code.SelectedView = codeview.ViewGoAsm
@@ -270,7 +273,19 @@ func (ui *FileUI) layoutCodeTab(gtx layout.Context, colors gui.UIColors, tab *Co
 					if tab.Preview {
 						label.Font.Style = font.Italic
 					}
-					dims := layout.W.Layout(gtx, label.Layout)
+					dims := layout.W.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+						return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Baseline}.Layout(gtx,
+							layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+								if !tab.Changed {
+									return layout.Dimensions{}
+								}
+								badge := ui.Theme.Label("● ", 0.8)
+								badge.Color = ui.Theme.ContrastBg
+								return badge.Layout(gtx)
+							}),
+							layout.Flexed(1, label.Layout),
+						)
+					})
 					return layout.Dimensions{Size: size, Baseline: dims.Baseline}
 				})
 			}),
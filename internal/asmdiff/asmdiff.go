@@ -0,0 +1,193 @@
+// Package asmdiff compares two builds of a binary function by function and
+// diffs the instructions of a function, ignoring the addresses that move
+// between the builds.
+package asmdiff
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+
+	"loov.dev/lensm/internal/disasm"
+)
+
+// Status is the change of a function between the builds.
+type Status uint8
+
+const (
+	// Same has the same size, the instructions may still differ.
+	Same Status = iota
+	// Added is only in the new build.
+	Added
+	// Removed is only in the old build.
+	Removed
+	// Grown is larger in the new build.
+	Grown
+	// Shrunk is smaller in the new build.
+	Shrunk
+	// Changed has the same size but different instructions.
+	Changed
+)
+
+// String returns the name of the status, e.g. "grown".
+func (status Status) String() string {
+	switch status {
+	case Same:
+		return "same"
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Grown:
+		return "grown"
+	case Shrunk:
+		return "shrunk"
+	case Changed:
+		return "changed"
+	default:
+		return "unknown"
+	}
+}
+
+// Func is a function in either or both builds.
+type Func struct {
+	// Old and New are nil when the function is missing in the build.
+	Old, New disasm.Func
+	// OldSize and NewSize are the sizes of the code in bytes, zero when
+	// it's missing or unknown.
+	OldSize, NewSize uint64
+	Status           Status
+}
+
+// Name returns the name of the function.
+func (fn *Func) Name() string {
+	if fn.New != nil {
+		return fn.New.Name()
+	}
+	return fn.Old.Name()
+}
+
+// Delta returns the growth in bytes, negative when it shrunk.
+func (fn *Func) Delta() int64 { return int64(fn.NewSize) - int64(fn.OldSize) }
+
+// Compare matches the functions of the builds by name, in the order of
+// the new build followed by the removed functions. Functions that don't
+// implement disasm.Sizer are Same unless they were added or removed.
+func Compare(before, after []disasm.Func) []*Func {
+	// Static functions in C may share the name, the n-th one is matched
+	// with the n-th one.
+	type key struct {
+		name string
+		n    int
+	}
+	keys := func(funcs []disasm.Func) []key {
+		seen := map[string]int{}
+		keys := make([]key, len(funcs))
+		for i, fn := range funcs {
+			keys[i] = key{fn.Name(), seen[fn.Name()]}
+			seen[fn.Name()]++
+		}
+		return keys
+	}
+
+	oldIndex := make(map[key]int, len(before))
+	for i, k := range keys(before) {
+		oldIndex[k] = i
+	}
+	matched := make([]bool, len(before))
+
+	var funcs []*Func
+	for i, k := range keys(after) {
+		fn := &Func{New: after[i], NewSize: size(after[i]), Status: Added}
+		if at, ok := oldIndex[k]; ok {
+			matched[at] = true
+			fn.Old, fn.OldSize = before[at], size(before[at])
+			switch {
+			case fn.NewSize > fn.OldSize:
+				fn.Status = Grown
+			case fn.NewSize < fn.OldSize:
+				fn.Status = Shrunk
+			default:
+				fn.Status = Same
+			}
+		}
+		funcs = append(funcs, fn)
+	}
+	for i, fn := range before {
+		if !matched[i] {
+			funcs = append(funcs, &Func{Old: fn, OldSize: size(fn), Status: Removed})
+		}
+	}
+	return funcs
+}
+
+// SortByChange sorts the functions by the status and then by the largest
+// change in size.
+func SortByChange(funcs []*Func) {
+	slices.SortStableFunc(funcs, func(a, b *Func) int {
+		if c := cmp.Compare(statusOrder(a.Status), statusOrder(b.Status)); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(abs(b.Delta()), abs(a.Delta())); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Name(), b.Name())
+	})
+}
+
+// statusOrder lists the functions that changed the most first.
+func statusOrder(status Status) int {
+	switch status {
+	case Added:
+		return 0
+	case Removed:
+		return 1
+	case Grown:
+		return 2
+	case Shrunk:
+		return 3
+	case Changed:
+		return 4
+	default:
+		return 5
+	}
+}
+
+// Load loads the code of the function in both builds, the missing one is
+// empty.
+func (fn *Func) Load(opts disasm.Options) (before, after *disasm.Code, err error) {
+	before, after = &disasm.Code{}, &disasm.Code{}
+	if fn.Old != nil {
+		if before, err = fn.Old.Load(opts); err != nil {
+			return nil, nil, err
+		}
+	}
+	if fn.New != nil {
+		if after, err = fn.New.Load(opts); err != nil {
+			return nil, nil, err
+		}
+	}
+	return before, after, nil
+}
+
+// FormatDelta formats the change in size, e.g. "+16".
+func FormatDelta(delta int64) string {
+	if delta > 0 {
+		return "+" + strconv.FormatInt(delta, 10)
+	}
+	return strconv.FormatInt(delta, 10)
+}
+
+func size(fn disasm.Func) uint64 {
+	if sizer, ok := fn.(disasm.Sizer); ok {
+		return sizer.Size()
+	}
+	return 0
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,60 @@
+package asmdiff
+
+import (
+	"testing"
+
+	"loov.dev/lensm/internal/disasm"
+)
+
+type testFunc struct {
+	name string
+	size uint64
+}
+
+func (fn testFunc) Name() string { return fn.name }
+func (fn testFunc) Size() uint64 { return fn.size }
+func (fn testFunc) Load(disasm.Options) (*disasm.Code, error) {
+	return &disasm.Code{Name: fn.name}, nil
+}
+
+func TestCompare(t *testing.T) {
+	before := []disasm.Func{
+		testFunc{"main.gone", 16},
+		testFunc{"main.grow", 32},
+		testFunc{"main.same", 8},
+		testFunc{"main.shrink", 64},
+		testFunc{"static", 4},
+	}
+	after := []disasm.Func{
+		testFunc{"main.grow", 48},
+		testFunc{"main.new", 24},
+		testFunc{"main.same", 8},
+		testFunc{"main.shrink", 40},
+		testFunc{"static", 4},
+		testFunc{"static", 12},
+	}
+	funcs := Compare(before, after)
+	SortByChange(funcs)
+
+	want := []struct {
+		name   string
+		status Status
+		delta  int64
+	}{
+		{"main.new", Added, 24},
+		{"static", Added, 12},
+		{"main.gone", Removed, -16},
+		{"main.grow", Grown, 16},
+		{"main.shrink", Shrunk, -24},
+		{"main.same", Same, 0},
+		{"static", Same, 0},
+	}
+	if len(funcs) != len(want) {
+		t.Fatalf("got %d functions, want %d", len(funcs), len(want))
+	}
+	for i, fn := range funcs {
+		if fn.Name() != want[i].name || fn.Status != want[i].status || fn.Delta() != want[i].delta {
+			t.Errorf("%d: %s %v %d, want %+v", i, fn.Name(), fn.Status, fn.Delta(), want[i])
+		}
+	}
+}
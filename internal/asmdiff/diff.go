@@ -0,0 +1,201 @@
+package asmdiff
+
+import (
+	"slices"
+
+	"loov.dev/lensm/internal/disasm"
+)
+
+// Op is the kind of a row in the side-by-side diff.
+type Op uint8
+
+const (
+	// Equal is the same instruction on both sides.
+	Equal Op = iota
+	// Delete is only on the old side.
+	Delete
+	// Insert is only on the new side.
+	Insert
+	// Change replaces the old instruction with the new one.
+	Change
+)
+
+// Row is a row of the side-by-side diff.
+type Row struct {
+	Op Op
+	// Old and New are the indices in Code.Insts, -1 on the empty side.
+	Old, New int
+}
+
+// maxEdits limits the search for the shortest diff, larger changes are
+// shown as replacing the whole middle of the function.
+const maxEdits = 1000
+
+// Diff compares the normalized instructions of the two versions of a
+// function. The deletions followed by insertions are paired as changes.
+// The empty rows before the jump targets are skipped.
+func Diff(before, after *disasm.Code) []Row {
+	oldIndex, oldText := normalized(before)
+	newIndex, newText := normalized(after)
+
+	var rows []Row
+	pending := func(deleted, inserted []int) {
+		n := min(len(deleted), len(inserted))
+		for i := range n {
+			rows = append(rows, Row{Op: Change, Old: deleted[i], New: inserted[i]})
+		}
+		for _, i := range deleted[n:] {
+			rows = append(rows, Row{Op: Delete, Old: i, New: -1})
+		}
+		for _, i := range inserted[n:] {
+			rows = append(rows, Row{Op: Insert, Old: -1, New: i})
+		}
+	}
+
+	var deleted, inserted []int
+	x, y := 0, 0
+	for _, op := range edits(oldText, newText) {
+		switch op {
+		case Equal:
+			pending(deleted, inserted)
+			deleted, inserted = deleted[:0], inserted[:0]
+			rows = append(rows, Row{Op: Equal, Old: oldIndex[x], New: newIndex[y]})
+			x, y = x+1, y+1
+		case Delete:
+			deleted = append(deleted, oldIndex[x])
+			x++
+		case Insert:
+			inserted = append(inserted, newIndex[y])
+			y++
+		}
+	}
+	pending(deleted, inserted)
+	return rows
+}
+
+// Differs reports whether the diff has other rows than Equal.
+func Differs(rows []Row) bool {
+	for _, row := range rows {
+		if row.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// normalized returns the indices of the instructions in code.Insts and
+// their normalized text.
+func normalized(code *disasm.Code) (index []int, text []string) {
+	for i, inst := range code.Insts {
+		if inst.Text == "" {
+			continue
+		}
+		index = append(index, i)
+		text = append(text, Normalize(inst))
+	}
+	return index, text
+}
+
+// edits returns the shortest script of Equal, Delete and Insert that turns
+// a into b, see "An O(ND) Difference Algorithm and Its Variations" by
+// Eugene W. Myers.
+func edits(a, b []string) []Op {
+	// The common prefix and suffix are usually most of the function.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []Op
+	for range prefix {
+		ops = append(ops, Equal)
+	}
+	ops = append(ops, middle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for range suffix {
+		ops = append(ops, Equal)
+	}
+	return ops
+}
+
+// middle is edits without the common prefix and suffix.
+func middle(a, b []string) []Op {
+	n, m := len(a), len(b)
+	replace := func() []Op {
+		ops := make([]Op, 0, n+m)
+		for range n {
+			ops = append(ops, Delete)
+		}
+		for range m {
+			ops = append(ops, Insert)
+		}
+		return ops
+	}
+	if n == 0 || m == 0 {
+		return replace()
+	}
+
+	// v[k] is the furthest x on the diagonal k = x - y, the trace keeps v
+	// of every step for walking back.
+	limit := min(n+m, maxEdits)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+				return backtrack(trace, n, m)
+			}
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+	}
+	return replace()
+}
+
+// backtrack walks the trace of middle from the end to the start.
+func backtrack(trace [][]int, n, m int) []Op {
+	var ops []Op
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		// trace[d-1] is v before the step d, for the diagonals -d+1..d-1.
+		prev := func(k int) int { return trace[d-1][k+d-1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || k != d && prev(k-1) < prev(k+1) {
+			prevK = k + 1
+		}
+		prevX := prev(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, Equal)
+			x, y = x-1, y-1
+		}
+		if prevK == k+1 {
+			ops = append(ops, Insert)
+		} else {
+			ops = append(ops, Delete)
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		ops = append(ops, Equal)
+		x, y = x-1, y-1
+	}
+	slices.Reverse(ops)
+	return ops
+}
@@ -0,0 +1,95 @@
+package asmdiff
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+
+	"loov.dev/lensm/internal/disasm"
+)
+
+// lcs returns the length of the longest common subsequence.
+func lcs(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		next := make([]int, len(b)+1)
+		for k := range b {
+			if a[i] == b[k] {
+				next[k+1] = prev[k] + 1
+			} else {
+				next[k+1] = max(prev[k+1], next[k])
+			}
+		}
+		prev = next
+	}
+	return prev[len(b)]
+}
+
+func TestEdits_Shortest(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	words := func() []string {
+		s := make([]string, rng.IntN(30))
+		for i := range s {
+			s[i] = strconv.Itoa(rng.IntN(5))
+		}
+		return s
+	}
+	for range 500 {
+		a, b := words(), words()
+		ops := edits(a, b)
+
+		// Applying the script to a gives b.
+		var got []string
+		x, y, equal := 0, 0, 0
+		for _, op := range ops {
+			switch op {
+			case Equal:
+				if a[x] != b[y] {
+					t.Fatalf("%q → %q: equal %q and %q", a, b, a[x], b[y])
+				}
+				got = append(got, a[x])
+				x, y, equal = x+1, y+1, equal+1
+			case Delete:
+				x++
+			case Insert:
+				got = append(got, b[y])
+				y++
+			}
+		}
+		if x != len(a) || !slices.Equal(got, b) {
+			t.Fatalf("%q → %q: got %q", a, b, got)
+		}
+		if want := lcs(a, b); equal != want {
+			t.Fatalf("%q → %q: %d equal, want %d", a, b, equal, want)
+		}
+	}
+}
+
+func TestDiff_PairsChanges(t *testing.T) {
+	code := func(texts ...string) *disasm.Code {
+		code := &disasm.Code{Insts: []disasm.Inst{{}}}
+		for i, text := range texts {
+			code.Insts = append(code.Insts, disasm.Inst{PC: uint64(0x100 + i), Text: text})
+		}
+		return code
+	}
+	before := code("PUSHQ BP", "MOVQ $1, AX", "ADDQ BX, AX", "RET")
+	after := code("PUSHQ BP", "MOVQ $2, AX", "INCQ AX", "ADDQ BX, AX", "NOP", "RET")
+
+	want := []Row{
+		{Op: Equal, Old: 1, New: 1},
+		{Op: Change, Old: 2, New: 2},
+		{Op: Insert, Old: -1, New: 3},
+		{Op: Equal, Old: 3, New: 4},
+		{Op: Insert, Old: -1, New: 5},
+		{Op: Equal, Old: 4, New: 6},
+	}
+	rows := Diff(before, after)
+	if !slices.Equal(rows, want) {
+		t.Errorf("rows = %+v, want %+v", rows, want)
+	}
+	if !Differs(rows) || Differs(Diff(before, before)) {
+		t.Error("Differs")
+	}
+}
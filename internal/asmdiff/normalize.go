@@ -0,0 +1,58 @@
+package asmdiff
+
+import (
+	"regexp"
+	"strings"
+
+	"loov.dev/lensm/internal/disasm"
+)
+
+// rxPCRelative matches an offset from the pc, e.g. "0x1234(IP)" on amd64 or
+// "26(PC)" on arm64.
+var rxPCRelative = regexp.MustCompile(`-?(?:0x[\da-fA-F]+|\d+)\((?:IP|PC)\)`)
+
+// rxAddress matches an address, e.g. the target in "JBE 0x47dc15".
+var rxAddress = regexp.MustCompile(`\b0x[\da-fA-F]+\b`)
+
+// rxNumber matches a number.
+var rxNumber = regexp.MustCompile(`-?\b(?:0x[\da-fA-F]+|\d+)\b`)
+
+// rxGroupOffset matches the offset in the grouped symbols, e.g. in
+// "go:string.*+1004(SB)", which moves when other strings are added.
+var rxGroupOffset = regexp.MustCompile(`\*\+(?:0x[\da-fA-F]+|\d+)\(SB\)`)
+
+// addressPlaceholder replaces the addresses that differ between the builds.
+const addressPlaceholder = "·"
+
+// Normalize returns the text of the instruction without the addresses and
+// the offsets that move when code is added elsewhere. The jump targets in
+// the function are dropped, the called functions and the loaded symbols
+// are kept with the Go strings and floats that they contain.
+func Normalize(inst disasm.Inst) string {
+	text, _, _ := strings.Cut(inst.Text, "\t")
+	text = strings.TrimSpace(text)
+
+	text = rxPCRelative.ReplaceAllString(text, "(PC)")
+	if inst.Call == "" && (inst.RefPC != 0 || len(inst.Targets) > 0) {
+		text = rxAddress.ReplaceAllString(text, addressPlaceholder)
+	}
+	text = rxGroupOffset.ReplaceAllString(text, "*(SB)")
+
+	// The other architectures build the address from several parts, e.g.
+	// "ADD $377, R0, R0" after ADRP, the symbol is more stable.
+	symbolic := strings.Contains(text, "(SB)")
+	if len(inst.Operands) > 0 && !symbolic {
+		text = rxNumber.ReplaceAllString(text, addressPlaceholder)
+	}
+	for _, op := range inst.Operands {
+		if !symbolic {
+			text += " " + op.Symbol
+		}
+		// The other values are guessed and may be in the middle of
+		// unrelated data that moves.
+		if op.Value != "" && (strings.HasPrefix(op.Symbol, "go:string.") || strings.HasPrefix(op.Symbol, "$f")) {
+			text += " " + op.Value
+		}
+	}
+	return text
+}
@@ -0,0 +1,48 @@
+package asmdiff
+
+import (
+	"testing"
+
+	"loov.dev/lensm/internal/disasm"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		inst disasm.Inst
+		want string
+	}{
+		{disasm.Inst{Text: "MOVQ 0x10(R14), AX"}, "MOVQ 0x10(R14), AX"},
+		{disasm.Inst{Text: "JBE 0x47dc15", RefPC: 0x47dc15}, "JBE ·"},
+		{disasm.Inst{Text: "BLS 26(PC)", RefPC: 0x88010}, "BLS (PC)"},
+		{disasm.Inst{Text: "CALL main.add(SB)", RefPC: 0x47db20, Call: "main.add"}, "CALL main.add(SB)"},
+		{disasm.Inst{Text: "LEAQ 0xa3a97(IP), AX"}, "LEAQ (PC), AX"},
+		{
+			disasm.Inst{
+				Text:     "LEAQ go:string.*+404(SB), AX",
+				Operands: []disasm.Operand{{Symbol: "go:string.*", Offset: 404, Value: `"world"`}},
+			},
+			`LEAQ go:string.*(SB), AX "world"`,
+		},
+		{disasm.Inst{Text: "ADRP 36864(PC), R0"}, "ADRP (PC), R0"},
+		{
+			disasm.Inst{
+				Text:     "ADD $377, R0, R0",
+				Operands: []disasm.Operand{{Symbol: "go:string.*", Offset: 377, Value: `"world"`}},
+			},
+			`ADD $·, R0, R0 go:string.* "world"`,
+		},
+		{
+			disasm.Inst{
+				Text:     "LEAQ 0x17944(IP), BX",
+				Operands: []disasm.Operand{{Symbol: ".rodata", Offset: 32832, Value: `"S$H"`}},
+			},
+			"LEAQ (PC), BX .rodata",
+		},
+		{disasm.Inst{Text: "CALL 0(PC)\t[1:5]R_CALL:main.add", Call: "main.add"}, "CALL (PC)"},
+	}
+	for _, test := range tests {
+		if got := Normalize(test.inst); got != test.want {
+			t.Errorf("Normalize(%q) = %q, want %q", test.inst.Text, got, test.want)
+		}
+	}
+}
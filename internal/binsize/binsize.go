@@ -0,0 +1,281 @@
+// Package binsize attributes the size of an executable to the packages,
+// the symbols and the generic functions, and compares two builds.
+package binsize
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"loov.dev/lensm/internal/disasm"
+)
+
+// By is how the symbols are grouped.
+type By uint8
+
+const (
+	// ByPackage sums the symbols of a package.
+	ByPackage By = iota
+	// BySymbol lists the functions and the data symbols.
+	BySymbol
+	// ByGeneric sums the instantiations of a generic function.
+	ByGeneric
+)
+
+// String returns the name of the grouping, as accepted by ParseBy.
+func (by By) String() string {
+	switch by {
+	case ByPackage:
+		return "package"
+	case BySymbol:
+		return "symbol"
+	case ByGeneric:
+		return "generic"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseBy parses the name of a grouping.
+func ParseBy(name string) (By, error) {
+	for _, by := range []By{ByPackage, BySymbol, ByGeneric} {
+		if by.String() == name {
+			return by, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown grouping %q, expected package, symbol or generic", name)
+}
+
+// Key is a column of the report to sort by.
+type Key uint8
+
+const (
+	// Total is the sum of the other sizes.
+	Total Key = iota
+	// Text is the code.
+	Text
+	// ROData is the read-only data.
+	ROData
+	// Data is the initialized variables.
+	Data
+	// PCLN is the pclntab.
+	PCLN
+	// Name sorts alphabetically.
+	Name
+)
+
+// String returns the name of the column, as accepted by ParseKey.
+func (key Key) String() string {
+	switch key {
+	case Total:
+		return "total"
+	case Text:
+		return "text"
+	case ROData:
+		return "rodata"
+	case Data:
+		return "data"
+	case PCLN:
+		return "pclntab"
+	case Name:
+		return "name"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseKey parses the name of a column.
+func ParseKey(name string) (Key, error) {
+	for _, key := range []Key{Total, Text, ROData, Data, PCLN, Name} {
+		if key.String() == name {
+			return key, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown column %q, expected total, text, rodata, data, pclntab or name", name)
+}
+
+// Entry is the size of a package, a symbol or a generic function in bytes.
+type Entry struct {
+	Name   string `json:"name"`
+	Text   uint64 `json:"text"`
+	ROData uint64 `json:"rodata"`
+	Data   uint64 `json:"data"`
+	PCLN   uint64 `json:"pclntab"`
+	// Count is the number of the symbols, e.g. the instantiations of a
+	// generic function.
+	Count int `json:"count"`
+}
+
+// Size returns the size in the column, zero for Name.
+func (entry *Entry) Size(key Key) uint64 {
+	switch key {
+	case Total:
+		return entry.Text + entry.ROData + entry.Data + entry.PCLN
+	case Text:
+		return entry.Text
+	case ROData:
+		return entry.ROData
+	case Data:
+		return entry.Data
+	case PCLN:
+		return entry.PCLN
+	default:
+		return 0
+	}
+}
+
+func (entry *Entry) add(sym disasm.SymbolSize) {
+	switch sym.Kind {
+	case disasm.InText:
+		entry.Text += sym.Size
+	case disasm.InROData:
+		entry.ROData += sym.Size
+	case disasm.InData:
+		entry.Data += sym.Size
+	case disasm.InPCLN:
+		entry.PCLN += sym.Size
+	}
+}
+
+// Report is the size of an executable.
+type Report struct {
+	Total Entry
+	// Packages, Symbols and Generics are sorted by the total size.
+	Packages []*Entry
+	Symbols  []*Entry
+	Generics []*Entry
+}
+
+// New sums the sizes of the symbols.
+func New(sizes []disasm.SymbolSize) *Report {
+	report := &Report{Total: Entry{Name: "total"}}
+	packages := map[string]*Entry{}
+	symbols := map[string]*Entry{}
+	generics := map[string]*Entry{}
+	entry := func(entries map[string]*Entry, name string, counted bool) *Entry {
+		e, ok := entries[name]
+		if !ok {
+			e = &Entry{Name: name}
+			entries[name] = e
+		}
+		// A symbol is counted once for the text, the data and the
+		// pclntab.
+		if !counted {
+			e.Count++
+		}
+		return e
+	}
+
+	seen := map[string]bool{}
+	for _, sym := range sizes {
+		counted := seen[sym.Name]
+		seen[sym.Name] = true
+		report.Total.add(sym)
+		if !counted {
+			report.Total.Count++
+		}
+		entry(symbols, sym.Name, counted).add(sym)
+		entry(packages, PackageName(sym.Name), counted).add(sym)
+		if generic, ok := GenericName(sym.Name); ok {
+			entry(generics, generic, counted).add(sym)
+		}
+	}
+
+	list := func(entries map[string]*Entry) []*Entry {
+		list := make([]*Entry, 0, len(entries))
+		for _, e := range entries {
+			list = append(list, e)
+		}
+		Sort(list, Total)
+		return list
+	}
+	report.Packages = list(packages)
+	report.Symbols = list(symbols)
+	report.Generics = list(generics)
+	return report
+}
+
+// Entries returns the entries of the grouping.
+func (report *Report) Entries(by By) []*Entry {
+	switch by {
+	case BySymbol:
+		return report.Symbols
+	case ByGeneric:
+		return report.Generics
+	default:
+		return report.Packages
+	}
+}
+
+// Sort sorts the entries by the largest size in the column, or by name.
+func Sort(entries []*Entry, key Key) {
+	slices.SortStableFunc(entries, func(a, b *Entry) int {
+		if key != Name {
+			if c := cmp.Compare(b.Size(key), a.Size(key)); c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+}
+
+// unknownPackage is the package of the symbols without one, e.g. in C.
+const unknownPackage = "(other)"
+
+// PackageName returns the import path of the package that the symbol
+// belongs to. The symbols that the compiler generates, e.g. go:string.*,
+// are their own package.
+func PackageName(name string) string {
+	// The symbols of the other archive entries are prefixed with the entry.
+	if _, after, ok := strings.Cut(name, ": "); ok {
+		name = after
+	}
+	if strings.HasPrefix(name, "go:") || strings.HasPrefix(name, "type:") {
+		return name
+	}
+	// The type arguments may contain other import paths.
+	name = stripTypeArgs(name)
+	start := strings.LastIndex(name, "/") + 1
+	if i := strings.Index(name[start:], "."); i > 0 {
+		return name[:start+i]
+	}
+	return unknownPackage
+}
+
+// GenericName returns the name of the generic function for an
+// instantiation, e.g. "slices.Sort[...]" for "slices.Sort[go.shape.int]".
+// The dictionaries are attributed to the function.
+func GenericName(name string) (string, bool) {
+	if !strings.Contains(name, "[") {
+		return "", false
+	}
+	name = strings.Replace(name, "..dict.", ".", 1)
+	return stripTypeArgs(name), true
+}
+
+// stripTypeArgs replaces the type arguments in name with "...".
+func stripTypeArgs(name string) string {
+	if !strings.Contains(name, "[") {
+		return name
+	}
+	var b strings.Builder
+	depth := 0
+	for _, r := range name {
+		switch {
+		case r == '[':
+			if depth == 0 {
+				b.WriteString("[...")
+			}
+			depth++
+		case r == ']' && depth > 0:
+			depth--
+			if depth == 0 {
+				b.WriteRune(r)
+			}
+		case depth == 0:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,101 @@
+package binsize
+
+import (
+	"testing"
+
+	"loov.dev/lensm/internal/disasm"
+)
+
+func TestPackageName(t *testing.T) {
+	tests := []struct{ name, want string }{
+		{"main.main", "main"},
+		{"net/http.(*Server).Serve", "net/http"},
+		{"github.com/loov/lensm/internal/goobj.Load", "github.com/loov/lensm/internal/goobj"},
+		{"slices.Sort[go.shape.struct { net/http.a int }]", "slices"},
+		{"go:string.*", "go:string.*"},
+		{"type:*", "type:*"},
+		{"puts@plt", "(other)"},
+		{"_cgo_main.o: main.helper", "main"},
+	}
+	for _, test := range tests {
+		if got := PackageName(test.name); got != test.want {
+			t.Errorf("PackageName(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestGenericName(t *testing.T) {
+	tests := []struct {
+		name, want string
+		ok         bool
+	}{
+		{"main.main", "", false},
+		{"slices.Sort[go.shape.[]uint8]", "slices.Sort[...]", true},
+		{"main.(*List[go.shape.int]).Push", "main.(*List[...]).Push", true},
+		{"main..dict.Map[int,string]", "main.Map[...]", true},
+	}
+	for _, test := range tests {
+		got, ok := GenericName(test.name)
+		if got != test.want || ok != test.ok {
+			t.Errorf("GenericName(%q) = %q, %v, want %q, %v", test.name, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	report := New([]disasm.SymbolSize{
+		{Name: "main.Map[go.shape.int]", Kind: disasm.InText, Size: 100},
+		{Name: "main.Map[go.shape.int]", Kind: disasm.InPCLN, Size: 20},
+		{Name: "main.Map[go.shape.string]", Kind: disasm.InText, Size: 120},
+		{Name: "main..dict.Map[string]", Kind: disasm.InROData, Size: 8},
+		{Name: "main.main", Kind: disasm.InText, Size: 50},
+		{Name: "fmt.Println", Kind: disasm.InText, Size: 200},
+	})
+	if got := report.Total; got.Size(Total) != 498 || got.Count != 5 {
+		t.Errorf("total = %+v, want 498 B in 5 symbols", got)
+	}
+
+	main := report.Packages[0]
+	if main.Name != "main" || main.Text != 270 || main.ROData != 8 || main.PCLN != 20 || main.Count != 4 {
+		t.Errorf("first package = %+v, want main", main)
+	}
+	if len(report.Generics) != 1 {
+		t.Fatalf("generics = %v, want main.Map[...]", report.Generics)
+	}
+	if generic := report.Generics[0]; generic.Name != "main.Map[...]" || generic.Size(Total) != 248 || generic.Count != 3 {
+		t.Errorf("generic = %+v, want main.Map[...] with 248 B in 3 symbols", generic)
+	}
+
+	Sort(report.Symbols, Name)
+	if first := report.Symbols[0].Name; first != "fmt.Println" {
+		t.Errorf("first symbol by name = %q", first)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	before := []*Entry{{Name: "fmt", Text: 100}, {Name: "main", Text: 50}, {Name: "os", Text: 10}}
+	after := []*Entry{{Name: "fmt", Text: 100}, {Name: "main", Text: 40}, {Name: "net", Text: 400}}
+	deltas := Compare(before, after)
+	var got []string
+	for _, delta := range deltas {
+		got = append(got, delta.Name)
+	}
+	if want := []string{"net", "main", "os"}; !equal(got, want) {
+		t.Errorf("deltas = %v, want %v", got, want)
+	}
+	if change := deltas[1].Change(Text); change != -10 {
+		t.Errorf("main changed by %d, want -10", change)
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package binsize
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Delta is an entry in either or both builds, the missing one is zero.
+type Delta struct {
+	Name     string
+	Old, New Entry
+}
+
+// Change returns the growth in the column, negative when it shrunk.
+func (delta *Delta) Change(key Key) int64 {
+	return int64(delta.New.Size(key)) - int64(delta.Old.Size(key))
+}
+
+// Compare matches the entries of the builds by name, the ones that didn't
+// change are left out.
+func Compare(before, after []*Entry) []*Delta {
+	byName := map[string]*Delta{}
+	var deltas []*Delta
+	delta := func(name string) *Delta {
+		d, ok := byName[name]
+		if !ok {
+			d = &Delta{Name: name}
+			byName[name] = d
+			deltas = append(deltas, d)
+		}
+		return d
+	}
+	for _, e := range before {
+		delta(e.Name).Old = *e
+	}
+	for _, e := range after {
+		delta(e.Name).New = *e
+	}
+	deltas = slices.DeleteFunc(deltas, func(d *Delta) bool { return d.Old == d.New })
+	SortDeltas(deltas, Total)
+	return deltas
+}
+
+// SortDeltas sorts by the largest change in the column, or by name.
+func SortDeltas(deltas []*Delta, key Key) {
+	slices.SortStableFunc(deltas, func(a, b *Delta) int {
+		if key != Name {
+			if c := cmp.Compare(abs(b.Change(key)), abs(a.Change(key))); c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
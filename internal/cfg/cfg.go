@@ -0,0 +1,212 @@
+// Package cfg builds the control-flow graph of the disassembled code, split
+// into basic blocks, and lays it out in layers for drawing.
+package cfg
+
+import (
+	"strings"
+
+	"loov.dev/lensm/internal/disasm"
+)
+
+// Graph is the control-flow graph of a disasm.Code.
+type Graph struct {
+	// Blocks are the basic blocks in the order of the instructions, the
+	// first one is the entry.
+	Blocks []Block
+	// Edges are the transfers of control between the blocks.
+	Edges []Edge
+	// Layers are the blocks in each layer from left to right, see Layout.
+	Layers [][]int
+}
+
+// Block is a basic block, a run of instructions that is entered only at
+// the first and left only at the last one.
+type Block struct {
+	// Start and End are the range of Code.Insts in the block, End is
+	// exclusive. The range may include the empty rows before jump targets.
+	Start, End int
+	// Layer and Order are the row and the column of the block in the
+	// layered layout.
+	Layer, Order int
+}
+
+// Kind is the kind of an edge.
+type Kind uint8
+
+const (
+	// Fallthrough continues with the next block.
+	Fallthrough Kind = iota
+	// Branch jumps to the target of the last instruction.
+	Branch
+)
+
+// Edge is a transfer of control from one block to another.
+type Edge struct {
+	From, To int
+	Kind     Kind
+	// Back is set for the edges that close a loop, they point against the
+	// layers.
+	Back bool
+}
+
+// Build splits code into basic blocks, connects them and lays them out. The
+// blocks start at the jump targets and end after the jumps and returns.
+func Build(code *disasm.Code) *Graph {
+	g := &Graph{}
+	if code == nil || code.Data != nil {
+		return g
+	}
+
+	pcToIndex := map[uint64]int{}
+	for i, ix := range code.Insts {
+		if ix.Text != "" {
+			pcToIndex[ix.PC] = i
+		}
+	}
+	targets := func(ix disasm.Inst) []int {
+		// A named target is a call or a tail call, except for the
+		// jump back to the entry after growing the stack.
+		if ix.Call != "" && !noFallthrough(ix) {
+			return nil
+		}
+		pcs := ix.Targets
+		if len(pcs) == 0 && ix.RefPC != 0 {
+			pcs = []uint64{ix.RefPC}
+		}
+		var indices []int
+		for _, pc := range pcs {
+			if i, ok := pcToIndex[pc]; ok {
+				indices = append(indices, i)
+			}
+		}
+		return indices
+	}
+
+	leaders := map[int]bool{}
+	first := true
+	for i, ix := range code.Insts {
+		if ix.Text == "" {
+			continue
+		}
+		if first {
+			leaders[i], first = true, false
+		}
+		jumps := targets(ix)
+		for _, target := range jumps {
+			leaders[target] = true
+		}
+		if len(jumps) > 0 || noFallthrough(ix) {
+			if next := nextInst(code.Insts, i+1); next >= 0 {
+				leaders[next] = true
+			}
+		}
+	}
+	if len(leaders) == 0 {
+		return g
+	}
+
+	blockAt := map[int]int{}
+	for i, ix := range code.Insts {
+		if ix.Text == "" {
+			continue
+		}
+		if leaders[i] {
+			blockAt[i] = len(g.Blocks)
+			g.Blocks = append(g.Blocks, Block{Start: i})
+		}
+		g.Blocks[len(g.Blocks)-1].End = i + 1
+	}
+
+	seen := map[[2]int]bool{}
+	connect := func(from, to int, kind Kind) {
+		if seen[[2]int{from, to}] {
+			return
+		}
+		seen[[2]int{from, to}] = true
+		g.Edges = append(g.Edges, Edge{From: from, To: to, Kind: kind})
+	}
+	for b, block := range g.Blocks {
+		last := code.Insts[block.End-1]
+		if !noFallthrough(last) && b+1 < len(g.Blocks) {
+			connect(b, b+1, Fallthrough)
+		}
+		for _, target := range targets(last) {
+			connect(b, blockAt[target], Branch)
+		}
+	}
+
+	g.markBackEdges()
+	g.Layout()
+	return g
+}
+
+// BlockOf returns the block that contains the instruction at index in
+// Code.Insts, or -1.
+func (g *Graph) BlockOf(index int) int {
+	for b, block := range g.Blocks {
+		if block.Start <= index && index < block.End {
+			return b
+		}
+	}
+	return -1
+}
+
+// markBackEdges marks the edges that close a loop in a depth-first search
+// from the entry, the unreachable blocks are searched afterwards.
+func (g *Graph) markBackEdges() {
+	out := make([][]int, len(g.Blocks))
+	for i, edge := range g.Edges {
+		out[edge.From] = append(out[edge.From], i)
+	}
+
+	const (
+		unvisited = iota
+		active
+		done
+	)
+	state := make([]uint8, len(g.Blocks))
+	var visit func(b int)
+	visit = func(b int) {
+		state[b] = active
+		for _, i := range out[b] {
+			edge := &g.Edges[i]
+			switch state[edge.To] {
+			case unvisited:
+				visit(edge.To)
+			case active:
+				edge.Back = true
+			}
+		}
+		state[b] = done
+	}
+	for b := range g.Blocks {
+		if state[b] == unvisited {
+			visit(b)
+		}
+	}
+}
+
+// unconditional are the mnemonics of the jumps and returns that don't
+// continue with the next instruction, in the Go syntax and in wasm.
+var unconditional = map[string]bool{
+	"JMP": true, "B": true, "BR": true, "RET": true,
+	"br": true, "br_table": true, "return": true, "unreachable": true, "else": true,
+}
+
+// noFallthrough reports whether the instruction never continues with the
+// next one.
+func noFallthrough(ix disasm.Inst) bool {
+	mnemonic, _, _ := strings.Cut(strings.TrimSpace(ix.Text), " ")
+	return unconditional[mnemonic]
+}
+
+// nextInst returns the index of the first instruction from i on, skipping
+// the empty rows, or -1.
+func nextInst(insts []disasm.Inst, i int) int {
+	for ; i < len(insts); i++ {
+		if insts[i].Text != "" {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,102 @@
+package cfg
+
+import (
+	"slices"
+	"testing"
+
+	"loov.dev/lensm/internal/disasm"
+)
+
+func load(insts ...disasm.Inst) *disasm.Code {
+	code := &disasm.Code{Name: "main.sum"}
+	disasm.LayoutJumps(code, insts)
+	return code
+}
+
+// blockPCs returns the pc of the first instruction of each block.
+func blockPCs(code *disasm.Code, g *Graph) []uint64 {
+	var pcs []uint64
+	for _, block := range g.Blocks {
+		pcs = append(pcs, code.Insts[block.Start].PC)
+	}
+	return pcs
+}
+
+func TestBuild_Loop(t *testing.T) {
+	code := load(
+		disasm.Inst{PC: 0x00, Text: "XORL AX, AX"},
+		disasm.Inst{PC: 0x02, Text: "JMP 0x0a", RefPC: 0x0a},
+		disasm.Inst{PC: 0x04, Text: "ADDQ CX, AX"},
+		disasm.Inst{PC: 0x07, Text: "INCQ CX"},
+		disasm.Inst{PC: 0x0a, Text: "CMPQ CX, BX"},
+		disasm.Inst{PC: 0x0d, Text: "JL 0x04", RefPC: 0x04},
+		disasm.Inst{PC: 0x0f, Text: "CALL main.add(SB)", RefPC: 0x40, Call: "main.add"},
+		disasm.Inst{PC: 0x14, Text: "RET"},
+	)
+	g := Build(code)
+
+	// The loop is entered at the condition, the body jumps back to it.
+	if got, want := blockPCs(code, g), []uint64{0x00, 0x04, 0x0a, 0x0f}; !slices.Equal(got, want) {
+		t.Fatalf("blocks start at %#x, want %#x", got, want)
+	}
+	want := []Edge{
+		{From: 0, To: 2, Kind: Branch},
+		{From: 1, To: 2, Kind: Fallthrough, Back: true},
+		{From: 2, To: 3, Kind: Fallthrough},
+		{From: 2, To: 1, Kind: Branch},
+	}
+	if !slices.Equal(g.Edges, want) {
+		t.Errorf("edges = %+v, want %+v", g.Edges, want)
+	}
+
+	var layers []int
+	for _, block := range g.Blocks {
+		layers = append(layers, block.Layer)
+	}
+	if want := []int{0, 2, 1, 2}; !slices.Equal(layers, want) {
+		t.Errorf("layers = %d, want %d", layers, want)
+	}
+	if len(g.Layers) != 3 || len(g.Layers[2]) != 2 {
+		t.Errorf("layers = %d", g.Layers)
+	}
+
+	for i, ix := range code.Insts {
+		if ix.PC == 0x07 && ix.Text != "" {
+			if b := g.BlockOf(i); b != 1 {
+				t.Errorf("BlockOf(INCQ) = %d, want 1", b)
+			}
+		}
+	}
+}
+
+func TestBuild_Targets(t *testing.T) {
+	jump := disasm.Inst{PC: 0x104, Text: "JMP (R1)"}
+	jump.SetTargets([]uint64{0x10c, 0x108, 0x10c})
+	code := load(
+		disasm.Inst{PC: 0x100, Text: "CMP $1, R0"},
+		jump,
+		disasm.Inst{PC: 0x108, Text: "RET"},
+		disasm.Inst{PC: 0x10c, Text: "JMP main.sum(SB)", RefPC: 0x100, Call: "main.sum"},
+	)
+	g := Build(code)
+
+	if got, want := blockPCs(code, g), []uint64{0x100, 0x108, 0x10c}; !slices.Equal(got, want) {
+		t.Fatalf("blocks start at %#x, want %#x", got, want)
+	}
+	// The jump back to the entry, e.g. after growing the stack, is a loop.
+	want := []Edge{
+		{From: 0, To: 1, Kind: Branch},
+		{From: 0, To: 2, Kind: Branch},
+		{From: 2, To: 0, Kind: Branch, Back: true},
+	}
+	if !slices.Equal(g.Edges, want) {
+		t.Errorf("edges = %+v, want %+v", g.Edges, want)
+	}
+}
+
+func TestBuild_Data(t *testing.T) {
+	code := &disasm.Code{Data: &disasm.DataSymbol{}, Insts: []disasm.Inst{{PC: 0, Text: "00 01"}}}
+	if g := Build(code); len(g.Blocks) != 0 {
+		t.Errorf("data has %d blocks", len(g.Blocks))
+	}
+}
@@ -2,6 +2,7 @@ package codeview
 
 import (
 	"image"
+	"image/color"
 	"strings"
 
 	"gioui.org/f32"
@@ -15,6 +16,7 @@ import (
 	"loov.dev/lensm/internal/comments"
 	"loov.dev/lensm/internal/disasm"
 	"loov.dev/lensm/internal/gui"
+	"loov.dev/lensm/internal/profile"
 	"loov.dev/lensm/internal/syntax"
 )
 
@@ -24,7 +26,16 @@ type UI struct {
 	asm gui.ScrollRegion
 	src gui.ScrollRegion
 
-	hl highlightCache
+	hl      highlightCache
+	graph   graphView
+	profile profileCache
+
+	// History are the earlier builds of the function, oldest first.
+	History []Snapshot
+	// Compare is the index in History that the inline diff is against.
+	Compare      int
+	diff         diffMarks
+	older, newer widget.Clickable
 
 	mousePosition f32.Point
 	SelectedAsm   int
@@ -33,6 +44,9 @@ type UI struct {
 	SelectedLine  int
 	Selection     TextSelection
 
+	// reveal scrolls the selected instruction into view on the next layout.
+	reveal bool
+
 	selecting        bool
 	selectionPointer pointer.ID
 	selectionStart   f32.Point
@@ -48,6 +62,7 @@ type highlightCache struct {
 	syntax syntax.Palette
 
 	asm        [][]syntax.Span
+	inline     [][]color.NRGBA
 	nativeText []string
 	native     [][]syntax.Span
 	// source is indexed by source file, block, and line within the block.
@@ -62,11 +77,13 @@ func (hl *highlightCache) update(code *disasm.Code, palette syntax.Palette) {
 	hl.syntax = palette
 
 	hl.asm = make([][]syntax.Span, len(code.Insts))
+	hl.inline = make([][]color.NRGBA, len(code.Insts))
 	hl.nativeText = make([]string, len(code.Insts))
 	hl.native = make([][]syntax.Span, len(code.Insts))
 	for i := range code.Insts {
 		ix := &code.Insts[i]
-		hl.asm[i] = syntax.HighlightAsm(ix.Text, ix.Call, palette)
+		hl.asm[i] = syntax.HighlightAsm(ix.Text, ix.Target(), palette)
+		hl.inline[i] = inlineColors(ix.Inlined)
 		hl.nativeText[i] = strings.ToUpper(ix.NativeText)
 		hl.native[i] = syntax.HighlightAsm(hl.nativeText[i], "", palette)
 	}
@@ -94,11 +111,34 @@ func (ui *UI) ResetScroll() {
 	ui.src.Offset = 100000
 }
 
+// SelectPC selects the instruction that contains pc and scrolls it into
+// view, for data it's the row.
+func (ui *UI) SelectPC(pc uint64) {
+	selected := -1
+	for i, ix := range ui.Code.Insts {
+		if ix.PC > pc {
+			break
+		}
+		if ix.Text != "" {
+			selected = i
+		}
+	}
+	if selected < 0 {
+		return
+	}
+	ui.SelectedAsm = selected
+	ui.SelectedView = ViewGoAsm
+	ui.reveal = true
+}
+
 type Style struct {
 	*UI
 
 	TryOpen  func(gtx layout.Context, funcname string)
 	CopyText func(gtx layout.Context, text string)
+	// OpenSymbol opens a referenced symbol at the offset, TryOpen is used
+	// when it's nil.
+	OpenSymbol func(gtx layout.Context, name string, offset uint64)
 	// OnInteract fires on a primary press in the content, used to keep a
 	// preview tab open once the user acts on it.
 	OnInteract func()
@@ -116,6 +156,13 @@ type Style struct {
 
 	ShowNative bool
 	ShowHelp   bool
+	// ShowGraph replaces the assembly with the control-flow graph.
+	ShowGraph bool
+	// ShowDiff marks the changes since an earlier build in History.
+	ShowDiff bool
+	// Profile shows the share of the samples next to the instructions
+	// and the source lines, nil hides the columns.
+	Profile    *profile.Overlay
 	TextHeight unit.Sp
 }
 
@@ -126,11 +173,45 @@ func (ui Style) Layout(gtx layout.Context) layout.Dimensions {
 	}
 	ui.UI.hl.update(ui.Code, ui.Syntax)
 
+	warning := staleWarning(ui.Code)
+	diff := ui.diffMode()
+	if warning == "" && !diff {
+		return ui.layoutCode(gtx)
+	}
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if warning == "" {
+				return layout.Dimensions{}
+			}
+			return ui.layoutStaleBanner(gtx, warning)
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if !diff {
+				return layout.Dimensions{}
+			}
+			return ui.layoutDiffBanner(gtx)
+		}),
+		layout.Flexed(1, ui.layoutCode),
+	)
+}
+
+// layoutCode draws the assembly, the source and the relations between them.
+func (ui Style) layoutCode(gtx layout.Context) layout.Dimensions {
+	gtx.Constraints = layout.Exact(gtx.Constraints.Max)
 	paint.FillShape(gtx.Ops, ui.Theme.Colors.Background, clip.Rect{Max: gtx.Constraints.Max}.Op())
 	defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
 
 	c := ui.columns(gtx)
+	reveal := ui.reveal
+	if ui.reveal {
+		ui.reveal = false
+		ui.asm.Anim.Stop()
+		ui.asm.Offset = float32(gtx.Constraints.Max.Y/3 - ui.SelectedAsm*c.lineHeight)
+	}
 	mouseClicked := ui.handleInput(gtx, c)
+	if ui.graphMode() {
+		return ui.layoutGraphCode(gtx, c, mouseClicked, reveal)
+	}
 
 	// draw gutter
 	paint.FillShape(gtx.Ops, ui.Theme.Colors.Gutter, clip.Rect{
@@ -144,9 +225,40 @@ func (ui Style) Layout(gtx layout.Context) layout.Dimensions {
 	hover := ui.resolveHover(gtx, c, mouseClicked)
 	highlightRanges := ui.layoutRelations(gtx, c, hover)
 	ui.layoutAssembly(gtx, c, hover, highlightRanges)
+	ui.layoutAsmProfile(gtx, c)
 	sourceContentHeight := ui.layoutSource(gtx, c, hover, mouseClicked)
+	ui.layoutSourceProfile(gtx, c)
 	ui.layoutScrollbars(gtx, c, sourceContentHeight)
 	ui.layoutHelp(gtx, c, hover)
 
 	return layout.Dimensions{Size: gtx.Constraints.Max}
 }
+
+// graphMode reports whether the control-flow graph is shown, data has no
+// graph.
+func (ui Style) graphMode() bool {
+	return ui.ShowGraph && ui.Code.Data == nil
+}
+
+// layoutGraphCode draws the control-flow graph next to the source.
+func (ui Style) layoutGraphCode(gtx layout.Context, c codeColumns, mouseClicked, reveal bool) layout.Dimensions {
+	paint.FillShape(gtx.Ops, ui.Theme.Colors.Gutter, clip.Rect{
+		Min: image.Pt(int(c.gutter.Min), 0),
+		Max: image.Pt(int(c.gutter.Max), gtx.Constraints.Max.Y),
+	}.Op())
+
+	hover := codeHover{
+		position: ui.mousePosition,
+		inSource: c.source.Contains(ui.mousePosition.X),
+		asmIndex: -1,
+	}
+	if hover.inSource {
+		pointer.CursorText.Add(gtx.Ops)
+	}
+	sourceContentHeight := ui.layoutSource(gtx, c, hover, mouseClicked)
+	ui.layoutSourceProfile(gtx, c)
+	ui.layoutScrollbars(gtx, c, sourceContentHeight)
+	ui.layoutGraph(gtx, c, reveal)
+
+	return layout.Dimensions{Size: gtx.Constraints.Max}
+}
@@ -15,8 +15,10 @@ import (
 	"gioui.org/unit"
 	"gioui.org/widget/material"
 
+	"loov.dev/lensm/internal/asmdiff"
 	"loov.dev/lensm/internal/disasm"
 	"loov.dev/lensm/internal/gui"
+	"loov.dev/lensm/internal/profile"
 	"loov.dev/lensm/internal/syntax"
 )
 
@@ -30,8 +32,11 @@ func TestCodeUIStyleLayoutWithHelpAndSelection(t *testing.T) {
 		File:    "main.go",
 		MaxJump: 1,
 		Insts: []disasm.Inst{
-			{Text: "MOV (R2), R1", NativeText: "mov (%r2), %r1"},
+			{Text: "MOV (R2), R1", NativeText: "mov (%r2), %r1",
+				RefOffset: 1, RefStack: 1, TargetOffsets: []int{1, 2}},
 			{Text: "ADDQ $1, R1", NativeText: "addq $1, %r1"},
+			{Text: "LEAQ 0x10(IP), AX", NativeText: "leaq 0x10(%rip), %rax",
+				Operands: []disasm.Operand{{Symbol: "go:string.*", Offset: 4, Value: `"hi"`}}},
 		},
 		Source: []disasm.Source{{
 			File: "main.go",
@@ -109,3 +114,127 @@ func TestCodeUIStyleLayoutWithHelpAndSelection(t *testing.T) {
 		t.Fatalf("overshoot drag selection = %#v, range %d..%d", state.Selection, from, to)
 	}
 }
+
+func TestStaleWarning(t *testing.T) {
+	code := &disasm.Code{Source: []disasm.Source{
+		{File: "a.go"},
+		{File: "b.go"},
+	}}
+	if warning := staleWarning(code); warning != "" {
+		t.Fatalf("unexpected warning %q", warning)
+	}
+
+	code.Source[1].Stale = true
+	code.Source[1].StaleReason = "modified after the binary was built"
+	if warning, want := staleWarning(code), "Source may not match the binary, b.go modified after the binary was built."; warning != want {
+		t.Fatalf("got %q, want %q", warning, want)
+	}
+
+	code.Source[0].Stale = true
+	code.Source[0].StaleReason = "modified after the binary was built"
+	if warning, want := staleWarning(code), "Sources may not match the binary, 2 files changed since it was built, e.g. a.go modified after the binary was built."; warning != want {
+		t.Fatalf("got %q, want %q", warning, want)
+	}
+
+	base := material.NewTheme()
+	base.Shaper = text.NewShaper(text.WithCollection(gui.LoadFonts("")))
+	theme := gui.NewTheme(base, false)
+	style := Style{
+		UI:         &UI{Code: code},
+		Theme:      theme,
+		Syntax:     syntax.PaletteFor(syntax.StyleGoLand, theme.Colors.SyntaxColors()),
+		TextHeight: theme.TextSize,
+	}
+	var operations op.Ops
+	gtx := layout.Context{
+		Ops:         &operations,
+		Metric:      unit.Metric{PxPerDp: 1, PxPerSp: 1},
+		Now:         time.Now(),
+		Constraints: layout.Exact(image.Pt(800, 400)),
+	}
+	if got := style.Layout(gtx).Size; got != gtx.Constraints.Max {
+		t.Fatalf("Layout size = %v, want %v", got, gtx.Constraints.Max)
+	}
+}
+
+func TestInlineText(t *testing.T) {
+	got := inlineText([]disasm.InlineFrame{
+		{Func: "strings.Index", File: "/src/a.go", Line: 12},
+		{Func: "main.find", File: "/src/b.go", Line: 40},
+	})
+	if want := "inlined from strings.Index at a.go:12 ← main.find at b.go:40"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestOperandsLabel(t *testing.T) {
+	got := operandsLabel([]disasm.Operand{
+		{Symbol: "go:string.*", Offset: 0x3c3, Value: `"hello"`},
+		{Symbol: "main.counter"},
+	})
+	want := `→ go:string.*+0x3c3 "hello"  → main.counter`
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDiffMarks(t *testing.T) {
+	code := func(texts ...string) *disasm.Code {
+		code := &disasm.Code{}
+		for i, text := range texts {
+			code.Insts = append(code.Insts, disasm.Inst{PC: uint64(i), Text: text})
+		}
+		return code
+	}
+	previous := code("MOVL $0x7, AX", "ADDQ $0x1, AX", "NOPL", "RET")
+	current := code("MOVL $0x7, AX", "ADDQ $0x2, AX", "RET", "INT $0x3")
+
+	var marks diffMarks
+	marks.update(current, previous)
+	if got, want := marks.summary(), "1 changed, 1 added, 1 removed"; got != want {
+		t.Errorf("summary = %q, want %q", got, want)
+	}
+	for i, want := range []string{"", "was ADDQ $0x1, AX", "- NOPL", ""} {
+		if got := marks.note(i); got != want {
+			t.Errorf("note(%d) = %q, want %q", i, got, want)
+		}
+	}
+	if marks.ops[3] != asmdiff.Insert {
+		t.Errorf("ops[3] = %v, want Insert", marks.ops[3])
+	}
+
+	base := material.NewTheme()
+	base.Shaper = text.NewShaper(text.WithCollection(gui.LoadFonts("")))
+	theme := gui.NewTheme(base, false)
+	state := &UI{Code: current}
+	state.SetHistory([]Snapshot{{Code: code("RET")}, {Code: previous, Loaded: time.Now()}})
+	style := Style{
+		UI:         state,
+		Theme:      theme,
+		Syntax:     syntax.PaletteFor(syntax.StyleGoLand, theme.Colors.SyntaxColors()),
+		ShowDiff:   true,
+		TextHeight: theme.TextSize,
+	}
+	var operations op.Ops
+	gtx := layout.Context{
+		Ops:         &operations,
+		Metric:      unit.Metric{PxPerDp: 1, PxPerSp: 1},
+		Now:         time.Now(),
+		Constraints: layout.Exact(image.Pt(800, 400)),
+	}
+	if got := style.Layout(gtx).Size; got != gtx.Constraints.Max {
+		t.Fatalf("Layout size = %v, want %v", got, gtx.Constraints.Max)
+	}
+	if state.diff.previous != previous {
+		t.Error("diff is not against the latest build")
+	}
+}
+
+func TestFormatShare(t *testing.T) {
+	overlay := &profile.Overlay{Total: 2000}
+	for weight, want := range map[int64]string{0: "", 1: "<0.1%", 2: "0.1%", 1500: "75.0%"} {
+		if got := FormatShare(overlay, weight); got != want {
+			t.Errorf("FormatShare(%d) = %q, want %q", weight, got, want)
+		}
+	}
+}
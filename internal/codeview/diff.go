@@ -0,0 +1,239 @@
+package codeview
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/widget"
+
+	"loov.dev/lensm/internal/asmdiff"
+	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/gui"
+)
+
+// Snapshot is the code of the function in an earlier build.
+type Snapshot struct {
+	Code *disasm.Code
+	// Loaded is when the build was loaded.
+	Loaded time.Time
+}
+
+// DiffColors are the backgrounds of the changed instructions, per
+// asmdiff.Op.
+var DiffColors = [...]color.NRGBA{
+	asmdiff.Equal:  {},
+	asmdiff.Delete: {R: 0xe0, G: 0x40, B: 0x40, A: 0x40},
+	asmdiff.Insert: {R: 0x40, G: 0xc0, B: 0x50, A: 0x40},
+	asmdiff.Change: {R: 0xe0, G: 0xa0, B: 0x20, A: 0x40},
+}
+
+// diffMarks are the changes of the instructions since a snapshot. The
+// removed instructions have no row, they are marked between the rows.
+type diffMarks struct {
+	code, previous *disasm.Code
+
+	// ops is the change of each instruction, indexed like Code.Insts.
+	ops []asmdiff.Op
+	// was is the index of the old instruction that was changed, -1 for
+	// the others.
+	was []int
+	// removed are the old instructions that were deleted before the
+	// instruction, the ones at the end are at len(Code.Insts).
+	removed map[int][]int
+
+	changed, added, deleted int
+}
+
+// update diffs the code against previous when either changed.
+func (marks *diffMarks) update(code, previous *disasm.Code) {
+	if marks.code == code && marks.previous == previous {
+		return
+	}
+	*marks = diffMarks{
+		code:     code,
+		previous: previous,
+		ops:      make([]asmdiff.Op, len(code.Insts)),
+		was:      make([]int, len(code.Insts)),
+		removed:  map[int][]int{},
+	}
+	for i := range marks.was {
+		marks.was[i] = -1
+	}
+
+	var deleted []int
+	for _, row := range asmdiff.Diff(previous, code) {
+		switch row.Op {
+		case asmdiff.Delete:
+			deleted = append(deleted, row.Old)
+			marks.deleted++
+			continue
+		case asmdiff.Insert:
+			marks.added++
+		case asmdiff.Change:
+			marks.was[row.New] = row.Old
+			marks.changed++
+		}
+		marks.ops[row.New] = row.Op
+		if len(deleted) > 0 {
+			marks.removed[row.New] = deleted
+			deleted = nil
+		}
+	}
+	if len(deleted) > 0 {
+		marks.removed[len(code.Insts)] = deleted
+	}
+}
+
+// summary describes the number of the changes.
+func (marks *diffMarks) summary() string {
+	var parts []string
+	for _, part := range []struct {
+		count int
+		label string
+	}{
+		{marks.changed, "changed"},
+		{marks.added, "added"},
+		{marks.deleted, "removed"},
+	} {
+		if part.count > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", part.count, part.label))
+		}
+	}
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// note returns the old text of a changed instruction or of the ones
+// removed before it, shown in place of the operands.
+func (marks *diffMarks) note(i int) string {
+	if old := marks.was[i]; old >= 0 {
+		return "was " + instText(marks.previous.Insts[old])
+	}
+	if removed := marks.removed[i]; len(removed) > 0 {
+		note := "- " + instText(marks.previous.Insts[removed[0]])
+		if len(removed) > 1 {
+			note += fmt.Sprintf(" and %d more", len(removed)-1)
+		}
+		return note
+	}
+	return ""
+}
+
+// diffNote returns the note of the instruction i in the diff mode.
+func (ui Style) diffNote(diff bool, i int) string {
+	if !diff {
+		return ""
+	}
+	return ui.UI.diff.note(i)
+}
+
+// instText is the text of the instruction without the relocations.
+func instText(inst disasm.Inst) string {
+	text, _, _ := strings.Cut(inst.Text, "\t")
+	return strings.TrimSpace(text)
+}
+
+// SetHistory sets the earlier builds of the function, oldest first, and
+// compares with the latest one.
+func (ui *UI) SetHistory(history []Snapshot) {
+	ui.History = history
+	ui.Compare = len(history) - 1
+}
+
+// diffMode reports whether the instructions are marked against a snapshot.
+func (ui Style) diffMode() bool {
+	return ui.ShowDiff && gui.InRange(ui.Compare, len(ui.History)) && !ui.graphMode()
+}
+
+// compared returns the snapshot of the inline diff.
+func (ui Style) compared() Snapshot {
+	return ui.History[ui.Compare]
+}
+
+// layoutDiffBanner describes the changes since the compared build, the
+// buttons step through the older builds.
+func (ui Style) layoutDiffBanner(gtx layout.Context) layout.Dimensions {
+	for ui.older.Clicked(gtx) {
+		ui.Compare = max(ui.Compare-1, 0)
+	}
+	for ui.newer.Clicked(gtx) {
+		ui.Compare = min(ui.Compare+1, len(ui.History)-1)
+	}
+	snapshot := ui.compared()
+	ui.UI.diff.update(ui.Code, snapshot.Code)
+
+	text := fmt.Sprintf("Since the build loaded at %s: %s.", snapshot.Loaded.Format(time.TimeOnly), ui.UI.diff.summary())
+	if len(ui.History) > 1 {
+		text = fmt.Sprintf("Since the build loaded at %s (%d of %d): %s.", snapshot.Loaded.Format(time.TimeOnly),
+			len(ui.History)-ui.Compare, len(ui.History), ui.UI.diff.summary())
+	}
+	button := func(click *widget.Clickable, label string, enabled bool) layout.FlexChild {
+		return layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if !enabled {
+				gtx = gtx.Disabled()
+			}
+			return click.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return layout.Inset{Left: 6, Right: 6}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					if !enabled {
+						return ui.Theme.Muted(label, 0.85).Layout(gtx)
+					}
+					return ui.Theme.Label(label, 0.85).Layout(gtx)
+				})
+			})
+		})
+	}
+
+	return layout.Stack{}.Layout(gtx,
+		layout.Expanded(func(gtx layout.Context) layout.Dimensions {
+			background := ui.Theme.ContrastBg
+			background.A = 0x30
+			paint.FillShape(gtx.Ops, background, clip.Rect{Max: gtx.Constraints.Min}.Op())
+			return layout.Dimensions{Size: gtx.Constraints.Min}
+		}),
+		layout.Stacked(func(gtx layout.Context) layout.Dimensions {
+			gtx.Constraints.Min.X = gtx.Constraints.Max.X
+			return layout.UniformInset(4).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+					layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+						label := ui.Theme.Label(text, 1)
+						label.MaxLines = 1
+						return label.Layout(gtx)
+					}),
+					button(&ui.older, "Older", ui.Compare > 0),
+					button(&ui.newer, "Newer", ui.Compare < len(ui.History)-1),
+				)
+			})
+		}),
+	)
+}
+
+// layoutDiffMarks paints the background of the instruction i by its change
+// and a line above it where instructions were removed.
+func (ui Style) layoutDiffMarks(gtx layout.Context, c codeColumns, i int) {
+	marks := &ui.UI.diff
+	top := i*c.lineHeight + int(ui.asm.Offset)
+	if i < len(marks.ops) {
+		if bg := DiffColors[marks.ops[i]]; bg != (color.NRGBA{}) {
+			paint.FillShape(gtx.Ops, bg, clip.Rect{
+				Min: image.Pt(int(c.asm.Min), top),
+				Max: image.Pt(int(c.gutter.Min), top+c.lineHeight),
+			}.Op())
+		}
+	}
+	if len(marks.removed[i]) > 0 {
+		removed := DiffColors[asmdiff.Delete]
+		removed.A = 0xc0
+		paint.FillShape(gtx.Ops, removed, clip.Rect{
+			Min: image.Pt(int(c.asm.Min), top-gtx.Metric.Dp(1)),
+			Max: image.Pt(int(c.gutter.Min), top+gtx.Metric.Dp(1)),
+		}.Op())
+	}
+}
@@ -3,6 +3,7 @@ package codeview
 import (
 	"image"
 	"math"
+	"strings"
 
 	"gioui.org/f32"
 	"gioui.org/io/key"
@@ -109,7 +110,15 @@ func (ui Style) layoutAssembly(gtx layout.Context, c codeColumns, hover codeHove
 			Max: image.Pt(int(native.Min)-pad/2+1, gtx.Constraints.Max.Y),
 		}.Op())
 	}
+	diff := ui.diffMode()
+	if diff {
+		ui.UI.diff.update(ui.Code, ui.compared().Code)
+		ui.layoutDiffMarks(gtx, c, len(ui.Code.Insts))
+	}
 	for i, ix := range ui.Code.Insts {
+		if diff {
+			ui.layoutDiffMarks(gtx, c, i)
+		}
 		if ui.Selection.Contains(ViewGoAsm, i) {
 			paint.FillShape(gtx.Ops, ui.Theme.Colors.Selection, clip.Rect{
 				Min: image.Pt(int(asm.Min), i*lineHeight+int(ui.asm.Offset)),
@@ -128,13 +137,14 @@ func (ui Style) layoutAssembly(gtx layout.Context, c codeColumns, hover codeHove
 				Max: image.Pt(int(gutter.Min), (i+1)*lineHeight+int(ui.asm.Offset)),
 			}.Op())
 		}
+		ui.layoutInlineBars(gtx, c, i)
 		gui.SourceLine{
 			TopLeft:    image.Pt(c.goTextLeft, i*lineHeight+int(ui.asm.Offset)),
 			Width:      c.goInstructionWidth,
 			Text:       ix.Text,
 			Spans:      hl.asm[i],
 			TextHeight: ui.TextHeight,
-			Italic:     ix.Call != "",
+			Italic:     ix.Target() != "",
 			Bold:       highlightAsmIndex == i || ui.SelectedAsm == i,
 			Color:      ui.Syntax.Plain,
 		}.Layout(ui.Theme.Theme, gtx)
@@ -151,6 +161,22 @@ func (ui Style) layoutAssembly(gtx layout.Context, c codeColumns, hover codeHove
 					Italic:     true,
 					Color:      ui.Theme.Colors.MutedText,
 				}.Layout(ui.Theme.Theme, gtx)
+			} else if note := ui.diffNote(diff, i); note != "" {
+				gui.SourceLine{
+					TopLeft:    image.Pt(c.commentLeft, i*lineHeight+int(ui.asm.Offset)),
+					Width:      c.commentWidth,
+					Text:       note,
+					TextHeight: ui.TextHeight,
+					Color:      ui.Theme.Colors.MutedText,
+				}.Layout(ui.Theme.Theme, gtx)
+			} else if len(ix.Operands) > 0 {
+				gui.SourceLine{
+					TopLeft:    image.Pt(c.commentLeft, i*lineHeight+int(ui.asm.Offset)),
+					Width:      c.commentWidth,
+					Text:       operandsLabel(ix.Operands),
+					TextHeight: ui.TextHeight,
+					Color:      ui.Theme.Colors.MutedText,
+				}.Layout(ui.Theme.Theme, gtx)
 			}
 		}
 		if ui.ShowNative {
@@ -182,8 +208,15 @@ func (ui Style) layoutAssembly(gtx layout.Context, c codeColumns, hover codeHove
 			}
 		}
 
-		// jump line
-		if ix.RefOffset != 0 {
+		// jump line, a jump with several targets fans out from one layer
+		offsets := ix.TargetOffsets
+		if len(offsets) == 0 {
+			offsets = []int{ix.RefOffset}
+		}
+		for _, offset := range offsets {
+			if offset == 0 {
+				continue
+			}
 			lineWidth := gtx.Metric.Dp(1)
 			align := float32(lineWidth%2) / 2
 			stack := op.Affine(f32.Affine2D{}.Offset(
@@ -193,8 +226,8 @@ func (ui Style) layoutAssembly(gtx layout.Context, c codeColumns, hover codeHove
 			path.Begin(gtx.Ops)
 			path.MoveTo(f32.Pt(float32(pad/2), float32(lineHeight*2/3)))
 			path.LineTo(f32.Pt(float32(-jumpStep*ix.RefStack), float32(lineHeight*2/3)))
-			path.LineTo(f32.Pt(float32(-jumpStep*ix.RefStack), float32(lineHeight/3+ix.RefOffset*lineHeight)))
-			path.LineTo(f32.Pt(float32(-jumpStep/2), float32(lineHeight/3+ix.RefOffset*lineHeight)))
+			path.LineTo(f32.Pt(float32(-jumpStep*ix.RefStack), float32(lineHeight/3+offset*lineHeight)))
+			path.LineTo(f32.Pt(float32(-jumpStep/2), float32(lineHeight/3+offset*lineHeight)))
 			// draw arrow
 			path.Line(f32.Pt(0, float32(lineHeight/4)))
 			path.Line(f32.Pt(float32(lineHeight/3), float32(-lineHeight/4)))
@@ -203,10 +236,10 @@ func (ui Style) layoutAssembly(gtx layout.Context, c codeColumns, hover codeHove
 
 			width := float32(lineWidth)
 			alpha := float32(0.7)
-			if highlightAsmIndex >= 0 && (highlightAsmIndex == i || highlightAsmIndex == i+ix.RefOffset) {
+			if highlightAsmIndex >= 0 && (highlightAsmIndex == i || highlightAsmIndex == i+offset) {
 				width *= 3
 				alpha = 1
-			} else if disasm.LineRangesContain(highlightRanges, i, i+ix.RefOffset) {
+			} else if disasm.LineRangesContain(highlightRanges, i, i+offset) {
 				width *= 3
 			}
 			jumpColor := f32color.HSLA(float32(math.Mod(float64(ix.PC)*math.Phi, 1)), 0.8, 0.4, alpha)
@@ -218,6 +251,20 @@ func (ui Style) layoutAssembly(gtx layout.Context, c codeColumns, hover codeHove
 	asmClip.Pop()
 }
 
+// operandsLabel describes the resolved memory operands in place of a
+// comment, e.g. "→ go:string.*+0x3c3 "hello"".
+func operandsLabel(operands []disasm.Operand) string {
+	var b strings.Builder
+	for i, op := range operands {
+		if i > 0 {
+			b.WriteString("  ")
+		}
+		b.WriteString("→ ")
+		b.WriteString(op.String())
+	}
+	return b.String()
+}
+
 // layoutSource draws the source column: file headers, source lines,
 // selection highlights, and inline comments. It returns the total pixel
 // height of the source content for the scrollbar.
@@ -249,12 +296,16 @@ func (ui Style) layoutSource(gtx layout.Context, c codeColumns, hover codeHover,
 			sourceRow++
 		}
 		paintSourceSelection(sourceRow, top)
+		header, headerColor := src.File, ui.Theme.Colors.MutedText
+		if src.Stale {
+			header, headerColor = src.File+" (stale: "+src.StaleReason+")", ui.Theme.Colors.Error
+		}
 		gui.SourceLine{
 			TopLeft:    image.Pt(int(source.Min), top),
-			Text:       src.File,
+			Text:       header,
 			TextHeight: ui.TextHeight,
 			Bold:       hover.asmIndex == i,
-			Color:      ui.Theme.Colors.MutedText,
+			Color:      headerColor,
 		}.Layout(ui.Theme.Theme, gtx)
 		top += lineHeight
 		sourceRow++
@@ -321,7 +372,8 @@ func (ui Style) layoutScrollbars(gtx layout.Context, c codeColumns, sourceConten
 
 	overflow := float32(lineHeight)
 
-	{
+	// The graph pans on its own.
+	if !ui.graphMode() {
 		stack := clip.Rect{
 			Min: image.Pt(int(jump.Min)-pad, 0),
 			Max: image.Pt(int(gutter.Min), gtx.Constraints.Max.Y),
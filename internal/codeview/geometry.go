@@ -16,6 +16,11 @@ type codeColumns struct {
 	pad        int
 	jumpStep   int
 
+	// profile and sourceProfile are the shares of the samples left of the
+	// jumps and of the source, empty without a profile.
+	profile       gui.Bounds
+	sourceProfile gui.Bounds
+
 	jump   gui.Bounds
 	asm    gui.Bounds
 	native gui.Bounds
@@ -52,18 +57,24 @@ type codeHover struct {
 func (ui Style) columns(gtx layout.Context) codeColumns {
 	// The layout has the following sections:
 	// pad | Jump | pad/2 | Go asm | pad | Native asm | pad | Gutter | pad | Source | pad
+	// With a profile the shares are before the jumps and the source.
 	lineHeight := gui.CodeLineHeightPx(gtx, ui.TextHeight)
 	pad := lineHeight
 	jumpStep := lineHeight / 2
 	jumpWidth := jumpStep * ui.Code.MaxJump
 	gutterWidth := lineHeight * 8
-	fixedWidth := gutterWidth + jumpWidth + 4*pad + pad/2
+	profileWidth := 0
+	if ui.profileShown() {
+		profileWidth = lineHeight * 6
+	}
+	fixedWidth := gutterWidth + jumpWidth + 4*pad + pad/2 + 2*profileWidth
 	if ui.ShowNative {
 		fixedWidth += pad
 	}
 	blocksWidth := max(0, gtx.Constraints.Max.X-fixedWidth)
 
-	jump := gui.BoundsWidth(pad, jumpWidth)
+	profile := gui.BoundsWidth(pad/2, profileWidth)
+	jump := gui.BoundsWidth(pad+profileWidth, jumpWidth)
 	asmWidth := blocksWidth * 40 / 100
 	if ui.ShowNative {
 		asmWidth = blocksWidth * 28 / 100
@@ -77,17 +88,20 @@ func (ui Style) columns(gtx layout.Context) codeColumns {
 		gutter = gui.BoundsWidth(int(native.Max)+pad, gutterWidth)
 		sourceWidth -= int(native.Width())
 	}
-	source := gui.BoundsWidth(int(gutter.Max)+pad, max(0, sourceWidth))
+	sourceProfile := gui.BoundsWidth(int(gutter.Max)+pad, profileWidth)
+	source := gui.BoundsWidth(int(sourceProfile.Max), max(0, sourceWidth))
 
 	c := codeColumns{
-		lineHeight: lineHeight,
-		pad:        pad,
-		jumpStep:   jumpStep,
-		jump:       jump,
-		asm:        asm,
-		native:     native,
-		gutter:     gutter,
-		source:     source,
+		lineHeight:    lineHeight,
+		pad:           pad,
+		jumpStep:      jumpStep,
+		profile:       profile,
+		sourceProfile: sourceProfile,
+		jump:          jump,
+		asm:           asm,
+		native:        native,
+		gutter:        gutter,
+		source:        source,
 	}
 	minimumCommentWidth := lineHeight * 4
 
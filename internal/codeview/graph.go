@@ -0,0 +1,388 @@
+package codeview
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"gioui.org/f32"
+	"gioui.org/font"
+	"gioui.org/io/event"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+
+	"loov.dev/lensm/internal/cfg"
+	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/f32color"
+	"loov.dev/lensm/internal/gui"
+)
+
+// graphMaxColumns limits the width of a block, longer instructions are
+// clipped.
+const graphMaxColumns = 48
+
+// graphView is the control-flow graph of the code with the pixel layout
+// of the blocks. The layout is rebuilt when the code or the text size
+// changes.
+type graphView struct {
+	code       *disasm.Code
+	graph      *cfg.Graph
+	lineHeight int
+
+	// boxes are the bounds of the blocks and size the bounds of the
+	// whole graph.
+	boxes []image.Rectangle
+	size  image.Point
+
+	// offset is the pan of the graph in the view, it's centered on the
+	// selected block, or the entry, when follow is set.
+	offset   f32.Point
+	follow   bool
+	pointer  f32.Point
+	pressed  bool
+	dragged  bool
+	dragFrom f32.Point
+}
+
+// update rebuilds the graph for code and lays out the blocks, measure
+// returns the width of the instruction at an index.
+func (view *graphView) update(code *disasm.Code, lineHeight, maxWidth int, measure func(int) int) {
+	if view.code != code {
+		view.code = code
+		view.graph = cfg.Build(code)
+		view.follow = true
+		view.lineHeight = 0
+	}
+	if view.lineHeight == lineHeight {
+		return
+	}
+	view.lineHeight = lineHeight
+
+	pad := lineHeight
+	gap := lineHeight * 2
+
+	sizes := make([]image.Point, len(view.graph.Blocks))
+	for b, block := range view.graph.Blocks {
+		width, rows := 0, 0
+		for i := block.Start; i < block.End; i++ {
+			if code.Insts[i].Text == "" {
+				continue
+			}
+			width = max(width, min(measure(i), maxWidth))
+			rows++
+		}
+		sizes[b] = image.Pt(width+pad, rows*lineHeight+pad/2)
+	}
+
+	widths := make([]int, len(view.graph.Layers))
+	widest := 0
+	for k, layer := range view.graph.Layers {
+		for i, b := range layer {
+			if i > 0 {
+				widths[k] += gap
+			}
+			widths[k] += sizes[b].X
+		}
+		widest = max(widest, widths[k])
+	}
+
+	// The layers are centered, the back edges pass on the right.
+	view.boxes = make([]image.Rectangle, len(view.graph.Blocks))
+	y := pad
+	for k, layer := range view.graph.Layers {
+		x := pad + (widest-widths[k])/2
+		height := 0
+		for _, b := range layer {
+			view.boxes[b] = image.Rectangle{Min: image.Pt(x, y), Max: image.Pt(x, y).Add(sizes[b])}
+			x += sizes[b].X + gap
+			height = max(height, sizes[b].Y)
+		}
+		y += height + gap
+	}
+	view.size = image.Pt(widest+2*pad+gap, y-gap+pad)
+}
+
+// blockAt returns the block under the position in the view, or -1.
+func (view *graphView) blockAt(position f32.Point) int {
+	p := position.Sub(view.offset)
+	for b, box := range view.boxes {
+		if float32(box.Min.X) <= p.X && p.X < float32(box.Max.X) &&
+			float32(box.Min.Y) <= p.Y && p.Y < float32(box.Max.Y) {
+			return b
+		}
+	}
+	return -1
+}
+
+// clamp keeps the graph in the view of the size, a graph narrower than
+// the view is centered.
+func (view *graphView) clamp(size image.Point) {
+	if view.size.X <= size.X {
+		view.offset.X = float32(size.X-view.size.X) / 2
+	} else {
+		view.offset.X = min(max(view.offset.X, float32(size.X-view.size.X)), 0)
+	}
+	view.offset.Y = min(max(view.offset.Y, float32(min(size.Y-view.size.Y, 0))), 0)
+}
+
+// center pans the view of the size to the block.
+func (view *graphView) center(b int, size image.Point) {
+	if b < 0 || b >= len(view.boxes) {
+		return
+	}
+	box := view.boxes[b]
+	view.offset = f32.Pt(
+		float32(size.X/2-(box.Min.X+box.Max.X)/2),
+		float32(size.Y/3-box.Min.Y),
+	)
+}
+
+// layoutGraph draws the control-flow graph in place of the assembly
+// columns, clicking a block selects its first instruction and scrolls the
+// source to it.
+func (ui Style) layoutGraph(gtx layout.Context, c codeColumns, reveal bool) {
+	view := &ui.UI.graph
+	// The selected instruction is drawn bold, the blocks fit it. The
+	// spans are measured separately, like they are drawn.
+	bold := font.Font{Typeface: "override-monospace,Go,monospace", Weight: font.Black}
+	maxWidth := ui.measureAsmTextWidth(gtx, bold, strings.Repeat("0", graphMaxColumns))
+	view.update(ui.Code, c.lineHeight, maxWidth, func(i int) int {
+		width := 0
+		for _, span := range ui.UI.hl.asm[i] {
+			width += ui.measureAsmTextWidth(gtx, bold, span.Text)
+		}
+		return width
+	})
+
+	size := image.Pt(int(c.gutter.Min), gtx.Constraints.Max.Y)
+	defer clip.Rect{Max: size}.Push(gtx.Ops).Pop()
+	event.Op(gtx.Ops, view)
+
+	for {
+		e, ok := gtx.Event(pointer.Filter{
+			Target:  view,
+			Kinds:   pointer.Move | pointer.Leave | pointer.Press | pointer.Drag | pointer.Release | pointer.Cancel | pointer.Scroll,
+			ScrollX: pointer.ScrollRange{Min: -view.size.X, Max: view.size.X},
+			ScrollY: pointer.ScrollRange{Min: -view.size.Y, Max: view.size.Y},
+		})
+		if !ok {
+			break
+		}
+		ev, ok := e.(pointer.Event)
+		if !ok {
+			continue
+		}
+		switch ev.Kind {
+		case pointer.Move:
+			view.pointer = ev.Position
+		case pointer.Leave:
+			if !view.pressed {
+				view.pointer = f32.Pt(-1, -1)
+			}
+		case pointer.Press:
+			if !ev.Buttons.Contain(pointer.ButtonPrimary) {
+				break
+			}
+			if ui.OnInteract != nil {
+				ui.OnInteract()
+			}
+			view.pointer = ev.Position
+			view.pressed, view.dragged = true, false
+			view.dragFrom = ev.Position
+			gtx.Execute(pointer.GrabCmd{Tag: view, ID: ev.PointerID})
+		case pointer.Drag:
+			if view.pressed {
+				view.offset = view.offset.Add(ev.Position.Sub(view.pointer))
+				if math.Abs(float64(ev.Position.X-view.dragFrom.X)) > 3 || math.Abs(float64(ev.Position.Y-view.dragFrom.Y)) > 3 {
+					view.dragged = true
+				}
+			}
+			view.pointer = ev.Position
+		case pointer.Release:
+			if view.pressed && !view.dragged {
+				if b := view.blockAt(ev.Position); b >= 0 {
+					ui.selectBlock(gtx, c, view.graph.Blocks[b])
+				}
+			}
+			view.pressed = false
+		case pointer.Cancel:
+			view.pressed = false
+		case pointer.Scroll:
+			view.offset = view.offset.Sub(ev.Scroll)
+		}
+	}
+	if reveal || view.follow {
+		view.follow = false
+		view.center(max(view.graph.BlockOf(ui.SelectedAsm), 0), size)
+	}
+	view.clamp(size)
+
+	hovered := view.blockAt(view.pointer)
+	switch {
+	case view.pressed && view.dragged:
+		pointer.CursorGrabbing.Add(gtx.Ops)
+	case hovered >= 0:
+		pointer.CursorPointer.Add(gtx.Ops)
+	}
+
+	selected := view.graph.BlockOf(ui.SelectedAsm)
+	visible := image.Rectangle{Max: size}.Sub(image.Pt(int(view.offset.X), int(view.offset.Y)))
+	stack := op.Affine(f32.Affine2D{}.Offset(view.offset)).Push(gtx.Ops)
+	ui.layoutGraphEdges(gtx, hovered, selected)
+	for b, block := range view.graph.Blocks {
+		if box := view.boxes[b]; box.Overlaps(visible) {
+			ui.layoutGraphBlock(gtx, c, block, box, b == hovered, b == selected)
+		}
+	}
+	stack.Pop()
+}
+
+// layoutGraphBlock draws the box of the block with its instructions.
+func (ui Style) layoutGraphBlock(gtx layout.Context, c codeColumns, block cfg.Block, box image.Rectangle, hovered, selected bool) {
+	radius := c.lineHeight / 4
+	background := ui.Theme.Colors.SecondaryBackground
+	if hovered || selected {
+		background = ui.Theme.Colors.Selection
+	}
+	border, borderWidth := ui.Theme.Colors.Splitter, float32(gtx.Metric.Dp(1))
+	if selected {
+		border, borderWidth = ui.Theme.ContrastBg, float32(gtx.Metric.Dp(2))
+	}
+	paint.FillShape(gtx.Ops, background, clip.UniformRRect(box, radius).Op(gtx.Ops))
+	paint.FillShape(gtx.Ops, border, clip.Stroke{Path: clip.UniformRRect(box, radius).Path(gtx.Ops), Width: borderWidth}.Op())
+
+	top := box.Min.Y + c.lineHeight/4
+	for i := block.Start; i < block.End; i++ {
+		ix := &ui.Code.Insts[i]
+		if ix.Text == "" {
+			continue
+		}
+		gui.SourceLine{
+			TopLeft:    image.Pt(box.Min.X+c.pad/2, top),
+			Width:      box.Dx() - c.pad,
+			Text:       ix.Text,
+			Spans:      ui.UI.hl.asm[i],
+			TextHeight: ui.TextHeight,
+			Italic:     ix.Target() != "",
+			Bold:       ui.SelectedAsm == i,
+			Color:      ui.Syntax.Plain,
+		}.Layout(ui.Theme.Theme, gtx)
+		top += c.lineHeight
+	}
+}
+
+// layoutGraphEdges draws the edges between the blocks, the forward edges
+// leave at the bottom and enter at the top, the back edges of loops pass
+// on the right and are highlighted.
+func (ui Style) layoutGraphEdges(gtx layout.Context, hovered, selected int) {
+	view := &ui.UI.graph
+	graph := view.graph
+	lineHeight := float32(view.lineHeight)
+
+	// The edges of a block are spread along its side.
+	outgoing := make([]int, len(graph.Blocks))
+	incoming := make([]int, len(graph.Blocks))
+	for _, edge := range graph.Edges {
+		if !edge.Back {
+			outgoing[edge.From]++
+			incoming[edge.To]++
+		}
+	}
+	spread := func(box image.Rectangle, k, n int) float32 {
+		return float32(box.Min.X) + float32(box.Dx())*float32(k+1)/float32(n+1)
+	}
+	outAt := make([]int, len(graph.Blocks))
+	inAt := make([]int, len(graph.Blocks))
+	backAt := 0
+
+	arrow := lineHeight / 4
+	for _, edge := range graph.Edges {
+		from, to := view.boxes[edge.From], view.boxes[edge.To]
+
+		var path clip.Path
+		path.Begin(gtx.Ops)
+		var tip f32.Point
+		if edge.Back {
+			start := f32.Pt(float32(from.Max.X), float32(from.Max.Y)-lineHeight/2)
+			end := f32.Pt(float32(to.Max.X), float32(to.Min.Y)+lineHeight/2)
+			// Pass on the right of the blocks in between.
+			right := max(from.Max.X, to.Max.X)
+			for _, layer := range graph.Layers[graph.Blocks[edge.To].Layer : graph.Blocks[edge.From].Layer+1] {
+				right = max(right, view.boxes[layer[len(layer)-1]].Max.X)
+			}
+			side := float32(right) + lineHeight*(1+float32(backAt%4)/2)
+			backAt++
+			corner := lineHeight / 2
+			path.MoveTo(start)
+			path.LineTo(f32.Pt(side-corner, start.Y))
+			path.QuadTo(f32.Pt(side, start.Y), f32.Pt(side, start.Y-corner))
+			path.LineTo(f32.Pt(side, end.Y+corner))
+			path.QuadTo(f32.Pt(side, end.Y), f32.Pt(side-corner, end.Y))
+			path.LineTo(end)
+			tip = end
+			path.MoveTo(tip)
+			path.LineTo(tip.Add(f32.Pt(arrow, -arrow)))
+			path.MoveTo(tip)
+			path.LineTo(tip.Add(f32.Pt(arrow, arrow)))
+		} else {
+			start := f32.Pt(spread(from, outAt[edge.From], outgoing[edge.From]), float32(from.Max.Y))
+			end := f32.Pt(spread(to, inAt[edge.To], incoming[edge.To]), float32(to.Min.Y))
+			outAt[edge.From]++
+			inAt[edge.To]++
+			bend := max((end.Y-start.Y)/2, lineHeight)
+			path.MoveTo(start)
+			path.CubeTo(f32.Pt(start.X, start.Y+bend), f32.Pt(end.X, end.Y-bend), end)
+			tip = end
+			path.MoveTo(tip)
+			path.LineTo(tip.Add(f32.Pt(-arrow, -arrow)))
+			path.MoveTo(tip)
+			path.LineTo(tip.Add(f32.Pt(arrow, -arrow)))
+		}
+
+		width := float32(gtx.Metric.Dp(1))
+		var edgeColor color.NRGBA
+		switch {
+		case edge.Back:
+			edgeColor = f32color.HSLA(0.08, 0.9, 0.5, 1)
+			width *= 2
+		case edge.Kind == cfg.Branch:
+			edgeColor = ui.Theme.ContrastBg
+		default:
+			edgeColor = ui.Theme.Colors.MutedText
+		}
+		if edge.From == hovered || edge.To == hovered || edge.From == selected || edge.To == selected {
+			width *= 2
+		}
+		paint.FillShape(gtx.Ops, edgeColor, clip.Stroke{Path: path.End(), Width: width}.Op())
+	}
+}
+
+// selectBlock selects the first instruction of the block and scrolls the
+// assembly and the source to it.
+func (ui Style) selectBlock(gtx layout.Context, c codeColumns, block cfg.Block) {
+	first := -1
+	for i := block.Start; i < block.End; i++ {
+		if ui.Code.Insts[i].Text != "" {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return
+	}
+	ui.SelectedAsm = first
+	ui.SelectedView = ViewGoAsm
+	ui.SelectedFile = ""
+	ui.SelectedLine = 0
+	ui.asm.Anim.Stop()
+	ui.asm.Offset = float32(gtx.Constraints.Max.Y/3 - first*c.lineHeight)
+
+	ix := ui.Code.Insts[first]
+	if row := sourceRowOf(ui.Code, ix.File, ix.Line); row >= 0 {
+		ui.src.Offset = float32(gtx.Constraints.Max.Y/3 - row*c.lineHeight)
+	}
+}
@@ -18,6 +18,7 @@ import (
 
 // layoutHelp draws the instruction help tooltip for the hovered assembly
 // line, when help is enabled and the user is not selecting or editing.
+// The tooltip also shows the inlined calls of the instruction.
 func (ui Style) layoutHelp(gtx layout.Context, c codeColumns, hover codeHover) {
 	commentEditing := ui.CommentEditor != nil && gtx.Focused(ui.CommentEditor)
 	if !ui.ShowHelp || ui.selecting || commentEditing || !gui.InRange(hover.asmIndex, len(ui.Code.Insts)) {
@@ -32,12 +33,70 @@ func (ui Style) layoutHelp(gtx layout.Context, c codeColumns, hover codeHover) {
 	} else {
 		help, ok = asmhelp.ForInstruction(ui.Code.Arch, inst.Mnemonic, inst.Text)
 	}
+
+	var children []layout.FlexChild
 	if ok {
-		ui.layoutAssemblyHelp(gtx, help, hover.position)
+		children = append(children, ui.assemblyHelp(help)...)
+	}
+	if len(inst.Inlined) > 0 {
+		children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			label := material.Body1(ui.Theme.Theme, inlineText(inst.Inlined))
+			label.Color = ui.Syntax.Comment
+			label.TextSize = ui.TextHeight * 8 / 10
+			if !ok {
+				return label.Layout(gtx)
+			}
+			return layout.Inset{Top: 5}.Layout(gtx, label.Layout)
+		}))
+	}
+	if len(children) > 0 {
+		ui.layoutTooltip(gtx, hover.position, children)
 	}
 }
 
-func (ui Style) layoutAssemblyHelp(gtx layout.Context, help asmhelp.Help, position f32.Point) {
+// assemblyHelp returns the rows describing the instruction.
+func (ui Style) assemblyHelp(help asmhelp.Help) []layout.FlexChild {
+	children := []layout.FlexChild{
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			label := material.Body1(ui.Theme.Theme, help.Mnemonic+" — "+help.Description)
+			label.Font.Weight = font.Bold
+			label.Color = ui.Theme.Colors.Text
+			label.TextSize = ui.TextHeight * 9 / 10
+			return label.Layout(gtx)
+		}),
+	}
+	if help.Explanation != "" {
+		children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			label := material.Body1(ui.Theme.Theme, help.Explanation)
+			label.Font.Typeface = "override-monospace,Go,monospace"
+			label.Color = ui.Syntax.Plain
+			label.TextSize = ui.TextHeight * 9 / 10
+			return layout.Inset{Top: 5}.Layout(gtx, label.Layout)
+		}))
+	}
+	if len(help.Ports) > 0 {
+		children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			label := material.Body1(ui.Theme.Theme, "ports: "+strings.Join(help.Ports, ", "))
+			label.Font.Typeface = "override-monospace,Go,monospace"
+			label.Color = ui.Syntax.Comment
+			label.TextSize = ui.TextHeight * 8 / 10
+			return layout.Inset{Top: 5}.Layout(gtx, label.Layout)
+		}))
+	}
+	if help.Note != "" {
+		children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			label := material.Body1(ui.Theme.Theme, help.Note)
+			label.Font.Style = font.Italic
+			label.Color = ui.Syntax.Comment
+			label.TextSize = ui.TextHeight * 8 / 10
+			return layout.Inset{Top: 5}.Layout(gtx, label.Layout)
+		}))
+	}
+	return children
+}
+
+// layoutTooltip draws the rows in a box next to the pointer position.
+func (ui Style) layoutTooltip(gtx layout.Context, position f32.Point, children []layout.FlexChild) {
 	maxWidth := gtx.Metric.Dp(460)
 	if maxWidth > gtx.Constraints.Max.X-16 {
 		maxWidth = max(0, gtx.Constraints.Max.X-16)
@@ -51,42 +110,6 @@ func (ui Style) layoutAssemblyHelp(gtx layout.Context, help asmhelp.Help, positi
 	contentContext.Constraints.Max = image.Pt(maxWidth, gtx.Metric.Dp(140))
 	macro := op.Record(gtx.Ops)
 	dims := layout.UniformInset(8).Layout(contentContext, func(gtx layout.Context) layout.Dimensions {
-		children := []layout.FlexChild{
-			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-				label := material.Body1(ui.Theme.Theme, help.Mnemonic+" — "+help.Description)
-				label.Font.Weight = font.Bold
-				label.Color = ui.Theme.Colors.Text
-				label.TextSize = ui.TextHeight * 9 / 10
-				return label.Layout(gtx)
-			}),
-		}
-		if help.Explanation != "" {
-			children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-				label := material.Body1(ui.Theme.Theme, help.Explanation)
-				label.Font.Typeface = "override-monospace,Go,monospace"
-				label.Color = ui.Syntax.Plain
-				label.TextSize = ui.TextHeight * 9 / 10
-				return layout.Inset{Top: 5}.Layout(gtx, label.Layout)
-			}))
-		}
-		if len(help.Ports) > 0 {
-			children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-				label := material.Body1(ui.Theme.Theme, "ports: "+strings.Join(help.Ports, ", "))
-				label.Font.Typeface = "override-monospace,Go,monospace"
-				label.Color = ui.Syntax.Comment
-				label.TextSize = ui.TextHeight * 8 / 10
-				return layout.Inset{Top: 5}.Layout(gtx, label.Layout)
-			}))
-		}
-		if help.Note != "" {
-			children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-				label := material.Body1(ui.Theme.Theme, help.Note)
-				label.Font.Style = font.Italic
-				label.Color = ui.Syntax.Comment
-				label.TextSize = ui.TextHeight * 8 / 10
-				return layout.Inset{Top: 5}.Layout(gtx, label.Layout)
-			}))
-		}
 		return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
 	})
 	call := macro.Stop()
@@ -0,0 +1,74 @@
+package codeview
+
+import (
+	"hash/fnv"
+	"image"
+	"image/color"
+	"path"
+	"strconv"
+	"strings"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+
+	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/f32color"
+)
+
+// inlineText describes the inlined calls, innermost first, e.g.
+// "inlined from X at a.go:12 ← Y at b.go:40".
+func inlineText(frames []disasm.InlineFrame) string {
+	var b strings.Builder
+	b.WriteString("inlined from ")
+	for i, frame := range frames {
+		if i > 0 {
+			b.WriteString(" ← ")
+		}
+		b.WriteString(frame.Func)
+		b.WriteString(" at ")
+		b.WriteString(path.Base(frame.File))
+		b.WriteString(":")
+		b.WriteString(strconv.Itoa(frame.Line))
+	}
+	return b.String()
+}
+
+// inlineColors returns a color for each inlined call of an instruction,
+// outermost first. Instructions from the same call get the same color,
+// so the bars group the rows by call.
+func inlineColors(frames []disasm.InlineFrame) []color.NRGBA {
+	if len(frames) == 0 {
+		return nil
+	}
+	colors := make([]color.NRGBA, len(frames))
+	h := fnv.New32a()
+	for depth := range frames {
+		frame := frames[len(frames)-1-depth]
+		_, _ = h.Write([]byte(frame.Func))
+		_, _ = h.Write([]byte(frame.File + ":" + strconv.Itoa(frame.Line)))
+		colors[depth] = f32color.HSLA(float32(h.Sum32()%360)/360, 0.6, 0.5, 0.8)
+	}
+	return colors
+}
+
+// layoutInlineBars draws a bar for each inlined call of the instruction
+// at the left edge of the Go assembly column.
+func (ui Style) layoutInlineBars(gtx layout.Context, c codeColumns, i int) {
+	colors := ui.UI.hl.inline[i]
+	if len(colors) == 0 {
+		return
+	}
+	width := max(gtx.Metric.Dp(2), 1)
+	top := i*c.lineHeight + int(ui.asm.Offset)
+	for depth, barColor := range colors {
+		left := int(c.asm.Min) + depth*(width+1)
+		if left+width > c.goTextLeft {
+			break
+		}
+		paint.FillShape(gtx.Ops, barColor, clip.Rect{
+			Min: image.Pt(left, top),
+			Max: image.Pt(left+width, top+c.lineHeight),
+		}.Op())
+	}
+}
@@ -25,8 +25,10 @@ import (
 func (ui Style) handleInput(gtx layout.Context, c codeColumns) (mouseClicked bool) {
 	lineHeight := c.lineHeight
 	event.Op(gtx.Ops, ui.UI)
+	// The graph handles the pointer in place of the assembly.
+	graph := ui.graphMode()
 	selectionAt := func(position f32.Point) (View, int, bool) {
-		if c.asm.Contains(position.X) {
+		if c.asm.Contains(position.X) && !graph {
 			relative := position.Y - ui.asm.Offset
 			if relative < 0 {
 				return ViewNone, -1, false
@@ -34,7 +36,7 @@ func (ui Style) handleInput(gtx layout.Context, c codeColumns) (mouseClicked boo
 			line := int(relative) / lineHeight
 			return ViewGoAsm, line, gui.InRange(line, len(ui.Code.Insts))
 		}
-		if ui.ShowNative && c.native.Contains(position.X) {
+		if ui.ShowNative && c.native.Contains(position.X) && !graph {
 			relative := position.Y - ui.asm.Offset
 			if relative < 0 {
 				return ViewNone, -1, false
@@ -131,6 +133,7 @@ func (ui Style) handleInput(gtx layout.Context, c codeColumns) (mouseClicked boo
 			case pointer.Scroll:
 				ui.mousePosition = ev.Position
 				switch {
+				case graph && ev.Position.X < c.gutter.Min:
 				case c.asm.Contains(ev.Position.X):
 					ui.asm.Offset -= ev.Scroll.Y
 				case ui.ShowNative && c.native.Contains(ev.Position.X):
@@ -215,7 +218,7 @@ func (ui Style) resolveHover(gtx layout.Context, c codeColumns, mouseClicked boo
 		activateClicked := mouseClicked && ui.SelectedAsm == highlightAsmIndex
 		ix := &ui.Code.Insts[highlightAsmIndex]
 		callTargetHovered := ui.TryOpen != nil &&
-			ix.Call != "" &&
+			ix.Target() != "" &&
 			c.asm.Contains(mousePosition.X) &&
 			mousePosition.X <= float32(c.goTextLeft+c.goInstructionWidth) &&
 			ui.callTargetHit(gtx, *ix, c.goTextLeft, mousePosition.X)
@@ -226,7 +229,11 @@ func (ui Style) resolveHover(gtx layout.Context, c codeColumns, mouseClicked boo
 				ui.SelectedView = ViewGoAsm
 				ui.SelectedFile = ""
 				ui.SelectedLine = 0
-				ui.TryOpen(gtx, ix.Call)
+				if ix.Call == "" && ui.OpenSymbol != nil {
+					ui.OpenSymbol(gtx, ix.Symbol, ix.SymbolOffset)
+				} else {
+					ui.TryOpen(gtx, ix.Target())
+				}
 			}
 		} else if mouseClicked && ix.Text != "" {
 			// Spacer rows (empty synthetic instructions) have no inline
@@ -243,7 +250,7 @@ func (ui Style) resolveHover(gtx layout.Context, c codeColumns, mouseClicked boo
 				gtx.Execute(key.FocusCmd{Tag: ui.CommentEditor})
 			}
 		}
-		if ix.Call == "" && ix.RefOffset != 0 {
+		if ix.Target() == "" && ix.RefOffset != 0 {
 			pointer.CursorPointer.Add(gtx.Ops)
 			if activateClicked {
 				// TODO: smooth scroll
@@ -265,10 +272,11 @@ func (ui Style) resolveHover(gtx layout.Context, c codeColumns, mouseClicked boo
 }
 
 func (ui Style) callTargetHit(gtx layout.Context, inst disasm.Inst, left int, x float32) bool {
-	if inst.Call == "" {
+	target := inst.Target()
+	if target == "" {
 		return false
 	}
-	start := strings.Index(inst.Text, inst.Call)
+	start := strings.Index(inst.Text, target)
 	if start < 0 {
 		return false
 	}
@@ -276,7 +284,7 @@ func (ui Style) callTargetHit(gtx layout.Context, inst disasm.Inst, left int, x
 	// same style, otherwise the hitbox drifts from the visible text
 	// whenever the fallback font is proportional.
 	f := font.Font{Typeface: "override-monospace,Go,monospace", Weight: font.Black, Style: font.Italic}
-	end := start + len(inst.Call)
+	end := start + len(target)
 	targetLeft := left + ui.measureAsmTextWidth(gtx, f, inst.Text[:start])
 	targetRight := left + ui.measureAsmTextWidth(gtx, f, inst.Text[:end])
 	return float32(targetLeft) <= x && x <= float32(targetRight)
@@ -0,0 +1,145 @@
+package codeview
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+
+	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/f32color"
+	"loov.dev/lensm/internal/gui"
+	"loov.dev/lensm/internal/profile"
+)
+
+// profileCache holds the weights of the code in the profile, attributing
+// the samples walks all of them, so it's done once per code and profile.
+type profileCache struct {
+	overlay *profile.Overlay
+	code    *disasm.Code
+	weights *profile.CodeWeights
+}
+
+// profileShown reports whether the profile columns are shown, data has no
+// samples.
+func (ui Style) profileShown() bool {
+	return ui.Profile != nil && ui.Code.Data == nil
+}
+
+// profileWeights returns the weights of the code, nil when it wasn't
+// sampled.
+func (ui Style) profileWeights() *profile.CodeWeights {
+	if !ui.profileShown() {
+		return nil
+	}
+	cache := &ui.UI.profile
+	if cache.overlay != ui.Profile || cache.code != ui.Code {
+		cache.overlay, cache.code = ui.Profile, ui.Code
+		cache.weights = ui.Profile.Code(ui.Code)
+	}
+	return cache.weights
+}
+
+// HeatColor returns the background for a weight that is the fraction of
+// the largest one, from a faint yellow to red.
+func HeatColor(fraction float32) color.NRGBA {
+	fraction = min(max(fraction, 0), 1)
+	return f32color.HSLA(0.15*(1-fraction), 0.9, 0.5, 0.15+0.6*fraction)
+}
+
+// FormatShare formats the weight as a percentage of the total, empty when
+// it's zero.
+func FormatShare(overlay *profile.Overlay, weight int64) string {
+	switch share := overlay.Percent(weight); {
+	case weight == 0:
+		return ""
+	case share < 0.1:
+		return "<0.1%"
+	default:
+		return fmt.Sprintf("%.1f%%", share)
+	}
+}
+
+// layoutAsmProfile draws the flat and the cumulative share of each
+// instruction left of the jumps.
+func (ui Style) layoutAsmProfile(gtx layout.Context, c codeColumns) {
+	weights := ui.profileWeights()
+	if weights == nil {
+		return
+	}
+	defer clip.Rect{
+		Min: image.Pt(int(c.profile.Min), 0),
+		Max: image.Pt(int(c.profile.Max), gtx.Constraints.Max.Y),
+	}.Push(gtx.Ops).Pop()
+	for i, w := range weights.Insts {
+		top := i*c.lineHeight + int(ui.asm.Offset)
+		if top+c.lineHeight < 0 || top > gtx.Constraints.Max.Y {
+			continue
+		}
+		ui.layoutProfileCell(gtx, c, c.profile, top, w, weights.MaxInst)
+	}
+}
+
+// layoutSourceProfile draws the share of each source line left of the
+// source, the rows follow layoutSource.
+func (ui Style) layoutSourceProfile(gtx layout.Context, c codeColumns) {
+	weights := ui.profileWeights()
+	if weights == nil {
+		return
+	}
+	defer clip.Rect{
+		Min: image.Pt(int(c.sourceProfile.Min), 0),
+		Max: image.Pt(int(c.sourceProfile.Max), gtx.Constraints.Max.Y),
+	}.Push(gtx.Ops).Pop()
+	top := int(ui.src.Offset)
+	for i, src := range ui.Code.Source {
+		if i > 0 {
+			top += c.lineHeight
+		}
+		top += c.lineHeight
+		for blockIndex, block := range src.Blocks {
+			if blockIndex > 0 {
+				top += c.lineHeight
+			}
+			for off := range block.Lines {
+				w := weights.Lines[profile.SourceLine{File: src.File, Line: block.From + off}]
+				ui.layoutProfileCell(gtx, c, c.sourceProfile, top, w, weights.MaxLine)
+				top += c.lineHeight
+			}
+		}
+	}
+}
+
+// layoutProfileCell draws the flat share over its heat, followed by the
+// cumulative share when it includes calls.
+func (ui Style) layoutProfileCell(gtx layout.Context, c codeColumns, bounds gui.Bounds, top int, w profile.Weight, maxFlat int64) {
+	if w == (profile.Weight{}) {
+		return
+	}
+	half := int(bounds.Width()) / 2
+	if w.Flat > 0 && maxFlat > 0 {
+		paint.FillShape(gtx.Ops, HeatColor(float32(w.Flat)/float32(maxFlat)), clip.Rect{
+			Min: image.Pt(int(bounds.Min), top),
+			Max: image.Pt(int(bounds.Min)+half, top+c.lineHeight),
+		}.Op())
+	}
+	gui.SourceLine{
+		TopLeft:    image.Pt(int(bounds.Min)+c.pad/4, top),
+		Width:      half - c.pad/4,
+		Text:       FormatShare(ui.Profile, w.Flat),
+		TextHeight: ui.TextHeight,
+		Color:      ui.Syntax.Plain,
+	}.Layout(ui.Theme.Theme, gtx)
+	if w.Cum != w.Flat {
+		gui.SourceLine{
+			TopLeft:    image.Pt(int(bounds.Min)+half+c.pad/4, top),
+			Width:      half - c.pad/4,
+			Text:       FormatShare(ui.Profile, w.Cum),
+			TextHeight: ui.TextHeight,
+			Color:      ui.Theme.Colors.MutedText,
+		}.Layout(ui.Theme.Theme, gtx)
+	}
+}
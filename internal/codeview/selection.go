@@ -129,6 +129,31 @@ func sourceRowCount(code *disasm.Code) int {
 	return count
 }
 
+// sourceRowOf returns the row of the line in the file, or -1 when it's not
+// shown.
+func sourceRowOf(code *disasm.Code, file string, line int) int {
+	if code == nil || file == "" {
+		return -1
+	}
+	row := 0
+	for sourceIndex, source := range code.Source {
+		if sourceIndex > 0 {
+			row++
+		}
+		row++
+		for blockIndex, block := range source.Blocks {
+			if blockIndex > 0 {
+				row++
+			}
+			if source.File == file && block.From <= line && line < block.From+len(block.Lines) {
+				return row + line - block.From
+			}
+			row += len(block.Lines)
+		}
+	}
+	return -1
+}
+
 func sourceRowAtY(code *disasm.Code, scroll float32, lineHeight int, y float32) int {
 	if code == nil || lineHeight <= 0 {
 		return -1
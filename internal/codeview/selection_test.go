@@ -42,3 +42,32 @@ func TestSourceRowAtYRejectsSpaceAboveContent(t *testing.T) {
 		t.Fatalf("sourceRowAtY() = %d, want 0", got)
 	}
 }
+
+func TestSourceRowOf(t *testing.T) {
+	code := &disasm.Code{Source: []disasm.Source{
+		{File: "a.go", Blocks: []disasm.SourceBlock{
+			{LineRange: disasm.LineRange{From: 3, To: 5}, Lines: []string{"x", "y"}},
+			{LineRange: disasm.LineRange{From: 9, To: 10}, Lines: []string{"z"}},
+		}},
+		{File: "b.go", Blocks: []disasm.SourceBlock{
+			{LineRange: disasm.LineRange{From: 1, To: 2}, Lines: []string{"w"}},
+		}},
+	}}
+	// The rows include the file headers and the gaps between the blocks.
+	for _, test := range []struct {
+		file string
+		line int
+		row  int
+	}{
+		{"a.go", 3, 1},
+		{"a.go", 4, 2},
+		{"a.go", 9, 4},
+		{"b.go", 1, 7},
+		{"a.go", 7, -1},
+		{"c.go", 1, -1},
+	} {
+		if got := sourceRowOf(code, test.file, test.line); got != test.row {
+			t.Errorf("sourceRowOf(%s, %d) = %d, want %d", test.file, test.line, got, test.row)
+		}
+	}
+}
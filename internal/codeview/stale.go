@@ -0,0 +1,53 @@
+package codeview
+
+import (
+	"strconv"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+
+	"loov.dev/lensm/internal/disasm"
+)
+
+// staleWarning describes the stale sources of code, or returns an empty
+// string when all of them match the binary.
+func staleWarning(code *disasm.Code) string {
+	var stale []disasm.Source
+	for _, src := range code.Source {
+		if src.Stale {
+			stale = append(stale, src)
+		}
+	}
+	switch len(stale) {
+	case 0:
+		return ""
+	case 1:
+		return "Source may not match the binary, " + stale[0].File + " " + stale[0].StaleReason + "."
+	default:
+		return "Sources may not match the binary, " + strconv.Itoa(len(stale)) + " files changed since it was built, e.g. " +
+			stale[0].File + " " + stale[0].StaleReason + "."
+	}
+}
+
+// layoutStaleBanner draws the warning above the code.
+func (ui Style) layoutStaleBanner(gtx layout.Context, warning string) layout.Dimensions {
+	return layout.Stack{}.Layout(gtx,
+		layout.Expanded(func(gtx layout.Context) layout.Dimensions {
+			background := ui.Theme.Colors.Error
+			background.A = 0x40
+			paint.FillShape(gtx.Ops, background, clip.Rect{Max: gtx.Constraints.Min}.Op())
+			return layout.Dimensions{Size: gtx.Constraints.Min}
+		}),
+		layout.Stacked(func(gtx layout.Context) layout.Dimensions {
+			gtx.Constraints.Min.X = gtx.Constraints.Max.X
+			return layout.UniformInset(4).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				label := ui.Theme.Label(warning, 1)
+				label.MaxLines = 1
+				dims := label.Layout(gtx)
+				dims.Size.X = max(dims.Size.X, gtx.Constraints.Min.X)
+				return dims
+			})
+		}),
+	)
+}
@@ -1,5 +1,10 @@
 package disasm
 
+import (
+	"slices"
+	"strconv"
+)
+
 // Code combines the disassembly and the source code mapping.
 type Code struct {
 	// Name is the name of the code block, e.g. function or method name.
@@ -18,6 +23,9 @@ type Code struct {
 
 	// Source is the slice of a codeblocks that were used to create the instructions.
 	Source []Source
+
+	// Data is set when the instructions are a dump of a data symbol.
+	Data *DataSymbol
 }
 
 // Inst represents a single instruction.
@@ -35,6 +43,9 @@ type Inst struct {
 	File string
 	// Line is the line in the file where this instruction was compiled from.
 	Line int
+	// Inlined is the chain of inlined calls the instruction belongs to,
+	// innermost first. File and Line are then in the innermost function.
+	Inlined []InlineFrame
 
 	// RefPC is a reference to another program counter, e.g. a call.
 	RefPC uint64
@@ -42,17 +53,152 @@ type Inst struct {
 	RefOffset int
 	// RefStack is the depth that the jump line should be drawn at.
 	RefStack int
+	// Targets are the destinations of a jump that has several of them,
+	// e.g. through a jump table, in ascending order. RefPC is the first.
+	Targets []uint64
+	// TargetOffsets are the relative jumps to Targets, like RefOffset,
+	// zero for the ones outside of the code.
+	TargetOffsets []int
 
 	// Call is a named target that should be present in Funcs.
 	// This is used to make the instruction clickable and follow to the
 	// called target.
 	Call string
+	// Symbol is a data symbol that the instruction references, e.g. a
+	// string constant or a global variable, and SymbolOffset the offset
+	// in it. It's clickable like Call.
+	Symbol       string
+	SymbolOffset uint64
+	// Operands are the memory operands that were resolved to symbols,
+	// including the ones that the Text shows only as an address.
+	Operands []Operand
+}
+
+// Target returns the clickable name, the call or the referenced symbol.
+func (inst *Inst) Target() string {
+	if inst.Call != "" {
+		return inst.Call
+	}
+	return inst.Symbol
+}
+
+// SetTargets sets the destinations of a jump with several of them, e.g. the
+// cases of a jump table.
+func (inst *Inst) SetTargets(targets []uint64) {
+	targets = slices.Clone(targets)
+	slices.Sort(targets)
+	inst.Targets = slices.Compact(targets)
+	if len(inst.Targets) > 0 {
+		inst.RefPC = inst.Targets[0]
+	}
+}
+
+// Operand is a memory operand of an instruction, e.g. a global variable or
+// a constant in rodata.
+type Operand struct {
+	// Addr is the address of the operand.
+	Addr uint64
+	// Symbol is the symbol that contains Addr and Offset the offset in it.
+	Symbol string
+	Offset uint64
+	// Value is the decoded constant, a quoted string or a float, empty
+	// when the operand isn't one.
+	Value string
+}
+
+// String formats the operand as symbol+offset followed by the value.
+func (op Operand) String() string {
+	s := op.Symbol
+	if op.Offset != 0 {
+		s += "+0x" + strconv.FormatUint(op.Offset, 16)
+	}
+	if op.Value != "" {
+		s += " " + op.Value
+	}
+	return s
+}
+
+// InlineFrame is a call that was inlined.
+type InlineFrame struct {
+	// Func is the name of the inlined function.
+	Func string
+	// File and Line are the location of the call.
+	File string
+	Line int
+}
+
+// InlineSite is a call where a function was inlined.
+type InlineSite struct {
+	// Caller is the function that contains the inlined code.
+	Caller string
+	// File and Line are the location of the call.
+	File string
+	Line int
+	// Ranges are the instructions that belong to the call.
+	Ranges []PCRange
+	// Size is the number of bytes in Ranges.
+	Size uint64
+}
+
+// CallSite is a call from one function to another.
+type CallSite struct {
+	// Caller is the function that contains the call and Callee the one
+	// that is called.
+	Caller string
+	Callee string
+	// PC is the instruction that calls, or loads the closure.
+	PC   uint64
+	Kind CallKind
+	// File and Line are the location of the call.
+	File string
+	Line int
+}
+
+// CallKind is the way a function is called.
+type CallKind uint8
+
+const (
+	// DirectCall calls the function by name.
+	DirectCall CallKind = iota
+	// TailCall jumps to the function, which returns to the caller's caller.
+	TailCall
+	// ClosureCall loads the closure of the function, which is called
+	// indirectly.
+	ClosureCall
+)
+
+// String returns the name of the kind, e.g. "tail".
+func (kind CallKind) String() string {
+	switch kind {
+	case DirectCall:
+		return "call"
+	case TailCall:
+		return "tail"
+	case ClosureCall:
+		return "closure"
+	default:
+		return "unknown"
+	}
+}
+
+// PCRange is a range of program counters [Start, End).
+type PCRange struct {
+	Start uint64
+	End   uint64
 }
 
 // Source represents code from a single file.
 type Source struct {
-	// File is the file name for the source code.
+	// File is the file name for the source code, as recorded in the binary.
 	File string
+	// Missing is set when the file could not be found, the blocks then
+	// contain a placeholder with empty lines.
+	Missing bool
+	// Stale is set when the file on disk may differ from the one the
+	// binary was compiled from, the lines then may not match the
+	// instructions. StaleReason explains why.
+	Stale       bool
+	StaleReason string
 	// Blocks is a slice of blocks that were used for compiling the instructions.
 	Blocks []SourceBlock
 }
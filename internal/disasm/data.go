@@ -0,0 +1,143 @@
+package disasm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DataFile is implemented by files that list data symbols, e.g. strings,
+// tables and global variables. Loading them shows a hex dump of the
+// contents, see DumpData.
+type DataFile interface {
+	// Data enumerates the data symbols.
+	Data() []Func
+}
+
+// DataSymbols returns the data symbols of file, nil when it has none.
+func DataSymbols(file File) []Func {
+	if data, ok := file.(DataFile); ok {
+		return data.Data()
+	}
+	return nil
+}
+
+// DataSymbol is the contents of a data symbol.
+type DataSymbol struct {
+	Name string
+	// Section is where the symbol is, e.g. "rodata", "data" or "bss".
+	Section string
+	Addr    uint64
+	Size    uint64
+	// Bytes is nil for zero initialized data.
+	Bytes []byte
+	// Refs are the pointers to other symbols, ordered by offset.
+	Refs []DataRef
+}
+
+// DataRef is a pointer in the data.
+type DataRef struct {
+	Offset uint64
+	// Symbol is the referenced symbol and Addend the offset in it.
+	Symbol string
+	Addend uint64
+	// String is set when the pointer is followed by a length, i.e. it's a
+	// Go string header, Value is then the contents.
+	String bool
+	Value  string
+}
+
+// dataRowSize is the number of bytes in a row of the dump.
+const dataRowSize = 16
+
+// DumpData formats the contents of sym as rows of 16 bytes in hex and
+// ASCII. The pointers are appended to the row that contains them, the
+// first one in a row can be followed as Symbol. Repeated rows without
+// pointers are collapsed to "*".
+func DumpData(sym *DataSymbol, arch string) *Code {
+	code := &Code{
+		Name: sym.Name,
+		Arch: arch,
+		Data: sym,
+	}
+
+	refs := sym.Refs
+	var previous []byte
+	collapsed := false
+	zeros := make([]byte, dataRowSize)
+	for off := uint64(0); off < sym.Size; off += dataRowSize {
+		end := min(off+dataRowSize, sym.Size)
+		row := zeros[:end-off]
+		if sym.Bytes != nil && end <= uint64(len(sym.Bytes)) {
+			row = sym.Bytes[off:end]
+		}
+
+		var rowRefs []DataRef
+		for len(refs) > 0 && refs[0].Offset < end {
+			if refs[0].Offset >= off {
+				rowRefs = append(rowRefs, refs[0])
+			}
+			refs = refs[1:]
+		}
+
+		if len(rowRefs) == 0 && previous != nil && string(row) == string(previous) && end < sym.Size {
+			if !collapsed {
+				code.Insts = append(code.Insts, Inst{PC: sym.Addr + off, Text: "*"})
+				collapsed = true
+			}
+			continue
+		}
+		previous, collapsed = row, false
+
+		inst := Inst{
+			PC:   sym.Addr + off,
+			Text: dataRow(off, row),
+		}
+		for i, ref := range rowRefs {
+			if i == 0 {
+				inst.Symbol, inst.SymbolOffset = ref.Symbol, ref.Addend
+			}
+			inst.Text += "  → " + ref.Target()
+			if ref.String {
+				inst.Text += " " + strconv.Quote(ref.Value)
+			}
+		}
+		code.Insts = append(code.Insts, inst)
+	}
+	return code
+}
+
+// Target formats the referenced symbol with the offset.
+func (ref DataRef) Target() string {
+	if ref.Addend == 0 {
+		return ref.Symbol
+	}
+	return ref.Symbol + "+0x" + strconv.FormatUint(ref.Addend, 16)
+}
+
+// dataRow formats a row of the dump, e.g.
+//
+//	+0010  68 65 6c 6c 6f 00 00 00  00 00 00 00 00 00 00 00  |hello...........|
+func dataRow(off uint64, row []byte) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "+%04x ", off)
+	for i := range dataRowSize {
+		if i%8 == 0 {
+			b.WriteByte(' ')
+		}
+		if i < len(row) {
+			fmt.Fprintf(&b, " %02x", row[i])
+		} else {
+			b.WriteString("   ")
+		}
+	}
+	b.WriteString("  |")
+	for _, c := range row {
+		if c < 0x20 || c > 0x7e {
+			c = '.'
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte('|')
+	return b.String()
+}
@@ -0,0 +1,56 @@
+package disasm
+
+import "testing"
+
+func TestDumpData(t *testing.T) {
+	data := append([]byte("hello, world\x00\x00\x00\x00"), make([]byte, 48)...)
+	data = append(data, 1, 2, 3)
+	code := DumpData(&DataSymbol{
+		Name:    "main.table",
+		Section: "data",
+		Addr:    0x1000,
+		Size:    uint64(len(data)),
+		Bytes:   data,
+		Refs: []DataRef{
+			{Offset: 16, Symbol: "go:string.*", Addend: 0x20, String: true, Value: "beta"},
+			{Offset: 24, Symbol: "main.counter"},
+		},
+	}, "amd64")
+
+	want := []Inst{
+		{PC: 0x1000, Text: "+0000   68 65 6c 6c 6f 2c 20 77  6f 72 6c 64 00 00 00 00  |hello, world....|"},
+		{PC: 0x1010, Text: "+0010   00 00 00 00 00 00 00 00  00 00 00 00 00 00 00 00  |................|  → go:string.*+0x20 \"beta\"  → main.counter",
+			Symbol: "go:string.*", SymbolOffset: 0x20},
+		// The bytes are the same as in the previous row.
+		{PC: 0x1020, Text: "*"},
+		{PC: 0x1040, Text: "+0040   01 02 03                                          |...|"},
+	}
+	if len(code.Insts) != len(want) {
+		for _, inst := range code.Insts {
+			t.Log(inst.Text)
+		}
+		t.Fatalf("got %d rows, want %d", len(code.Insts), len(want))
+	}
+	for i, inst := range code.Insts {
+		if inst.PC != want[i].PC || inst.Text != want[i].Text || inst.Symbol != want[i].Symbol || inst.SymbolOffset != want[i].SymbolOffset {
+			t.Errorf("row %d:\ngot  %#x %q %s+%#x\nwant %#x %q %s+%#x", i,
+				inst.PC, inst.Text, inst.Symbol, inst.SymbolOffset,
+				want[i].PC, want[i].Text, want[i].Symbol, want[i].SymbolOffset)
+		}
+	}
+	if code.Data == nil || code.Name != "main.table" {
+		t.Errorf("code doesn't describe the symbol: %+v", code)
+	}
+}
+
+func TestDumpData_ZeroInitialized(t *testing.T) {
+	code := DumpData(&DataSymbol{Name: "main.buf", Section: "bss", Size: 1 << 20}, "amd64")
+	var texts []string
+	for _, inst := range code.Insts {
+		texts = append(texts, inst.Text)
+	}
+	// The first row, the collapsed rows and the last row.
+	if len(texts) != 3 || texts[1] != "*" {
+		t.Errorf("rows = %q", texts)
+	}
+}
@@ -16,9 +16,69 @@ type Func interface {
 	Load(opt Options) (*Code, error)
 }
 
+// Sizer is implemented by functions that know the size of their code
+// without loading it.
+type Sizer interface {
+	// Size returns the size of the code in bytes.
+	Size() uint64
+}
+
+// Addresser is implemented by functions that know the address of their
+// code without loading it.
+type Addresser interface {
+	// Addr returns the PC of the first instruction, as in Inst.PC.
+	Addr() uint64
+}
+
 // Options defines configuration for loading the func.
 type Options struct {
 	// Context is the number of lines that should be additionally included for context.
 	// This can often contain function documentation.
 	Context int
+	// SourceMap resolves the source files, nil uses the recorded paths.
+	SourceMap *SourceMap
+}
+
+// InlineIndex is implemented by files that record where functions were
+// inlined.
+type InlineIndex interface {
+	// InlinedInto returns the calls where the named function was inlined.
+	InlinedInto(name string) ([]InlineSite, error)
+}
+
+// CallIndex is implemented by files that index the calls between the
+// functions.
+type CallIndex interface {
+	// IndexCalls starts indexing in the background, the lookups wait for
+	// it to finish.
+	IndexCalls()
+	// Callers returns the calls to the named function.
+	Callers(name string) ([]CallSite, error)
+	// Callees returns the calls from the named function.
+	Callees(name string) ([]CallSite, error)
+}
+
+// ArchSelector is implemented by files that contain code for several
+// architectures, e.g. universal Mach-O binaries. Funcs returns the functions
+// of the selected architecture.
+type ArchSelector interface {
+	// Archs returns the available architectures in GOARCH notation.
+	Archs() []string
+	// Arch returns the selected architecture.
+	Arch() string
+	// SelectArch selects the architecture.
+	SelectArch(arch string) error
+}
+
+// SelectArch selects the architecture when the file contains several. An
+// empty arch keeps the default.
+func SelectArch(file File, arch string) error {
+	if arch == "" {
+		return nil
+	}
+	selector, ok := file.(ArchSelector)
+	if !ok {
+		return nil
+	}
+	return selector.SelectArch(arch)
 }
@@ -0,0 +1,78 @@
+package disasm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Format describes a file format that can be disassembled.
+type Format struct {
+	// Name is a short human readable name, e.g. "ELF".
+	Name string
+	// Probe reports whether the file header belongs to this format.
+	// The header contains at most HeaderSize bytes.
+	Probe func(header []byte) bool
+	// Open loads the file for disassembly.
+	Open func(path string) (File, error)
+}
+
+// HeaderSize is the number of leading bytes passed to Format.Probe.
+const HeaderSize = 64
+
+// ErrUnknownFormat is returned when no registered format recognizes a file.
+var ErrUnknownFormat = errors.New("unrecognized file format")
+
+var formats struct {
+	mu   sync.RWMutex
+	list []Format
+}
+
+// RegisterFormat adds a format to the registry. Formats are probed in
+// registration order, backends usually call it from init.
+func RegisterFormat(format Format) {
+	formats.mu.Lock()
+	defer formats.mu.Unlock()
+	formats.list = append(formats.list, format)
+}
+
+// Formats returns the registered formats.
+func Formats() []Format {
+	formats.mu.RLock()
+	defer formats.mu.RUnlock()
+	return append([]Format(nil), formats.list...)
+}
+
+// Detect finds the format of the file at path.
+func Detect(path string) (Format, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Format{}, err
+	}
+	defer func() { _ = f.Close() }()
+
+	header := make([]byte, HeaderSize)
+	n, err := io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return Format{}, err
+	}
+	header = header[:n]
+
+	for _, format := range Formats() {
+		if format.Probe(header) {
+			return format, nil
+		}
+	}
+	return Format{}, fmt.Errorf("open %s: %w", path, ErrUnknownFormat)
+}
+
+// Open detects the format of the file at path and loads it.
+func Open(path string) (File, error) {
+	format, err := Detect(path)
+	if err != nil {
+		return nil, err
+	}
+	return format.Open(path)
+}
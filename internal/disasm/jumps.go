@@ -0,0 +1,103 @@
+package disasm
+
+import "sort"
+
+// LayoutJumps appends insts to code.Insts, inserting an empty row before
+// every jump target, and resolves RefOffset, TargetOffsets, RefStack and
+// MaxJump so the jump lines can be drawn next to the instructions. A jump
+// with several targets is drawn on one layer that spans all of them.
+func LayoutJumps(code *Code, insts []Inst) {
+	needRefPCs := map[uint64]struct{}{}
+	for _, ix := range insts {
+		if ix.RefPC != 0 {
+			needRefPCs[ix.RefPC] = struct{}{}
+		}
+		for _, target := range ix.Targets {
+			needRefPCs[target] = struct{}{}
+		}
+	}
+
+	pcToIndex := map[uint64]int{}
+	for _, ix := range insts {
+		if _, ok := needRefPCs[ix.PC]; ok {
+			// add empty line
+			code.Insts = append(code.Insts, Inst{})
+		}
+		pcToIndex[ix.PC] = len(code.Insts)
+		code.Insts = append(code.Insts, ix)
+	}
+
+	type jumpInterval struct {
+		index    int
+		ix       *Inst
+		min, max uint64
+	}
+
+	var jumps []jumpInterval
+	for i := range code.Insts {
+		ix := &code.Insts[i]
+		if ix.RefPC != 0 {
+			target, ok := pcToIndex[ix.RefPC]
+			if !ok {
+				continue
+			}
+			ix.RefOffset = target - i
+
+			jump := jumpInterval{
+				index: i,
+				ix:    ix,
+				min:   min(ix.PC, ix.RefPC),
+				max:   max(ix.PC, ix.RefPC),
+			}
+			if len(ix.Targets) > 0 {
+				ix.TargetOffsets = make([]int, len(ix.Targets))
+				for k, pc := range ix.Targets {
+					if target, ok := pcToIndex[pc]; ok {
+						ix.TargetOffsets[k] = target - i
+						jump.min, jump.max = min(jump.min, pc), max(jump.max, pc)
+					}
+				}
+			}
+			jumps = append(jumps, jump)
+		}
+	}
+
+	sort.Slice(jumps, func(i, k int) bool {
+		if jumps[i].min == jumps[k].min {
+			return jumps[i].max > jumps[k].max
+		}
+		return jumps[i].min < jumps[k].min
+	})
+
+	var stackLayers []uint64
+	insertToStack := func(ix *Inst, max uint64) {
+		found := false
+		for k, pc := range stackLayers {
+			if pc == 0 {
+				stackLayers[k] = max
+				ix.RefStack = k
+				found = true
+				break
+			}
+		}
+		if !found {
+			code.MaxJump = len(stackLayers)
+			ix.RefStack = len(stackLayers)
+			stackLayers = append(stackLayers, max)
+		}
+	}
+
+	for _, jump := range jumps {
+		for i, pc := range stackLayers {
+			if pc <= jump.min {
+				stackLayers[i] = 0
+			}
+		}
+		insertToStack(jump.ix, jump.max)
+	}
+	for i := range code.Insts {
+		ix := &code.Insts[i]
+		ix.RefStack = code.MaxJump - ix.RefStack + 1
+	}
+	code.MaxJump++
+}
@@ -0,0 +1,38 @@
+package disasm
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLayoutJumps_Targets(t *testing.T) {
+	jump := Inst{PC: 0x10, Text: "JMP (R1)"}
+	jump.SetTargets([]uint64{0x18, 0x14, 0x18})
+	code := &Code{}
+	LayoutJumps(code, []Inst{
+		{PC: 0x0c, Text: "CMP $1, R0"},
+		jump,
+		{PC: 0x14, Text: "RET"},
+		{PC: 0x18, Text: "RET"},
+	})
+
+	var texts []string
+	for _, inst := range code.Insts {
+		texts = append(texts, inst.Text)
+	}
+	// An empty row is inserted before each target.
+	if want := []string{"CMP $1, R0", "JMP (R1)", "", "RET", "", "RET"}; !slices.Equal(texts, want) {
+		t.Fatalf("rows = %q, want %q", texts, want)
+	}
+
+	ix := code.Insts[1]
+	if !slices.Equal(ix.Targets, []uint64{0x14, 0x18}) || ix.RefPC != 0x14 {
+		t.Errorf("targets = %#x, RefPC = %#x", ix.Targets, ix.RefPC)
+	}
+	if ix.RefOffset != 2 || !slices.Equal(ix.TargetOffsets, []int{2, 4}) {
+		t.Errorf("RefOffset = %d, TargetOffsets = %d", ix.RefOffset, ix.TargetOffsets)
+	}
+	if code.MaxJump != 1 || ix.RefStack != 1 {
+		t.Errorf("MaxJump = %d, RefStack = %d", code.MaxJump, ix.RefStack)
+	}
+}
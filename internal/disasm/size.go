@@ -0,0 +1,63 @@
+package disasm
+
+// SizeKind is where the bytes of a symbol are in the executable.
+type SizeKind uint8
+
+const (
+	// InText is the code of a function.
+	InText SizeKind = iota
+	// InROData is read-only data, e.g. strings and types.
+	InROData
+	// InData is initialized variables.
+	InData
+	// InPCLN is the pclntab of a function: the name, the entry in the
+	// function table and the pc-value tables, e.g. the line numbers.
+	InPCLN
+)
+
+// String returns the name of the kind, e.g. "rodata".
+func (kind SizeKind) String() string {
+	switch kind {
+	case InText:
+		return "text"
+	case InROData:
+		return "rodata"
+	case InData:
+		return "data"
+	case InPCLN:
+		return "pclntab"
+	default:
+		return "unknown"
+	}
+}
+
+// SymbolSize is the size of a symbol in the executable. A symbol may be
+// listed once per kind.
+type SymbolSize struct {
+	Name string
+	Kind SizeKind
+	Size uint64
+}
+
+// SymbolSizer is implemented by files that can attribute their bytes to
+// the symbols. The bytes that don't belong to any symbol are listed under
+// the name of the section, e.g. the header of the pclntab.
+type SymbolSizer interface {
+	// SymbolSizes returns the sizes of the symbols.
+	SymbolSizes() ([]SymbolSize, error)
+}
+
+// SymbolSizes returns the sizes of the symbols of file, only the text of
+// the functions that implement Sizer when the file isn't a SymbolSizer.
+func SymbolSizes(file File) ([]SymbolSize, error) {
+	if sizer, ok := file.(SymbolSizer); ok {
+		return sizer.SymbolSizes()
+	}
+	var sizes []SymbolSize
+	for _, fn := range file.Funcs() {
+		if sizer, ok := fn.(Sizer); ok {
+			sizes = append(sizes, SymbolSize{Name: fn.Name(), Kind: InText, Size: sizer.Size()})
+		}
+	}
+	return sizes, nil
+}
@@ -0,0 +1,116 @@
+package disasm
+
+import (
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var rxEnvVariable = regexp.MustCompile(`\$[a-zA-Z_]+[a-zA-Z0-9_]+\b`)
+
+func replaceEnvironmentVariables(s string) string {
+	return rxEnvVariable.ReplaceAllStringFunc(s, func(env string) string {
+		replacement := os.Getenv(env[1:])
+		if replacement != "" {
+			return replacement
+		}
+		return env
+	})
+}
+
+// LoadSources loads the specified line sets. Files that cannot be found
+// get a placeholder source, so the instructions still relate to the lines.
+// Files that changed since the binary described by stamp are marked stale.
+func LoadSources(needed map[string]*LineSet, symbolFile string, stamp *BuildStamp, opts Options) []Source {
+	var sources []Source
+	for file, set := range needed {
+		var lines []string
+		resolved, err := opts.SourceMap.Resolve(file)
+		if err == nil {
+			var data []byte
+			data, err = os.ReadFile(resolved)
+			lines = strings.Split(string(data), "\n")
+		}
+
+		source := Source{
+			File:    file,
+			Missing: err != nil,
+		}
+		if !source.Missing {
+			source.StaleReason = stamp.Check(resolved)
+			source.Stale = source.StaleReason != ""
+		}
+		noted := false
+		for _, r := range set.Ranges(opts.Context) {
+			var lineBlock []string
+			if source.Missing {
+				lineBlock = make([]string, r.To-r.From)
+				if !noted && len(lineBlock) > 0 {
+					lineBlock[0] = "// " + err.Error()
+					noted = true
+				}
+			} else {
+				from := min(r.From-1, len(lines))
+				to := min(r.To-1, len(lines))
+				lineBlock = lines[from:to]
+				for i, v := range lineBlock {
+					lineBlock[i] = strings.Replace(v, "\t", "    ", -1)
+				}
+			}
+
+			source.Blocks = append(source.Blocks, SourceBlock{
+				LineRange: r,
+				Lines:     lineBlock,
+			})
+		}
+		sources = append(sources, source)
+	}
+
+	// Sort the sources and prioritize the file where the main symbol is located.
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].File == symbolFile {
+			return true
+		}
+		if sources[j].File == symbolFile {
+			return false
+		}
+		return sources[i].File < sources[j].File
+	})
+
+	return sources
+}
+
+// RelateSources fills SourceBlock.Related from the File and Line of each
+// instruction in code.
+func RelateSources(code *Code) {
+	type fileLine struct {
+		file string
+		line int
+	}
+
+	lineRefs := map[fileLine]*LineSet{}
+	for i, ix := range code.Insts {
+		k := fileLine{file: ix.File, line: ix.Line}
+		n, ok := lineRefs[k]
+		if !ok {
+			n = &LineSet{}
+			lineRefs[k] = n
+		}
+		n.Add(i)
+	}
+	for i := range code.Source {
+		src := &code.Source[i]
+		for k := range src.Blocks {
+			block := &src.Blocks[k]
+			block.Related = make([][]LineRange, len(block.Lines))
+			// Lines may be shorter than the range when the file on disk
+			// has fewer lines than the binary refers to.
+			for off := range block.Lines {
+				if refs, ok := lineRefs[fileLine{file: src.File, line: block.From + off}]; ok {
+					block.Related[off] = refs.RangesZero()
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package disasm
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// SourceMap resolves the source paths recorded in a binary to files on disk.
+//
+// Binaries built with -trimpath, in a container or on CI record paths
+// that don't exist locally. Besides the explicit rules, paths relative to
+// GOROOT, the module cache and the current module are resolved automatically.
+type SourceMap struct {
+	// Rules rewrite path prefixes, they are tried in order.
+	Rules []PathRule
+}
+
+// PathRule replaces the prefix From with To.
+type PathRule struct {
+	From string
+	To   string
+}
+
+// ParsePathRule parses a rule in the form "from=to".
+func ParsePathRule(s string) (PathRule, error) {
+	from, to, ok := strings.Cut(s, "=")
+	if !ok || from == "" {
+		return PathRule{}, fmt.Errorf("invalid path rule %q, expected from=to", s)
+	}
+	return PathRule{From: from, To: to}, nil
+}
+
+func (rule PathRule) String() string { return rule.From + "=" + rule.To }
+
+// cut removes the prefix From from file, it only matches whole path
+// elements, so "/build/src" doesn't rewrite "/build/srcgen/x.go".
+func (rule PathRule) cut(file string) (rest string, ok bool) {
+	rest, ok = strings.CutPrefix(file, rule.From)
+	if !ok || rest == "" || isSeparator(rest[0]) || isSeparator(rule.From[len(rule.From)-1]) {
+		return rest, ok
+	}
+	return "", false
+}
+
+// isSeparator reports whether c separates the path elements, the paths
+// recorded in Windows binaries may use either separator.
+func isSeparator(c byte) bool { return c == '/' || c == '\\' }
+
+// Resolve finds the file on disk for the path recorded in the binary.
+func (m *SourceMap) Resolve(file string) (string, error) {
+	file = replaceEnvironmentVariables(file)
+
+	var candidates []string
+	if m != nil {
+		for _, rule := range m.Rules {
+			if rest, ok := rule.cut(file); ok {
+				candidates = append(candidates, rule.To+rest)
+			}
+		}
+	}
+	candidates = append(candidates, file)
+
+	// Paths without a directory are relative to GOROOT, the module cache or
+	// the current module after -trimpath.
+	if !filepath.IsAbs(file) && !strings.HasPrefix(file, "<") {
+		env := loadGoEnv()
+		slashed := filepath.ToSlash(file)
+		if modulePath, rest, ok := splitModuleVersion(slashed); ok && env.modCache != "" {
+			candidates = append(candidates, filepath.Join(env.modCache, filepath.FromSlash(escapeModulePath(modulePath)+rest)))
+		}
+		if env.goroot != "" {
+			candidates = append(candidates, filepath.Join(env.goroot, "src", filepath.FromSlash(slashed)))
+		}
+		if env.modulePath != "" {
+			if rest, ok := strings.CutPrefix(slashed, env.modulePath+"/"); ok {
+				candidates = append(candidates, filepath.Join(env.moduleDir, filepath.FromSlash(rest)))
+			}
+		}
+	}
+
+	for _, candidate := range candidates {
+		if stat, err := os.Stat(candidate); err == nil && stat.Mode().IsRegular() {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("source %q not found", file)
+}
+
+// splitModuleVersion splits "example.com/mod@v1.2.3/file.go" into the
+// module with the version and the rest of the path.
+func splitModuleVersion(file string) (module, rest string, ok bool) {
+	at := strings.Index(file, "@")
+	if at < 0 {
+		return "", "", false
+	}
+	end := strings.Index(file[at:], "/")
+	if end < 0 {
+		return "", "", false
+	}
+	return file[:at+end], file[at+end:], true
+}
+
+// escapeModulePath escapes upper case letters the same way the module
+// cache does, e.g. "github.com/BurntSushi" becomes "github.com/!burnt!sushi".
+func escapeModulePath(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			b.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// goEnv contains the paths needed to resolve sources.
+type goEnv struct {
+	goroot     string
+	modCache   string
+	modulePath string
+	moduleDir  string
+}
+
+// loadGoEnv queries the go command once, it's fine for it to be missing.
+var loadGoEnv = sync.OnceValue(func() goEnv {
+	var env goEnv
+	out, err := exec.Command("go", "env", "GOROOT", "GOMODCACHE", "GOMOD").Output()
+	if err == nil {
+		lines := strings.Split(string(out), "\n")
+		if len(lines) >= 3 {
+			env.goroot = strings.TrimSpace(lines[0])
+			env.modCache = strings.TrimSpace(lines[1])
+			if gomod := strings.TrimSpace(lines[2]); gomod != "" && gomod != os.DevNull {
+				env.moduleDir = filepath.Dir(gomod)
+				env.modulePath = readModulePath(gomod)
+			}
+		}
+	}
+	if env.goroot == "" {
+		env.goroot = os.Getenv("GOROOT")
+	}
+	if env.modCache == "" {
+		env.modCache = os.Getenv("GOMODCACHE")
+	}
+	return env
+})
+
+// readModulePath returns the module path from a go.mod file.
+func readModulePath(gomod string) string {
+	data, err := os.ReadFile(gomod)
+	if err != nil {
+		return ""
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if rest, ok := strings.CutPrefix(line, "module"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			return path.Clean(strings.Trim(strings.TrimSpace(rest), `"`))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,103 @@
+package disasm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSourceMapRules(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "pkg", "main.go")
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &SourceMap{Rules: []PathRule{
+		{From: "/ci/missing", To: "/nowhere"},
+		{From: "/ci/build", To: dir},
+	}}
+	got, err := m.Resolve("/ci/build/pkg/main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != filepath.Join(dir, "pkg")+"/main.go" {
+		t.Errorf("got %q, want %q", got, file)
+	}
+
+	if _, err := m.Resolve("/ci/other/main.go"); err == nil {
+		t.Errorf("expected error for unmapped path")
+	}
+}
+
+func TestPathRuleBoundary(t *testing.T) {
+	for _, test := range []struct {
+		rule PathRule
+		file string
+		rest string
+		ok   bool
+	}{
+		{PathRule{From: "/build/src"}, "/build/src/x.go", "/x.go", true},
+		{PathRule{From: "/build/src"}, "/build/src", "", true},
+		{PathRule{From: "/build/src"}, "/build/srcgen/x.go", "", false},
+		{PathRule{From: "/build/src/"}, "/build/src/x.go", "x.go", true},
+		{PathRule{From: `C:\build`}, `C:\build\x.go`, `\x.go`, true},
+		{PathRule{From: `C:\build`}, `C:\buildx\x.go`, "", false},
+	} {
+		rest, ok := test.rule.cut(test.file)
+		if rest != test.rest || ok != test.ok {
+			t.Errorf("%v cut %q = %q, %v, want %q, %v", test.rule, test.file, rest, ok, test.rest, test.ok)
+		}
+	}
+}
+
+func TestParsePathRule(t *testing.T) {
+	rule, err := ParsePathRule("/build=/home/user/src=x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.From != "/build" || rule.To != "/home/user/src=x" {
+		t.Errorf("got %#v", rule)
+	}
+	for _, invalid := range []string{"", "/build", "=/src"} {
+		if _, err := ParsePathRule(invalid); err == nil {
+			t.Errorf("ParsePathRule(%q) should fail", invalid)
+		}
+	}
+}
+
+func TestSplitModuleVersion(t *testing.T) {
+	module, rest, ok := splitModuleVersion("github.com/BurntSushi/toml@v1.2.3/decode.go")
+	if !ok || module != "github.com/BurntSushi/toml@v1.2.3" || rest != "/decode.go" {
+		t.Errorf("got %q %q %v", module, rest, ok)
+	}
+	if escaped := escapeModulePath(module); escaped != "github.com/!burnt!sushi/toml@v1.2.3" {
+		t.Errorf("got %q", escaped)
+	}
+	if _, _, ok := splitModuleVersion("fmt/print.go"); ok {
+		t.Errorf("std path should not have a version")
+	}
+}
+
+func TestLoadSourcesMissing(t *testing.T) {
+	lines := &LineSet{}
+	lines.Add(10)
+	sources := LoadSources(map[string]*LineSet{"/ci/missing/main.go": lines}, "", nil, Options{})
+	if len(sources) != 1 {
+		t.Fatalf("got %d sources", len(sources))
+	}
+	source := sources[0]
+	if !source.Missing {
+		t.Errorf("expected Missing")
+	}
+	if len(source.Blocks) == 0 || len(source.Blocks[0].Lines) == 0 {
+		t.Fatalf("expected placeholder block")
+	}
+	if !strings.Contains(source.Blocks[0].Lines[0], "not found") {
+		t.Errorf("placeholder should explain the error, got %q", source.Blocks[0].Lines[0])
+	}
+}
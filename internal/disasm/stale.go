@@ -0,0 +1,137 @@
+package disasm
+
+import (
+	"debug/buildinfo"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// BuildStamp describes the binary the sources are compared against, to
+// detect sources that were edited without rebuilding.
+type BuildStamp struct {
+	// ModTime is the modification time of the binary.
+	ModTime time.Time
+	// MainModule is the path of the main module from the build info.
+	MainModule string
+	// Revision is the vcs.revision build setting.
+	Revision string
+	// Modified is the vcs.modified build setting, the binary was built with
+	// uncommitted changes.
+	Modified bool
+
+	// modules caches the directory of the main module by source directory,
+	// empty for directories outside of it.
+	modules sync.Map
+	// changed caches whether a source file differs from Revision.
+	changed sync.Map
+}
+
+// ReadBuildStamp reads the build stamp of the binary at path, the build
+// info is optional, e.g. for object files and non-Go binaries.
+func ReadBuildStamp(path string) *BuildStamp {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	stamp := &BuildStamp{ModTime: stat.ModTime()}
+
+	info, err := buildinfo.ReadFile(path)
+	if err != nil {
+		return stamp
+	}
+	stamp.MainModule = info.Main.Path
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			stamp.Revision = setting.Value
+		case "vcs.modified":
+			stamp.Modified = setting.Value == "true"
+		}
+	}
+	return stamp
+}
+
+// Check returns why the source file on disk may not match the binary,
+// or an empty string when it's up to date.
+func (stamp *BuildStamp) Check(file string) string {
+	if stamp == nil {
+		return ""
+	}
+	if stat, err := os.Stat(file); err == nil && stat.ModTime().After(stamp.ModTime) {
+		return "modified after the binary was built"
+	}
+	if stamp.Revision != "" && stamp.changedSinceRevision(file) {
+		if stamp.Modified {
+			// The differences may be the uncommitted changes that were
+			// built.
+			return "differs from revision " + shortRevision(stamp.Revision) + ", the binary was built with uncommitted changes"
+		}
+		return "changed since revision " + shortRevision(stamp.Revision) + " the binary was built from"
+	}
+	return ""
+}
+
+// hasGit reports whether git is available to compare the sources with the
+// revision of the binary.
+var hasGit = sync.OnceValue(func() bool {
+	_, err := exec.LookPath("git")
+	return err == nil
+})
+
+// changedSinceRevision reports whether the file of the main module differs
+// from the revision the binary was built from.
+func (stamp *BuildStamp) changedSinceRevision(file string) bool {
+	if changed, ok := stamp.changed.Load(file); ok {
+		return changed.(bool)
+	}
+
+	changed := false
+	if moduleDir := stamp.moduleDir(filepath.Dir(file)); moduleDir != "" && hasGit() {
+		// Exits with 1 when the file differs, other failures, such as an
+		// unknown revision, leave the file as up to date.
+		err := exec.Command("git", "-C", moduleDir, "diff", "--quiet", stamp.Revision, "--", file).Run()
+		var exit *exec.ExitError
+		changed = errors.As(err, &exit) && exit.ExitCode() == 1
+	}
+	stamp.changed.Store(file, changed)
+	return changed
+}
+
+// moduleDir returns the directory of the main module, when dir belongs to
+// it.
+func (stamp *BuildStamp) moduleDir(dir string) string {
+	if moduleDir, ok := stamp.modules.Load(dir); ok {
+		return moduleDir.(string)
+	}
+	moduleDir := findModuleDir(dir)
+	if moduleDir != "" && readModulePath(filepath.Join(moduleDir, "go.mod")) != stamp.MainModule {
+		moduleDir = ""
+	}
+	stamp.modules.Store(dir, moduleDir)
+	return moduleDir
+}
+
+// findModuleDir returns the closest parent of dir containing go.mod.
+func findModuleDir(dir string) string {
+	for {
+		if stat, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil && stat.Mode().IsRegular() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func shortRevision(revision string) string {
+	if len(revision) > 12 {
+		return revision[:12]
+	}
+	return revision
+}
@@ -0,0 +1,113 @@
+package disasm
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildStampModTime(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "main.exe")
+	source := filepath.Join(dir, "main.go")
+	for _, file := range []string{binary, source} {
+		if err := os.WriteFile(file, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	built := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(binary, built, built); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(source, built.Add(-time.Minute), built.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	stamp := ReadBuildStamp(binary)
+	if stamp == nil {
+		t.Fatal("expected stamp")
+	}
+	if reason := stamp.Check(source); reason != "" {
+		t.Errorf("unmodified source reported stale: %q", reason)
+	}
+
+	if err := os.Chtimes(source, built.Add(time.Minute), built.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if reason := stamp.Check(source); reason == "" {
+		t.Errorf("modified source not reported stale")
+	}
+
+	lines := &LineSet{}
+	lines.Add(1)
+	sources := LoadSources(map[string]*LineSet{source: lines}, "", stamp, Options{})
+	if len(sources) != 1 || !sources[0].Stale || sources[0].StaleReason == "" {
+		t.Errorf("LoadSources did not mark stale source: %#v", sources)
+	}
+
+	var none *BuildStamp
+	if reason := none.Check(source); reason != "" {
+		t.Errorf("nil stamp reported %q", reason)
+	}
+}
+
+func TestBuildStampRevision(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("go.mod", "module example.com/m\n")
+	a := write("a.go", "package m\n")
+	b := write("b.go", "package m\n")
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "built")
+	revision := git("rev-parse", "HEAD")
+
+	write("b.go", "package m\n\nfunc B() {}\n")
+	git("commit", "-q", "-am", "later")
+
+	// The modification times are before the build, only the revision
+	// tells the changed files apart.
+	built := time.Now().Add(time.Hour)
+	stamp := &BuildStamp{ModTime: built, MainModule: "example.com/m", Revision: revision}
+	if reason := stamp.Check(a); reason != "" {
+		t.Errorf("unchanged %s reported stale: %q", a, reason)
+	}
+	if reason := stamp.Check(b); !strings.Contains(reason, "changed since revision") {
+		t.Errorf("changed %s reason = %q", b, reason)
+	}
+
+	dirty := &BuildStamp{ModTime: built, MainModule: "example.com/m", Revision: revision, Modified: true}
+	if reason := dirty.Check(a); reason != "" {
+		t.Errorf("unchanged %s in a dirty build reported stale: %q", a, reason)
+	}
+	if reason := dirty.Check(b); !strings.Contains(reason, "uncommitted changes") {
+		t.Errorf("changed %s in a dirty build reason = %q", b, reason)
+	}
+
+	other := &BuildStamp{ModTime: built, MainModule: "example.com/other", Revision: revision}
+	if reason := other.Check(b); reason != "" {
+		t.Errorf("source outside of the main module reported stale: %q", reason)
+	}
+}
@@ -0,0 +1,96 @@
+// Package dwarfline maps addresses to source lines using DWARF line tables.
+package dwarfline
+
+import (
+	"debug/dwarf"
+	"errors"
+	"io"
+	"sort"
+)
+
+// row is a single row of the DWARF line table.
+type row struct {
+	pc   uint64
+	file string
+	line int
+	end  bool
+}
+
+// Table maps addresses to source lines.
+type Table struct {
+	rows []row
+}
+
+// Load collects the line tables of all compile units.
+func Load(data *dwarf.Data) (*Table, error) {
+	table := &Table{}
+
+	r := data.Reader()
+	for {
+		entry, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			break
+		}
+		if entry.Tag != dwarf.TagCompileUnit {
+			r.SkipChildren()
+			continue
+		}
+
+		lr, err := data.LineReader(entry)
+		if err != nil {
+			return nil, err
+		}
+		if lr == nil {
+			continue
+		}
+
+		var le dwarf.LineEntry
+		for {
+			err := lr.Next(&le)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			next := row{pc: le.Address, line: le.Line, end: le.EndSequence}
+			if le.File != nil {
+				next.file = le.File.Name
+			}
+			table.rows = append(table.rows, next)
+		}
+		r.SkipChildren()
+	}
+
+	// Sequence ends share the address with the start of the next sequence,
+	// so they need to come first.
+	sort.SliceStable(table.rows, func(i, k int) bool {
+		a, b := &table.rows[i], &table.rows[k]
+		if a.pc == b.pc {
+			return a.end && !b.end
+		}
+		return a.pc < b.pc
+	})
+	return table, nil
+}
+
+// Find returns the source location of pc.
+func (table *Table) Find(pc uint64) (file string, line int, ok bool) {
+	if table == nil {
+		return "", 0, false
+	}
+	i := sort.Search(len(table.rows), func(i int) bool {
+		return table.rows[i].pc > pc
+	})
+	if i == 0 {
+		return "", 0, false
+	}
+	r := &table.rows[i-1]
+	if r.end || r.line == 0 {
+		return "", 0, false
+	}
+	return r.file, r.line, true
+}
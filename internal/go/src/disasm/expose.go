@@ -1,17 +1,152 @@
 package disasm
 
 import (
+	"cmp"
+	"debug/gosym"
+	"encoding/binary"
+	"fmt"
+	"slices"
 	"strings"
 
 	"loov.dev/lensm/internal/go/src/objfile"
+
+	"golang.org/x/arch/arm/armasm"
+	"golang.org/x/arch/arm64/arm64asm"
+	"golang.org/x/arch/loong64/loong64asm"
+	"golang.org/x/arch/ppc64/ppc64asm"
+	"golang.org/x/arch/riscv64/riscv64asm"
+	"golang.org/x/arch/s390x/s390xasm"
+	"golang.org/x/arch/x86/x86asm"
 )
 
+// DisasmForEntry returns a disassembler for a single entry of the file,
+// DisasmForFile only handles the first one.
+//
+// Binaries linked with -s have no symbol table, the functions are then
+// recovered from the pclntab, which still names every Go function.
+//
+// This is a lensm addition.
+func DisasmForEntry(e *objfile.Entry) (*Disasm, error) {
+	d, err := disasmForEntry(e)
+	if err == nil && len(d.syms) > 0 {
+		return d, nil
+	}
+
+	syms, pclnErr := pclnSymbols(e)
+	if pclnErr != nil || len(syms) == 0 {
+		if err == nil {
+			return d, nil
+		}
+		return nil, err
+	}
+	if d == nil {
+		d, err = disasmForSyms(e, syms)
+		if err != nil {
+			return nil, err
+		}
+	}
+	d.syms = syms
+	return d, nil
+}
+
+// pclnSymbols enumerates the functions in the pclntab.
+func pclnSymbols(e *objfile.Entry) ([]objfile.Sym, error) {
+	pcln, err := e.PCLineTable()
+	if err != nil {
+		return nil, err
+	}
+	table, ok := pcln.(*gosym.Table)
+	if !ok {
+		return nil, fmt.Errorf("no pclntab")
+	}
+
+	syms := make([]objfile.Sym, 0, len(table.Funcs))
+	for _, fn := range table.Funcs {
+		if fn.Sym == nil || fn.End <= fn.Entry {
+			continue
+		}
+		syms = append(syms, objfile.Sym{
+			Name: fn.Name,
+			Addr: fn.Entry,
+			Size: int64(fn.End - fn.Entry),
+			Code: 'T',
+		})
+	}
+	slices.SortFunc(syms, func(a, b objfile.Sym) int { return cmp.Compare(a.Addr, b.Addr) })
+	return syms, nil
+}
+
+// disasmForSyms is disasmForEntry with the symbols provided by the caller.
+func disasmForSyms(e *objfile.Entry, syms []objfile.Sym) (*Disasm, error) {
+	pcln, err := e.PCLineTable()
+	if err != nil {
+		return nil, err
+	}
+
+	textStart, textBytes, err := e.Text()
+	if err != nil {
+		return nil, err
+	}
+
+	goarch := e.GOARCH()
+	disasm := disasms[goarch]
+	byteOrder := byteOrders[goarch]
+	if disasm == nil || byteOrder == nil {
+		return nil, fmt.Errorf("unsupported architecture %q", goarch)
+	}
+
+	return &Disasm{
+		syms:      syms,
+		pcln:      pcln,
+		text:      textBytes,
+		textStart: textStart,
+		textEnd:   textStart + uint64(len(textBytes)),
+		goarch:    goarch,
+		disasm:    disasm,
+		byteOrder: byteOrder,
+	}, nil
+}
+
 func (d *Disasm) Syms() []objfile.Sym { return d.syms }
 func (d *Disasm) TextStart() uint64   { return d.textStart }
 func (d *Disasm) TextEnd() uint64     { return d.textEnd }
 func (d *Disasm) PCLN() objfile.Liner { return d.pcln }
 func (d *Disasm) GOARCH() string      { return d.goarch }
 
+// SetPCLN replaces the line table, e.g. with one built from DWARF for
+// binaries that were not produced by the Go toolchain.
+//
+// This is a lensm addition.
+func (d *Disasm) SetPCLN(pcln objfile.Liner) { d.pcln = pcln }
+
+// AddCode adds code outside of the text section, e.g. the PLT stubs of
+// dynamically linked binaries, with the symbols in and around it. The text
+// is extended to cover the code, the gaps in between are zero.
+//
+// This is a lensm addition.
+func (d *Disasm) AddCode(start uint64, code []byte, syms []objfile.Sym) {
+	if len(code) > 0 {
+		textStart := min(d.textStart, start)
+		textEnd := max(d.textEnd, start+uint64(len(code)))
+		if textStart != d.textStart || textEnd != d.textEnd {
+			text := make([]byte, textEnd-textStart)
+			copy(text[d.textStart-textStart:], d.text)
+			d.text, d.textStart, d.textEnd = text, textStart, textEnd
+		}
+		copy(d.text[start-d.textStart:], code)
+	}
+
+	d.syms = append(d.syms, syms...)
+	slices.SortStableFunc(d.syms, func(a, b objfile.Sym) int { return cmp.Compare(a.Addr, b.Addr) })
+}
+
+// SetSyms replaces the symbols used for naming addresses, syms must be
+// sorted by address. When several symbols start at the same address, the
+// last one is used.
+//
+// This is a lensm addition.
+func (d *Disasm) SetSyms(syms []objfile.Sym) { d.syms = syms }
+
 // DecodeSyntax disassembles the text segment range [start, end), calling f for
 // each instruction with Go assembler syntax and native (GNU) syntax separately.
 //
@@ -21,6 +156,15 @@ func (d *Disasm) GOARCH() string      { return d.goarch }
 // mnemonic is the canonical decoder mnemonic (e.g. "LD1" where the Go syntax
 // spells it "VLD1"), used for reference lookups; empty for undecodable bytes.
 func (d *Disasm) DecodeSyntax(start, end uint64, relocs []objfile.Reloc, f func(pc, size uint64, file string, line int, goText, nativeText, mnemonic string)) {
+	d.DecodeRelative(0, start, end, relocs, f)
+}
+
+// DecodeRelative is DecodeSyntax for position independent code loaded at
+// base, start and end are absolute, but the pc passed to f and the targets
+// in the text are relative to base.
+//
+// This is a lensm addition.
+func (d *Disasm) DecodeRelative(base, start, end uint64, relocs []objfile.Reloc, f func(pc, size uint64, file string, line int, goText, nativeText, mnemonic string)) {
 	if start < d.textStart {
 		start = d.textStart
 	}
@@ -29,9 +173,18 @@ func (d *Disasm) DecodeSyntax(start, end uint64, relocs []objfile.Reloc, f func(
 	}
 	code := d.text[:end-d.textStart]
 	lookup := d.lookup
+	if base != 0 {
+		lookup = func(addr uint64) (string, uint64) {
+			name, symAddr := d.lookup(addr + base)
+			if name == "" {
+				return "", 0
+			}
+			return name, symAddr - base
+		}
+	}
 	for pc := start; pc < end; {
 		i := pc - d.textStart
-		combined, mnemonic, size := d.disasm(code[i:], pc, lookup, d.byteOrder, true)
+		combined, mnemonic, size := d.disasm(code[i:], pc-base, lookup, d.byteOrder, true)
 		goText, nativeText := combined, combined
 		if j := strings.Index(combined, " // "); j >= 0 {
 			goText = strings.TrimRight(combined[:j], " ")
@@ -45,7 +198,354 @@ func (d *Disasm) DecodeSyntax(start, end uint64, relocs []objfile.Reloc, f func(
 			sep = " "
 			relocs = relocs[1:]
 		}
-		f(pc, uint64(size), file, line, goText+reloc, nativeText+reloc, mnemonic)
+		f(pc-base, uint64(size), file, line, goText+reloc, nativeText+reloc, mnemonic)
 		pc += uint64(size)
 	}
 }
+
+// MemoryOperands returns the addresses of the memory operands of the
+// instruction at pc that are known without running the code, i.e. absolute
+// addresses and addresses relative to the pc. On arm64, loong64 and
+// riscv64 the address is split into a page or upper part and an ADD, load
+// or store, it's returned for the second instruction. Other architectures
+// return nil.
+//
+// This is a lensm addition.
+func (d *Disasm) MemoryOperands(pc uint64) []uint64 {
+	if pc < d.textStart || pc >= d.textEnd {
+		return nil
+	}
+	code := d.text[pc-d.textStart:]
+	switch d.goarch {
+	case "386", "amd64":
+		mode := 64
+		if d.goarch == "386" {
+			mode = 32
+		}
+		inst, err := x86asm.Decode(code, mode)
+		if err != nil {
+			return nil
+		}
+		var addrs []uint64
+		for _, arg := range inst.Args {
+			mem, ok := arg.(x86asm.Mem)
+			if !ok {
+				continue
+			}
+			switch {
+			case mem.Base == x86asm.RIP:
+				addrs = append(addrs, pc+uint64(inst.Len)+uint64(mem.Disp))
+			case mem.Base == 0 && mem.Segment == 0 && mem.Disp > 0:
+				if mode == 32 {
+					addrs = append(addrs, uint64(uint32(mem.Disp)))
+				} else {
+					addrs = append(addrs, uint64(mem.Disp))
+				}
+			}
+		}
+		return addrs
+	case "arm":
+		inst, err := armasm.Decode(code, armasm.ModeARM)
+		if err != nil {
+			return nil
+		}
+		var addrs []uint64
+		for _, arg := range inst.Args {
+			mem, ok := arg.(armasm.Mem)
+			if ok && mem.Base == armasm.PC && mem.Mode == armasm.AddrOffset && mem.Sign == 0 {
+				// The pc reads as the address of the instruction plus 8.
+				addrs = append(addrs, uint64(uint32(int64(pc)+8+int64(mem.Offset))))
+			}
+		}
+		return addrs
+	case "arm64":
+		if len(code) < 4 {
+			return nil
+		}
+		if addr, ok := arm64Operand(d.byteOrder, d.text, d.textStart, pc); ok {
+			return []uint64{addr}
+		}
+	case "loong64":
+		if len(code) < 4 {
+			return nil
+		}
+		if addr, ok := loong64Operand(d.byteOrder, d.text, d.textStart, pc); ok {
+			return []uint64{addr}
+		}
+	case "riscv64":
+		if len(code) < 4 {
+			return nil
+		}
+		if addr, ok := riscv64Operand(d.byteOrder, d.text, d.textStart, pc); ok {
+			return []uint64{addr}
+		}
+	}
+	return nil
+}
+
+// loong64Operand decodes the address of the instruction after a PCALAU12I
+// at pc.
+func loong64Operand(order binary.ByteOrder, text []byte, textStart, pc uint64) (uint64, bool) {
+	if pc < textStart+4 {
+		return 0, false
+	}
+	insn := order.Uint32(text[pc-textStart:])
+	pcala := order.Uint32(text[pc-4-textStart:])
+	if pcala&0xfe000000 != 0x1a000000 || insn>>5&0x1f != pcala&0x1f {
+		return 0, false
+	}
+	page := (pc-4)&^0xfff + uint64(signExtend(uint64(pcala>>5&0xfffff), 20)<<12)
+	switch op := insn >> 22; {
+	case op == 0x0b: // ADDI.D
+	case op >= 0xa0 && op <= 0xaf: // LD, ST, FLD, FST
+	default:
+		return 0, false
+	}
+	return page + uint64(signExtend(uint64(insn>>10&0xfff), 12)), true
+}
+
+// riscv64Operand decodes the address of the instruction after an AUIPC at
+// pc.
+func riscv64Operand(order binary.ByteOrder, text []byte, textStart, pc uint64) (uint64, bool) {
+	if pc < textStart+4 {
+		return 0, false
+	}
+	insn := order.Uint32(text[pc-textStart:])
+	auipc := order.Uint32(text[pc-4-textStart:])
+	if auipc&0x7f != 0x17 || insn>>15&0x1f != auipc>>7&0x1f {
+		return 0, false
+	}
+	upper := pc - 4 + uint64(int64(int32(auipc&0xfffff000)))
+	switch insn & 0x7f {
+	case 0x13: // ADDI
+		if insn>>12&7 != 0 {
+			return 0, false
+		}
+		return upper + uint64(int64(int32(insn)>>20)), true
+	case 0x03, 0x07: // loads
+		return upper + uint64(int64(int32(insn)>>20)), true
+	case 0x23, 0x27: // stores
+		imm := uint64(insn>>25)<<5 | uint64(insn>>7&0x1f)
+		return upper + uint64(signExtend(imm, 12)), true
+	}
+	return 0, false
+}
+
+// IndirectJump reports whether the instruction at pc jumps to an address
+// in a register or in memory, which is how jump tables are dispatched.
+// Calls and returns are not jumps. It's implemented for 386, amd64, arm64,
+// loong64 and riscv64.
+//
+// This is a lensm addition.
+func (d *Disasm) IndirectJump(pc uint64) bool {
+	if pc < d.textStart || pc >= d.textEnd {
+		return false
+	}
+	code := d.text[pc-d.textStart:]
+	switch d.goarch {
+	case "386", "amd64":
+		mode := 64
+		if d.goarch == "386" {
+			mode = 32
+		}
+		inst, err := x86asm.Decode(code, mode)
+		if err != nil || inst.Op != x86asm.JMP {
+			return false
+		}
+		switch inst.Args[0].(type) {
+		case x86asm.Reg, x86asm.Mem:
+			return true
+		}
+	case "arm64":
+		inst, err := arm64asm.Decode(code)
+		return err == nil && inst.Op == arm64asm.BR
+	case "loong64":
+		inst, err := loong64asm.Decode(code)
+		if err != nil || inst.Op != loong64asm.JIRL {
+			return false
+		}
+		// JIRL R0, R1, 0 is a return.
+		return inst.Args[0] == loong64asm.R0 && inst.Args[1] != loong64asm.R1
+	case "riscv64":
+		inst, err := riscv64asm.Decode(code)
+		if err != nil || inst.Op != riscv64asm.JALR {
+			return false
+		}
+		// JALR X0, 0(X1) is a return.
+		offset, ok := inst.Args[1].(riscv64asm.RegOffset)
+		return inst.Args[0] == riscv64asm.X0 && ok && offset.OfsReg != riscv64asm.X1
+	}
+	return false
+}
+
+// arm64Operand decodes the address of an ADR, a literal load or the second
+// instruction of an ADRP pair at pc.
+func arm64Operand(order binary.ByteOrder, text []byte, textStart, pc uint64) (uint64, bool) {
+	insn := order.Uint32(text[pc-textStart:])
+	switch {
+	case insn&0x9f000000 == 0x10000000: // ADR
+		return pc + uint64(arm64PCRel(insn)), true
+	case insn&0x3b000000 == 0x18000000: // LDR (literal)
+		return pc + uint64(signExtend(uint64(insn>>5&0x7ffff), 19)<<2), true
+	}
+
+	if pc < textStart+4 {
+		return 0, false
+	}
+	adrp := order.Uint32(text[pc-4-textStart:])
+	if adrp&0x9f000000 != 0x90000000 {
+		return 0, false
+	}
+	page := (pc-4)&^0xfff + uint64(arm64PCRel(adrp)<<12)
+	reg := adrp & 0x1f
+	if insn>>5&0x1f != reg {
+		return 0, false
+	}
+	switch {
+	case insn&0x7f800000 == 0x11000000: // ADD (immediate)
+		imm := uint64(insn >> 10 & 0xfff)
+		if insn>>22&1 != 0 {
+			imm <<= 12
+		}
+		return page + imm, true
+	case insn&0x3b000000 == 0x39000000: // LDR, STR (unsigned offset)
+		scale := insn >> 30
+		if insn>>26&1 != 0 && insn>>23&1 != 0 {
+			// 128-bit SIMD&FP register.
+			scale = 4
+		}
+		return page + uint64(insn>>10&0xfff)<<scale, true
+	}
+	return 0, false
+}
+
+// arm64PCRel decodes the immhi:immlo offset of ADR and ADRP.
+func arm64PCRel(insn uint32) int64 {
+	return signExtend(uint64(insn>>5&0x7ffff)<<2|uint64(insn>>29&3), 21)
+}
+
+// signExtend extends the sign of the low bits of v.
+func signExtend(v uint64, bits uint) int64 {
+	shift := 64 - bits
+	return int64(v<<shift) >> shift
+}
+
+// BranchTarget returns the address that the branch or call at pc jumps to,
+// it's false for other instructions and for the branches to an address in
+// a register.
+//
+// This is a lensm addition.
+func (d *Disasm) BranchTarget(pc uint64) (uint64, bool) {
+	if pc < d.textStart || pc >= d.textEnd {
+		return 0, false
+	}
+	code := d.text[pc-d.textStart:]
+	switch d.goarch {
+	case "386", "amd64":
+		mode := 64
+		if d.goarch == "386" {
+			mode = 32
+		}
+		inst, err := x86asm.Decode(code, mode)
+		if err != nil {
+			return 0, false
+		}
+		for _, arg := range inst.Args {
+			if rel, ok := arg.(x86asm.Rel); ok {
+				target := pc + uint64(inst.Len) + uint64(int64(rel))
+				if mode == 32 {
+					target = uint64(uint32(target))
+				}
+				return target, true
+			}
+		}
+	case "arm":
+		inst, err := armasm.Decode(code, armasm.ModeARM)
+		if err != nil {
+			return 0, false
+		}
+		for _, arg := range inst.Args {
+			if rel, ok := arg.(armasm.PCRel); ok {
+				// The pc reads as the address of the instruction plus 8.
+				return uint64(uint32(pc) + 8 + uint32(rel)), true
+			}
+		}
+	case "arm64":
+		inst, err := arm64asm.Decode(code)
+		if err != nil {
+			return 0, false
+		}
+		switch inst.Op {
+		case arm64asm.B, arm64asm.BL, arm64asm.CBZ, arm64asm.CBNZ, arm64asm.TBZ, arm64asm.TBNZ:
+			for _, arg := range inst.Args {
+				if rel, ok := arg.(arm64asm.PCRel); ok {
+					return pc + uint64(int64(rel)), true
+				}
+			}
+		}
+	case "loong64":
+		inst, err := loong64asm.Decode(code)
+		if err != nil || inst.Op == loong64asm.JIRL {
+			return 0, false
+		}
+		for _, arg := range inst.Args {
+			if off, ok := arg.(loong64asm.OffsetSimm); ok {
+				return pc + uint64(signExtend(uint64(uint32(off.Imm)), uint(off.Width)+2)), true
+			}
+		}
+	case "ppc64", "ppc64le":
+		inst, err := ppc64asm.Decode(code, d.byteOrder)
+		if err != nil {
+			return 0, false
+		}
+		for _, arg := range inst.Args {
+			switch arg := arg.(type) {
+			case ppc64asm.PCRel:
+				return pc + uint64(int64(arg)), true
+			case ppc64asm.Label:
+				return uint64(arg), true
+			}
+		}
+	case "riscv64":
+		inst, err := riscv64asm.Decode(code)
+		if err != nil {
+			return 0, false
+		}
+		switch inst.Op {
+		case riscv64asm.JAL, riscv64asm.BEQ, riscv64asm.BNE, riscv64asm.BLT,
+			riscv64asm.BGE, riscv64asm.BLTU, riscv64asm.BGEU:
+			for _, arg := range inst.Args {
+				if imm, ok := arg.(riscv64asm.Simm); ok {
+					return pc + uint64(int64(imm.Imm)), true
+				}
+			}
+		}
+	case "s390x":
+		inst, err := s390xasm.Decode(code)
+		if err != nil {
+			return 0, false
+		}
+		// The other pc relative instructions load or store, e.g. larl.
+		op := inst.Op.String()
+		if !strings.HasPrefix(op, "br") && !strings.HasSuffix(op, "j") {
+			return 0, false
+		}
+		for _, arg := range inst.Args {
+			var halfwords int64
+			switch arg := arg.(type) {
+			case s390xasm.RegIm12:
+				halfwords = signExtend(uint64(arg), 12)
+			case s390xasm.RegIm16:
+				halfwords = int64(int16(arg))
+			case s390xasm.RegIm24:
+				halfwords = signExtend(uint64(arg), 24)
+			case s390xasm.RegIm32:
+				halfwords = int64(int32(arg))
+			default:
+				continue
+			}
+			return pc + uint64(2*halfwords), true
+		}
+	}
+	return 0, false
+}
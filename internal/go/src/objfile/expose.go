@@ -0,0 +1,21 @@
+package objfile
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// OpenSection opens the executable in r, which is a section of f, e.g. a
+// single architecture of a universal Mach-O binary. The returned file
+// closes f.
+//
+// This is a lensm addition.
+func OpenSection(f *os.File, r io.ReaderAt) (*File, error) {
+	for _, try := range openers {
+		if raw, err := try(r); err == nil {
+			return &File{f, []*Entry{{raw: raw}}}, nil
+		}
+	}
+	return nil, fmt.Errorf("open %s: unrecognized object file", f.Name())
+}
@@ -0,0 +1,51 @@
+package goobj
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"loov.dev/lensm/internal/disasm"
+)
+
+// buildFuncs builds the main package src for linux/arch and loads the named
+// functions, in the same order, along with the file for the other lookups.
+func buildFuncs(t *testing.T, arch, src string, names ...string) (*File, []*disasm.Code) {
+	t.Helper()
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(main, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bin := filepath.Join(dir, arch+".exe")
+	cmd := exec.Command("go", "build", "-o", bin, main)
+	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH="+arch, "CGO_ENABLED=0")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+
+	file, err := Load(bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = file.Close() })
+
+	codes := make([]*disasm.Code, len(names))
+	for _, fn := range file.Funcs() {
+		for i, name := range names {
+			if fn.Name() != name {
+				continue
+			}
+			if codes[i], err = fn.Load(disasm.Options{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for i, code := range codes {
+		if code == nil {
+			t.Fatalf("%s not found", names[i])
+		}
+	}
+	return file, codes
+}
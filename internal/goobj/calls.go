@@ -0,0 +1,169 @@
+package goobj
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/go/src/objfile"
+)
+
+var _ disasm.CallIndex = (*File)(nil)
+
+// rxClosure matches the closure of a function that is loaded for an
+// indirect call, e.g. "LEAQ main.main.func1·f(SB), DX", or in the
+// relocations of object code.
+var rxClosure = regexp.MustCompile(`([^\s,$():]+)·f\b`)
+
+// tailJumps are the mnemonics of the unconditional jumps in the Go syntax,
+// a jump to another function is a tail call.
+var tailJumps = map[string]bool{"JMP": true, "B": true, "BR": true}
+
+// callIndex maps the functions to the calls to and from them.
+type callIndex struct {
+	callers map[string][]disasm.CallSite
+	callees map[string][]disasm.CallSite
+}
+
+// IndexCalls starts indexing the calls of all functions in the background.
+func (file *File) IndexCalls() {
+	file.callsOnce.Do(func() {
+		file.callsDone = make(chan struct{})
+		go func() {
+			defer close(file.callsDone)
+			file.calls = file.indexCalls()
+		}()
+	})
+}
+
+// Callers returns the calls to the named function, grouped by the caller.
+// It waits for the index, which is started when needed.
+func (file *File) Callers(name string) ([]disasm.CallSite, error) {
+	file.IndexCalls()
+	<-file.callsDone
+	return slices.Clone(file.calls.callers[name]), nil
+}
+
+// Callees returns the calls from the named function in the order of the
+// instructions.
+func (file *File) Callees(name string) ([]disasm.CallSite, error) {
+	file.IndexCalls()
+	<-file.callsDone
+	return slices.Clone(file.calls.callees[name]), nil
+}
+
+// indexCalls disassembles all functions, one at a time so that loading code
+// for the view isn't blocked for long. It stops when the file is closed.
+func (file *File) indexCalls() *callIndex {
+	index := &callIndex{
+		callers: map[string][]disasm.CallSite{},
+		callees: map[string][]disasm.CallSite{},
+	}
+	// The closures are read from the executable, object files name them.
+	mem, _ := openMemory(file.region)
+	defer func() { _ = mem.Close() }()
+
+	for _, f := range file.funcs {
+		fn, ok := f.(*Func)
+		if !ok {
+			continue
+		}
+		file.mu.Lock()
+		if file.closed {
+			file.mu.Unlock()
+			break
+		}
+		sites := fn.calls(mem)
+		file.mu.Unlock()
+
+		index.callees[fn.name] = sites
+		for _, site := range sites {
+			index.callers[site.Callee] = append(index.callers[site.Callee], site)
+		}
+	}
+	for _, sites := range index.callers {
+		slices.SortStableFunc(sites, func(a, b disasm.CallSite) int {
+			return strings.Compare(a.Caller, b.Caller)
+		})
+	}
+	return index
+}
+
+// calls returns the calls from fn whose target is known: the direct calls,
+// the jumps to other functions and the closures that are loaded.
+func (fn *Func) calls(mem *memory) []disasm.CallSite {
+	dis, base := fn.disasm, fn.obj.base
+	syms := dis.Syms()
+
+	var sites []disasm.CallSite
+	dis.DecodeRelative(base, fn.sym.Addr, fn.sym.Addr+uint64(fn.sym.Size), fn.sym.Relocs,
+		func(pc, size uint64, file string, line int, text, nativeText, mnemonic string) {
+			site := disasm.CallSite{Caller: fn.name, PC: pc, File: file, Line: line}
+
+			call, _ := branchTarget(dis, base, pc, text)
+			// Offsets are jumps into the middle of a function.
+			if call != "" && !strings.Contains(call, "+") {
+				site.Callee = fn.obj.callName(dis, call)
+				if op, _, _ := strings.Cut(text, " "); tailJumps[op] {
+					// The jump back to the entry after growing the stack.
+					if call == fn.sym.Name {
+						return
+					}
+					site.Kind = disasm.TailCall
+				}
+				sites = append(sites, site)
+				return
+			}
+
+			site.Kind = disasm.ClosureCall
+			add := func(callee string) {
+				callee = fn.obj.callName(dis, callee)
+				// The closure may be loaded in several steps.
+				if n := len(sites); n > 0 && sites[n-1].Kind == disasm.ClosureCall &&
+					sites[n-1].Callee == callee && sites[n-1].Line == line {
+					return
+				}
+				site.Callee = callee
+				sites = append(sites, site)
+			}
+			if match := rxClosure.FindStringSubmatch(text); len(match) > 0 {
+				add(match[1])
+				return
+			}
+			if mem == nil {
+				return
+			}
+			// The linker groups the closures without a name, the first
+			// word of a closure is the function.
+			for _, addr := range dis.MemoryOperands(pc + base) {
+				if !isClosure(syms, addr) {
+					continue
+				}
+				ptr, err := mem.readPointer(addr)
+				if err != nil {
+					continue
+				}
+				if target, ok := symbolAt(syms, ptr); ok && target.Addr == ptr && (target.Code == 'T' || target.Code == 't') {
+					add(target.Name)
+					return
+				}
+			}
+		})
+	return sites
+}
+
+// isClosure reports whether addr is in the closures, which are grouped in
+// go:funcdesc without a size, older linkers name them with the ·f suffix.
+func isClosure(syms []objfile.Sym, addr uint64) bool {
+	i := sort.Search(len(syms), func(i int) bool { return addr < syms[i].Addr })
+	// The group starts at the same address as e.g. runtime.etypes.
+	for k := i - 1; k >= 0 && syms[k].Addr == syms[i-1].Addr; k-- {
+		sym := syms[k]
+		if sym.Name == "go:funcdesc" || strings.HasSuffix(sym.Name, "·f") && addr < sym.Addr+uint64(sym.Size) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package goobj
+
+import (
+	"testing"
+
+	"loov.dev/lensm/internal/disasm"
+)
+
+func TestFile_Callers(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a test binary")
+	}
+
+	file, codes := buildFuncs(t, "amd64", `package main
+
+type T struct{ x int }
+
+//go:noinline
+func (t *T) Get() int { return t.x }
+
+type U struct{ *T }
+
+type Getter interface{ Get() int }
+
+//go:noinline
+func add(a, b int) int { return a + b }
+
+//go:noinline
+func apply(f func() int) int { return f() }
+
+var sink Getter
+
+func main() {
+	println(apply(func() int { return 7 }))
+	sink = U{&T{x: add(1, 2)}}
+	println(sink.Get())
+}
+`, "main.main")
+	src := codes[0].File
+	file.IndexCalls()
+
+	find := func(sites []disasm.CallSite, caller, callee string) (disasm.CallSite, bool) {
+		for _, site := range sites {
+			if site.Caller == caller && site.Callee == callee {
+				return site, true
+			}
+		}
+		return disasm.CallSite{}, false
+	}
+
+	callees, err := file.Callees("main.main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if site, ok := find(callees, "main.main", "main.add"); !ok || site.Kind != disasm.DirectCall || site.File != src || site.Line != 22 {
+		t.Errorf("call to main.add = %+v, %v", site, ok)
+	}
+	if site, ok := find(callees, "main.main", "main.main.func1"); !ok || site.Kind != disasm.ClosureCall || site.Line != 21 {
+		t.Errorf("closure main.main.func1 = %+v, %v in %+v", site, ok, callees)
+	}
+
+	// The promoted method of the embedded pointer jumps to the method.
+	callers, err := file.Callers("main.(*T).Get")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if site, ok := find(callers, "main.(*U).Get", "main.(*T).Get"); !ok || site.Kind != disasm.TailCall {
+		t.Errorf("tail call from main.(*U).Get = %+v, %v in %+v", site, ok, callers)
+	}
+
+	// The jump back to the entry after growing the stack isn't a call.
+	callers, err = file.Callers("main.apply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := find(callers, "main.apply", "main.apply"); ok {
+		t.Errorf("main.apply calls itself: %+v", callers)
+	}
+	if len(callers) != 1 || callers[0].Caller != "main.main" {
+		t.Errorf("callers of main.apply = %+v", callers)
+	}
+}
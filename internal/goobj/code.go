@@ -1,10 +1,7 @@
 package goobj
 
 import (
-	"fmt"
-	"os"
 	"regexp"
-	"sort"
 	"strconv"
 	"strings"
 
@@ -12,55 +9,79 @@ import (
 	godisasm "loov.dev/lensm/internal/go/src/disasm"
 )
 
-var rxRefAbs = regexp.MustCompile(`\s0x[\da-fA-F]+$`)
-var rxRefRel = regexp.MustCompile(`\s-?\d+\(PC\)$`)
 var rxCallOrJump = regexp.MustCompile(`^(?:CALL|JMP)\s+(.+?)\(SB\)`)
 
+// rxBranchSymbol matches the named target of a branch, which is the last
+// operand, e.g. "BL main.add(SB)" or "JAL X5, runtime.morestack(SB)".
+var rxBranchSymbol = regexp.MustCompile(`\s([^\s,()]+)\(SB\)$`)
+
+// rxSymbolRef matches the other references to symbols, e.g. the operand
+// of "LEAQ go:string.*+1234(SB), AX".
+var rxSymbolRef = regexp.MustCompile(`[\s,$]([^\s,$()]+?)(?:\+(0x[\da-fA-F]+|\d+))?\(SB\)`)
+
+// rxRelocCall matches call relocations in unlinked object code, which are
+// appended to the instruction text, e.g. "[1:5]R_CALL:pkg.Func".
+var rxRelocCall = regexp.MustCompile(`\]R_CALL\w*:(\S+)`)
+
+// branchTarget returns the function that the instruction at pc calls or
+// jumps to, and the target relative to base.
+func branchTarget(dis *godisasm.Disasm, base, pc uint64, text string) (call string, refPC uint64) {
+	if target, ok := dis.BranchTarget(pc + base); ok {
+		refPC = target - base
+		if match := rxBranchSymbol.FindStringSubmatch(text); len(match) > 0 {
+			call = match[1]
+		}
+	}
+	if match := rxCallOrJump.FindStringSubmatch(text); len(match) > 0 {
+		call = match[1]
+	}
+	if match := rxRelocCall.FindStringSubmatch(text); len(match) > 0 {
+		// The target is filled in by the linker.
+		call, refPC = match[1], 0
+	}
+	return call, refPC
+}
+
 // Disassemble disassembles the specified symbol.
 func Disassemble(dis *godisasm.Disasm, sym *Func, opts disasm.Options) (*disasm.Code, error) {
 	neededLines := make(map[string]*disasm.LineSet)
 
 	file, _, _ := dis.PCLN().PCToLine(sym.sym.Addr)
-	needRefPCs := map[uint64]struct{}{}
 
 	code := &disasm.Code{
 		Name: sym.Name(),
 		File: file,
 		Arch: dis.GOARCH(),
 	}
+	inlines := sym.obj.inlineTable().lookup(sym.sym.Addr)
+
 	var instructions []disasm.Inst
-	dis.DecodeSyntax(sym.sym.Addr, sym.sym.Addr+uint64(sym.sym.Size), sym.sym.Relocs,
+	// The instructions of position independent code are relative to the
+	// base, the inline table uses the addresses in the binary.
+	base := sym.obj.base
+	dis.DecodeRelative(base, sym.sym.Addr, sym.sym.Addr+uint64(sym.sym.Size), sym.sym.Relocs,
 		func(pc, size uint64, file string, line int, text, nativeText, mnemonic string) {
-			// TODO: find a better way to calculate the jump target
-			var refPC uint64
-			var call string
-			if match := rxRefAbs.FindString(text); match != "" {
-				if target, err := strconv.ParseInt(match[3:], 16, 64); err == nil {
-					refPC = uint64(target)
-				}
-			} else if match := rxRefRel.FindString(text); match != "" {
-				// TODO: this calculation seems incorrect
-				if target, err := strconv.ParseInt(match[1:len(match)-4], 10, 64); err == nil {
-					refPC = uint64(int64(pc) + target*4)
-				} else {
-					panic(err)
-				}
-			} else if match := rxCallOrJump.FindStringSubmatch(text); len(match) > 0 {
-				call = match[1]
+			call, refPC := branchTarget(dis, base, pc, text)
+			call = sym.obj.callName(dis, call)
+			var symbol string
+			var symbolOffset uint64
+			if match := rxSymbolRef.FindStringSubmatch(text); call == "" && len(match) > 0 {
+				symbol = match[1]
+				symbolOffset, _ = strconv.ParseUint(match[2], 0, 64)
 			}
 
-			if refPC != 0 {
-				needRefPCs[refPC] = struct{}{}
-			}
 			instructions = append(instructions, disasm.Inst{
-				PC:         pc,
-				Text:       text,
-				NativeText: nativeText,
-				Mnemonic:   mnemonic,
-				File:       file,
-				Line:       line,
-				Call:       call,
-				RefPC:      refPC,
+				PC:           pc,
+				Text:         text,
+				NativeText:   nativeText,
+				Mnemonic:     mnemonic,
+				File:         file,
+				Line:         line,
+				Inlined:      inlines.Stack(pc + base),
+				Call:         call,
+				Symbol:       symbol,
+				SymbolOffset: symbolOffset,
+				RefPC:        refPC,
 			})
 
 			if file != "" && file != "<autogenerated>" {
@@ -73,88 +94,12 @@ func Disassemble(dis *godisasm.Disasm, sym *Func, opts disasm.Options) (*disasm.
 			}
 		})
 
-	pcToIndex := map[uint64]int{}
-	for _, ix := range instructions {
-		if _, ok := needRefPCs[ix.PC]; ok {
-			// add empty line
-			code.Insts = append(code.Insts, disasm.Inst{})
-		}
-		pcToIndex[ix.PC] = len(code.Insts)
-		code.Insts = append(code.Insts, ix)
+	if len(sym.sym.Relocs) == 0 {
+		sym.obj.resolveOperands(dis, sym.sym, base, instructions)
+		sym.obj.resolveJumpTables(dis, sym.sym, base, instructions)
 	}
 
-	type jumpInterval struct {
-		index    int
-		ix       *disasm.Inst
-		min, max uint64
-	}
-
-	var jumps []jumpInterval
-	for i := range code.Insts {
-		ix := &code.Insts[i]
-		if ix.RefPC != 0 {
-			target, ok := pcToIndex[ix.RefPC]
-			if !ok {
-				continue
-			}
-			ix.RefOffset = target - i
-
-			if ix.PC <= ix.RefPC {
-				jumps = append(jumps, jumpInterval{
-					index: i,
-					ix:    ix,
-					min:   ix.PC,
-					max:   ix.RefPC,
-				})
-			} else {
-				jumps = append(jumps, jumpInterval{
-					index: i,
-					ix:    ix,
-					min:   ix.RefPC,
-					max:   ix.PC,
-				})
-			}
-		}
-	}
-
-	sort.Slice(jumps, func(i, k int) bool {
-		if jumps[i].min == jumps[k].min {
-			return jumps[i].max > jumps[k].max
-		}
-		return jumps[i].min < jumps[k].min
-	})
-
-	var stackLayers []uint64
-	insertToStack := func(ix *disasm.Inst, max uint64) {
-		found := false
-		for k, pc := range stackLayers {
-			if pc == 0 {
-				stackLayers[k] = max
-				ix.RefStack = k
-				found = true
-				break
-			}
-		}
-		if !found {
-			code.MaxJump = len(stackLayers)
-			ix.RefStack = len(stackLayers)
-			stackLayers = append(stackLayers, max)
-		}
-	}
-
-	for _, jump := range jumps {
-		for i, pc := range stackLayers {
-			if pc <= jump.min {
-				stackLayers[i] = 0
-			}
-		}
-		insertToStack(jump.ix, jump.max)
-	}
-	for i := range code.Insts {
-		ix := &code.Insts[i]
-		ix.RefStack = code.MaxJump - ix.RefStack + 1
-	}
-	code.MaxJump++
+	disasm.LayoutJumps(code, instructions)
 
 	// remove trailing interrupts from funcs
 	for len(code.Insts) > 0 &&
@@ -164,91 +109,10 @@ func Disassemble(dis *godisasm.Disasm, sym *Func, opts disasm.Options) (*disasm.
 	}
 
 	// load sources
-	code.Source = LoadSources(neededLines, code.File, opts.Context)
+	code.Source = disasm.LoadSources(neededLines, code.File, sym.obj.stamp, opts)
 
 	// create a mapping from source code to disassembly
-	type fileLine struct {
-		file string
-		line int
-	}
-
-	lineRefs := map[fileLine]*disasm.LineSet{}
-	for i, ix := range code.Insts {
-		k := fileLine{file: ix.File, line: ix.Line}
-		n, ok := lineRefs[k]
-		if !ok {
-			n = &disasm.LineSet{}
-			lineRefs[k] = n
-		}
-		n.Add(i)
-	}
-	for i := range code.Source {
-		src := &code.Source[i]
-		for k := range src.Blocks {
-			block := &src.Blocks[k]
-			block.Related = make([][]disasm.LineRange, len(block.Lines))
-			for line := block.From; line <= block.To; line++ { // todo check: line <= block.To
-				if refs, ok := lineRefs[fileLine{file: src.File, line: line}]; ok {
-					block.Related[line-block.From] = refs.RangesZero()
-				}
-			}
-		}
-	}
+	disasm.RelateSources(code)
 
 	return code, nil
 }
-
-var rxEnvVariable = regexp.MustCompile(`\$[a-zA-Z_]+[a-zA-Z0-9_]+\b`)
-
-func replaceEnvironmentVariables(s string) string {
-	return rxEnvVariable.ReplaceAllStringFunc(s, func(env string) string {
-		replacement := os.Getenv(env[1:])
-		if replacement != "" {
-			return replacement
-		}
-		return env
-	})
-}
-
-// LoadSources loads the specified line sets.
-func LoadSources(needed map[string]*disasm.LineSet, symbolFile string, context int) []disasm.Source {
-	var sources []disasm.Source
-	for file, set := range needed {
-		data, err := os.ReadFile(replaceEnvironmentVariables(file))
-		if err != nil {
-			// TODO: should we create a stub source block instead?
-			fmt.Fprintf(os.Stderr, "unable to load source from %q: %v\n", file, err)
-			continue
-		}
-		lines := strings.Split(string(data), "\n")
-		source := disasm.Source{
-			File: file,
-		}
-		for _, r := range set.Ranges(context) {
-			to := min(r.To-1, len(lines))
-			lineBlock := lines[r.From-1 : to]
-			for i, v := range lineBlock {
-				lineBlock[i] = strings.Replace(v, "\t", "    ", -1)
-			}
-
-			source.Blocks = append(source.Blocks, disasm.SourceBlock{
-				LineRange: r,
-				Lines:     lineBlock,
-			})
-		}
-		sources = append(sources, source)
-	}
-
-	// Sort the sources and prioritize the file where the main symbol is located.
-	sort.Slice(sources, func(i, j int) bool {
-		if sources[i].File == symbolFile {
-			return true
-		}
-		if sources[j].File == symbolFile {
-			return false
-		}
-		return sources[i].File < sources[j].File
-	})
-
-	return sources
-}
@@ -0,0 +1,136 @@
+package goobj
+
+import "testing"
+
+func TestDisassemble_BranchTargets(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds test binaries")
+	}
+
+	const src = `package main
+
+//go:noinline
+func sum(xs []int) (t int) {
+	for _, x := range xs {
+		if x > 10 {
+			t += add(x, 1)
+		} else {
+			t -= x
+		}
+	}
+	return t
+}
+
+//go:noinline
+func add(a, b int) int { return a + b }
+
+func main() { println(sum([]int{1, 20, 3})) }
+`
+
+	for _, arch := range []string{"386", "amd64", "arm", "arm64", "loong64", "ppc64", "ppc64le", "riscv64", "s390x"} {
+		t.Run(arch, func(t *testing.T) {
+			_, codes := buildFuncs(t, arch, src, "main.add", "main.sum")
+			add, sum := codes[0], codes[1]
+			// The first row is a spacer when a jump targets the entry.
+			entry := add.Insts[0].PC
+			if add.Insts[0].Text == "" {
+				entry = add.Insts[1].PC
+			}
+
+			pcs := map[uint64]bool{}
+			for _, inst := range sum.Insts {
+				if inst.Text != "" {
+					pcs[inst.PC] = true
+				}
+			}
+			var calls, loops int
+			for _, inst := range sum.Insts {
+				switch {
+				case inst.Call == "main.add":
+					calls++
+					if inst.RefPC != entry {
+						t.Errorf("%s: target %#x, want main.add at %#x", inst.Text, inst.RefPC, entry)
+					}
+				case inst.Call == "" && inst.RefPC != 0:
+					if !pcs[inst.RefPC] {
+						t.Errorf("%s: target %#x is not an instruction in main.sum", inst.Text, inst.RefPC)
+					}
+					if inst.RefOffset == 0 {
+						t.Errorf("%s: no jump line", inst.Text)
+					}
+					if inst.RefPC < inst.PC {
+						loops++
+					}
+				}
+			}
+			if calls != 1 {
+				t.Errorf("found %d calls to main.add, want 1", calls)
+			}
+			if loops == 0 {
+				t.Error("the loop has no backward jump")
+			}
+		})
+	}
+}
+
+func TestDisassemble_JumpTables(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds test binaries")
+	}
+
+	const src = `package main
+
+var sink int
+
+//go:noinline
+func pick(x int) {
+	switch x {
+	case 0:
+		sink += 11
+	case 1:
+		sink *= 27
+	case 2:
+		sink -= 35
+	case 3:
+		sink ^= 49
+	case 4:
+		sink |= 53
+	case 5:
+		sink &= 67
+	case 6:
+		sink <<= 1
+	case 7:
+		sink >>= 2
+	case 8:
+		sink = 97
+	}
+}
+
+func main() { pick(3); println(sink) }
+`
+
+	// The compiler emits jump tables on these architectures.
+	for _, arch := range []string{"amd64", "arm64", "loong64"} {
+		t.Run(arch, func(t *testing.T) {
+			_, codes := buildFuncs(t, arch, src, "main.pick")
+			code := codes[0]
+
+			for i, inst := range code.Insts {
+				if len(inst.Targets) == 0 {
+					continue
+				}
+				// One target for each case.
+				if len(inst.Targets) != 9 {
+					t.Errorf("%s: %d targets, want 9", inst.Text, len(inst.Targets))
+				}
+				for k, offset := range inst.TargetOffsets {
+					if offset == 0 || code.Insts[i+offset].PC != inst.Targets[k] {
+						t.Errorf("%s: target %#x isn't laid out", inst.Text, inst.Targets[k])
+					}
+				}
+				return
+			}
+			t.Error("jump table not found")
+		})
+	}
+}
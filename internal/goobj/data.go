@@ -0,0 +1,166 @@
+package goobj
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/go/src/objfile"
+)
+
+var _ disasm.DataFile = (*File)(nil)
+
+// Data is a data symbol in an executable, e.g. a variable or a table.
+type Data struct {
+	obj *File
+	sym objfile.Sym
+}
+
+func (data *Data) Name() string { return data.sym.Name }
+
+func (data *Data) Load(opts disasm.Options) (*disasm.Code, error) {
+	return data.obj.LoadData(data)
+}
+
+func (file *File) Data() []disasm.Func { return file.data }
+
+// maxString is the longest Go string that is decoded in the data.
+const maxString = 4 << 10
+
+// isData reports whether the nm code is for rodata, data or bss.
+func isData(code rune) bool {
+	switch code {
+	case 'R', 'r', 'D', 'd', 'B', 'b':
+		return true
+	}
+	return false
+}
+
+// sizeGroups gives the symbols that group many values, e.g. go:string.*
+// and type:*, the size up to the next symbol. The linker doesn't record
+// one, so the references into them would be left unnamed.
+func sizeGroups(syms []objfile.Sym) []objfile.Sym {
+	syms = slices.Clone(syms)
+	for i := range syms {
+		sym := &syms[i]
+		if sym.Size != 0 || !isData(sym.Code) || !strings.HasSuffix(sym.Name, "*") {
+			continue
+		}
+		for _, next := range syms[i+1:] {
+			if next.Addr > sym.Addr {
+				sym.Size = int64(next.Addr - sym.Addr)
+				break
+			}
+		}
+	}
+	// Lookups use the last symbol at an address, which should be the
+	// group instead of the markers, e.g. runtime.rodata.
+	slices.SortStableFunc(syms, func(a, b objfile.Sym) int {
+		return cmp.Or(cmp.Compare(a.Addr, b.Addr), cmp.Compare(a.Size, b.Size))
+	})
+	return syms
+}
+
+// symbolAt returns the symbol that contains addr.
+func symbolAt(syms []objfile.Sym, addr uint64) (objfile.Sym, bool) {
+	i := sort.Search(len(syms), func(i int) bool { return addr < syms[i].Addr })
+	if i > 0 {
+		sym := syms[i-1]
+		if sym.Addr != 0 && addr < sym.Addr+uint64(sym.Size) {
+			return sym, true
+		}
+	}
+	return objfile.Sym{}, false
+}
+
+// LoadData reads the contents of the data symbol.
+func (file *File) LoadData(data *Data) (*disasm.Code, error) {
+	mem, err := openMemory(file.region)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = mem.Close() }()
+
+	sym := data.sym
+	section := mem.sectionAt(sym.Addr)
+	if section == nil {
+		return nil, fmt.Errorf("%s: address %#x is not mapped", sym.Name, sym.Addr)
+	}
+	dump := &disasm.DataSymbol{
+		Name:    sym.Name,
+		Section: strings.TrimLeft(section.name, "._"),
+		Addr:    sym.Addr - file.base,
+		Size:    uint64(sym.Size),
+	}
+	if section.data != nil {
+		size := min(dump.Size, section.addr+section.size-sym.Addr)
+		dump.Bytes, err = mem.read(sym.Addr, size)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", sym.Name, err)
+		}
+		dump.Refs = file.dataRefs(mem, sym.Addr, dump.Bytes)
+	}
+
+	return disasm.DumpData(dump, file.first.GOARCH()), nil
+}
+
+// dataRefs finds the pointers to symbols in data, which is at addr. The
+// pointers into rodata that are followed by a length, which is the layout
+// of a Go string, are decoded when the bytes are printable text.
+func (file *File) dataRefs(mem *memory, addr uint64, data []byte) []disasm.DataRef {
+	syms := file.first.Syms()
+	ptrSize := mem.ptrSize
+	word := func(off int) uint64 {
+		if ptrSize == 4 {
+			return uint64(mem.order.Uint32(data[off:]))
+		}
+		return mem.order.Uint64(data[off:])
+	}
+
+	var refs []disasm.DataRef
+	// Pointers are aligned.
+	start := (ptrSize - int(addr%uint64(ptrSize))) % ptrSize
+	for off := start; off+ptrSize <= len(data); off += ptrSize {
+		ptr := word(off)
+		if ptr == 0 {
+			continue
+		}
+		target, ok := symbolAt(syms, ptr)
+		if !ok {
+			continue
+		}
+		ref := disasm.DataRef{
+			Offset: uint64(off),
+			Symbol: target.Name,
+			Addend: ptr - target.Addr,
+		}
+		if (target.Code == 'R' || target.Code == 'r') && off+2*ptrSize <= len(data) {
+			n := word(off + ptrSize)
+			if n > 0 && n <= maxString && ptr+n <= target.Addr+uint64(target.Size) {
+				if s, err := mem.read(ptr, n); err == nil && isText(s) {
+					ref.String, ref.Value = true, string(s)
+				}
+			}
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// isText reports whether s looks like a string literal.
+func isText(s []byte) bool {
+	if !utf8.Valid(s) {
+		return false
+	}
+	for _, r := range string(s) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+package goobj
+
+import (
+	"debug/gosym"
+	"sync"
+
+	"loov.dev/lensm/internal/dwarfline"
+	"loov.dev/lensm/internal/go/src/objfile"
+)
+
+// fallbackLiner resolves lines using the Go line table and falls back to
+// DWARF for addresses the pclntab doesn't cover, e.g. the C code in cgo
+// builds or binaries from other toolchains such as C or Rust.
+type fallbackLiner struct {
+	pcln  objfile.Liner
+	entry *objfile.Entry
+
+	// The DWARF line table is loaded on first use, because Go binaries
+	// rarely need it and it can be large.
+	once  sync.Once
+	lines *dwarfline.Table
+}
+
+func (liner *fallbackLiner) PCToLine(pc uint64) (string, int, *gosym.Func) {
+	if file, line, fn := liner.pcln.PCToLine(pc); file != "" {
+		return file, line, fn
+	}
+
+	liner.once.Do(func() {
+		data, err := liner.entry.DWARF()
+		if err != nil {
+			// Without debug information there are no lines to show.
+			return
+		}
+		liner.lines, _ = dwarfline.Load(data)
+	})
+
+	file, line, _ := liner.lines.Find(pc)
+	return file, line, nil
+}
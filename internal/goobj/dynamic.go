@@ -0,0 +1,219 @@
+package goobj
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"io"
+	"os"
+	"strings"
+
+	"loov.dev/lensm/internal/go/src/objfile"
+)
+
+// dynamicCode describes how a dynamically linked ELF binary is loaded.
+//
+// Calls to imported functions go through stubs in the PLT, which jump to
+// the address in the GOT that the dynamic linker fills in. The stubs have
+// no symbols, so they are named after the relocation of their GOT slot,
+// e.g. "puts@plt", and the slots themselves e.g. "puts@got".
+type dynamicCode struct {
+	// base is the load address of position independent binaries, the
+	// addresses are shown relative to it.
+	base uint64
+
+	// code contains the PLT sections starting at start.
+	start uint64
+	code  []byte
+	// syms are the stubs in code and the GOT slots.
+	syms []objfile.Sym
+}
+
+// pltSections contain the stubs, .plt.sec is used with CET and .plt.got
+// for functions that are also referenced by address.
+var pltSections = []string{".plt", ".plt.sec", ".plt.got"}
+
+// loadDynamic reads the stubs of the ELF binary at region, it returns nil
+// for other formats and statically linked executables.
+func loadDynamic(region fileRegion) (*dynamicCode, error) {
+	r, err := os.Open(region.path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var ra io.ReaderAt = r
+	if region.size > 0 {
+		ra = io.NewSectionReader(r, region.offset, region.size)
+	}
+	f, err := elf.NewFile(ra)
+	if err != nil {
+		return nil, nil
+	}
+
+	dyn := &dynamicCode{}
+	if f.Type == elf.ET_DYN {
+		dyn.base = ^uint64(0)
+		for _, prog := range f.Progs {
+			if prog.Type == elf.PT_LOAD {
+				dyn.base = min(dyn.base, prog.Vaddr)
+			}
+		}
+		if dyn.base == ^uint64(0) {
+			dyn.base = 0
+		}
+	}
+
+	slots := importSlots(f)
+	ptrSize := uint64(8)
+	if f.Class == elf.ELFCLASS32 {
+		ptrSize = 4
+	}
+	for addr, name := range slots {
+		dyn.syms = append(dyn.syms, objfile.Sym{Name: name + "@got", Addr: addr, Size: int64(ptrSize), Code: 'D'})
+	}
+
+	// i386 stubs are relative to the GOT, which is in ebx.
+	var got uint64
+	if section := f.Section(".got.plt"); section != nil {
+		got = section.Addr
+	}
+
+	end := uint64(0)
+	for _, name := range pltSections {
+		section := f.Section(name)
+		if section == nil || section.Type != elf.SHT_PROGBITS {
+			continue
+		}
+		data, err := section.Data()
+		if err != nil {
+			return nil, err
+		}
+
+		if dyn.code == nil {
+			dyn.start = section.Addr
+		}
+		if section.Addr < dyn.start {
+			dyn.code = append(make([]byte, dyn.start-section.Addr), dyn.code...)
+			dyn.start = section.Addr
+		}
+		end = max(end, dyn.start+uint64(len(dyn.code)), section.Addr+uint64(len(data)))
+		if grow := end - dyn.start - uint64(len(dyn.code)); grow > 0 {
+			dyn.code = append(dyn.code, make([]byte, grow)...)
+		}
+		copy(dyn.code[section.Addr-dyn.start:], data)
+
+		entsize := section.Entsize
+		if entsize == 0 {
+			entsize = 16
+		}
+		for off := uint64(0); off+entsize <= uint64(len(data)); off += entsize {
+			addr := section.Addr + off
+			slot, ok := stubSlot(f.Machine, f.ByteOrder, data[off:off+entsize], addr, got)
+			if !ok || slots[slot] == "" {
+				continue
+			}
+			dyn.syms = append(dyn.syms, objfile.Sym{
+				Name: slots[slot] + "@plt",
+				Addr: addr,
+				Size: int64(entsize),
+				Code: 'T',
+			})
+		}
+	}
+
+	if dyn.base == 0 && len(dyn.syms) == 0 {
+		return nil, nil
+	}
+	return dyn, nil
+}
+
+// importSlots returns the imported symbols by the address that the dynamic
+// linker writes them to.
+func importSlots(f *elf.File) map[uint64]string {
+	dynsyms, err := f.DynamicSymbols()
+	if err != nil {
+		return nil
+	}
+	slots := make(map[uint64]string)
+	for _, section := range f.Sections {
+		if section.Type != elf.SHT_RELA && section.Type != elf.SHT_REL {
+			continue
+		}
+		if int(section.Link) >= len(f.Sections) || f.Sections[section.Link].Type != elf.SHT_DYNSYM {
+			continue
+		}
+		data, err := section.Data()
+		if err != nil {
+			continue
+		}
+
+		// Rela entries have an addend after the offset and info.
+		var size int
+		switch {
+		case f.Class == elf.ELFCLASS64 && section.Type == elf.SHT_RELA:
+			size = 24
+		case f.Class == elf.ELFCLASS64:
+			size = 16
+		case section.Type == elf.SHT_RELA:
+			size = 12
+		default:
+			size = 8
+		}
+		for ; len(data) >= size; data = data[size:] {
+			var offset, sym uint64
+			if f.Class == elf.ELFCLASS64 {
+				offset = f.ByteOrder.Uint64(data)
+				sym = f.ByteOrder.Uint64(data[8:]) >> 32
+			} else {
+				offset = uint64(f.ByteOrder.Uint32(data))
+				sym = uint64(f.ByteOrder.Uint32(data[4:]) >> 8)
+			}
+			// The symbols returned by DynamicSymbols skip the null symbol.
+			if sym == 0 || sym > uint64(len(dynsyms)) {
+				continue
+			}
+			if name := dynsyms[sym-1].Name; name != "" {
+				slots[offset] = strings.TrimSuffix(name, "@")
+			}
+		}
+	}
+	return slots
+}
+
+// stubSlot returns the GOT slot that the stub at addr jumps through.
+func stubSlot(machine elf.Machine, order binary.ByteOrder, stub []byte, addr, got uint64) (uint64, bool) {
+	switch machine {
+	case elf.EM_X86_64:
+		// jmp *slot(%rip), possibly after endbr64 and bnd.
+		for i := 0; i+6 <= len(stub); i++ {
+			if stub[i] == 0xff && stub[i+1] == 0x25 {
+				disp := int32(order.Uint32(stub[i+2:]))
+				return addr + uint64(i+6) + uint64(int64(disp)), true
+			}
+		}
+	case elf.EM_386:
+		// jmp *slot, or jmp *off(%ebx) in position independent code.
+		for i := 0; i+6 <= len(stub); i++ {
+			if stub[i] == 0xff && stub[i+1] == 0x25 {
+				return uint64(order.Uint32(stub[i+2:])), true
+			}
+			if stub[i] == 0xff && stub[i+1] == 0xa3 {
+				return got + uint64(int64(int32(order.Uint32(stub[i+2:])))), true
+			}
+		}
+	case elf.EM_AARCH64:
+		// adrp x16, page; ldr x17, [x16, #off]
+		for i := 0; i+8 <= len(stub); i += 4 {
+			adrp := order.Uint32(stub[i:])
+			ldr := order.Uint32(stub[i+4:])
+			if adrp&0x9f00001f != 0x90000010 || ldr&0xffc003ff != 0xf9400211 {
+				continue
+			}
+			imm := int64(adrp>>29&3 | adrp>>5&0x7ffff<<2)
+			imm = imm << 43 >> 43 // sign extend 21 bits
+			page := (addr+uint64(i))&^0xfff + uint64(imm<<12)
+			return page + uint64(ldr>>10&0xfff)*8, true
+		}
+	}
+	return 0, false
+}
@@ -1,6 +1,8 @@
 package goobj
 
 import (
+	"errors"
+	"fmt"
 	"regexp"
 	"sort"
 	"strings"
@@ -13,26 +15,59 @@ import (
 
 var _ disasm.File = (*File)(nil)
 var _ disasm.Func = (*Func)(nil)
+var _ disasm.Sizer = (*Func)(nil)
+var _ disasm.Addresser = (*Func)(nil)
 
 // File contains information about the object file.
 type File struct {
 	objfile *objfile.File
-	disasm  *godisasm.Disasm
 	funcs   []disasm.Func
+	// stamp is used to detect stale sources.
+	stamp *disasm.BuildStamp
+
+	// region and first are used to load the inline table on first use,
+	// it's only available in executables.
+	region fileRegion
+	first  *godisasm.Disasm
+	// textStart is the start of the text before the stubs are added.
+	textStart  uint64
+	inlineOnce sync.Once
+	inlines    *inlineTable
+	// index is built on the first InlinedInto.
+	indexOnce sync.Once
+	index     inlineIndex
+	// calls is built in the background after IndexCalls, callsDone is
+	// closed when it's ready.
+	callsOnce sync.Once
+	callsDone chan struct{}
+	calls     *callIndex
+
+	// data are the data symbols of executables.
+	data []disasm.Func
+	// bySymbol are the functions of archives by their symbol name, the
+	// calls refer to the ones in the cgo entries without the prefix.
+	bySymbol map[string][]*Func
+
+	// base is subtracted from the addresses of position independent
+	// executables and shared libraries.
+	base uint64
 
 	// mu guards cache and serializes Disassemble calls: disassembly
 	// lazily populates line-table caches inside disasm, which is not
 	// safe for concurrent use.
 	mu    sync.Mutex
 	cache map[cacheKey]cacheEntry
+	// closed stops indexing the calls.
+	closed bool
 }
 
 // cacheKey includes the options: MCP callers choose the source context
 // per call, and a cache keyed by function alone would silently serve
 // whichever context happened to load first.
 type cacheKey struct {
-	fn      *Func
-	context int
+	fn        *Func
+	context   int
+	sourceMap *disasm.SourceMap
 }
 
 // cacheEntry also caches failures, so an erroring function isn't
@@ -46,16 +81,37 @@ func (file *File) Funcs() []disasm.Func { return file.funcs }
 
 // Function contains information about the executable.
 type Func struct {
-	obj *File
-	sym objfile.Sym
+	obj    *File
+	disasm *godisasm.Disasm
+	sym    objfile.Sym
+	// name includes the archive entry when there are several.
+	name string
 
 	sortName string
 }
 
-func (fn *Func) Name() string { return fn.sym.Name }
+func (fn *Func) Name() string { return fn.name }
+func (fn *Func) Size() uint64 { return uint64(fn.sym.Size) }
+func (fn *Func) Addr() uint64 { return fn.sym.Addr - fn.obj.base }
 
 func (file *File) Close() error {
-	return file.objfile.Close()
+	file.mu.Lock()
+	defer file.mu.Unlock()
+	file.closed = true
+	return errors.Join(file.inlines.Close(), file.objfile.Close())
+}
+
+// inlineTable returns the inline table, nil when it's not available.
+func (file *File) inlineTable() *inlineTable {
+	file.inlineOnce.Do(func() {
+		if file.first == nil {
+			return
+		}
+		// Without the inline trees the instructions are still shown,
+		// only attributed to the innermost function.
+		file.inlines, _ = loadInlineTable(file.region, file.first.Syms(), file.first.PCLN(), file.textStart)
+	})
+	return file.inlines
 }
 
 func Load(path string) (*File, error) {
@@ -63,38 +119,129 @@ func Load(path string) (*File, error) {
 	if err != nil {
 		return nil, err
 	}
+	return load(f, fileRegion{path: path})
+}
 
-	dis, err := godisasm.DisasmForFile(f)
-	if err != nil {
-		_ = f.Close()
-		return nil, err
-	}
-
+// load loads the functions from f, which is located at region.
+func load(f *objfile.File, region fileRegion) (*File, error) {
+	path := region.path
 	file := &File{
 		objfile: f,
-		disasm:  dis,
+		stamp:   disasm.ReadBuildStamp(path),
+		region:  region,
 		cache:   make(map[cacheKey]cacheEntry),
 	}
 
-	for _, sym := range dis.Syms() {
-		if sym.Code != 'T' && sym.Code != 't' || sym.Addr < dis.TextStart() {
+	// Archives from the build cache or `go tool compile` contain the Go
+	// object and native objects from cgo, so every entry is loaded. The
+	// first entry is the package itself, the names in the others are
+	// prefixed with the entry to group them together in the list.
+	entries := f.Entries()
+	loaded := 0
+	for i, entry := range entries {
+		dis, err := godisasm.DisasmForEntry(entry)
+		if err != nil {
+			if len(entries) == 1 {
+				_ = f.Close()
+				return nil, err
+			}
+			// Skip entries that can't be disassembled, e.g. without text.
 			continue
 		}
-		sym := &Func{
-			obj:      file,
-			sym:      sym,
-			sortName: sortingName(sym.Name),
+		loaded++
+		if len(entries) == 1 {
+			file.first = dis
+			file.textStart = dis.TextStart()
+			if err := file.addDynamic(dis); err != nil {
+				_ = f.Close()
+				return nil, err
+			}
+			dis.SetSyms(sizeGroups(dis.Syms()))
+			for _, sym := range dis.Syms() {
+				if isData(sym.Code) && sym.Size > 0 && sym.Name != "" {
+					file.data = append(file.data, &Data{obj: file, sym: sym})
+				}
+			}
 		}
-		file.funcs = append(file.funcs, sym)
+
+		dis.SetPCLN(&fallbackLiner{pcln: dis.PCLN(), entry: entry})
+
+		prefix := ""
+		if i > 0 {
+			prefix = entry.Name() + ": "
+		}
+
+		for _, sym := range dis.Syms() {
+			if sym.Code != 'T' && sym.Code != 't' || sym.Addr < dis.TextStart() || sym.Name == "" {
+				continue
+			}
+			name := prefix + sym.Name
+			fn := &Func{
+				obj:      file,
+				disasm:   dis,
+				sym:      sym,
+				name:     name,
+				sortName: sortingName(name),
+			}
+			file.funcs = append(file.funcs, fn)
+			if len(entries) > 1 {
+				if file.bySymbol == nil {
+					file.bySymbol = make(map[string][]*Func)
+				}
+				file.bySymbol[sym.Name] = append(file.bySymbol[sym.Name], fn)
+			}
+		}
+	}
+	if loaded == 0 {
+		_ = f.Close()
+		return nil, fmt.Errorf("open %s: no entries with code", path)
 	}
 
 	sort.SliceStable(file.funcs, func(i, k int) bool {
 		return sortingName(file.funcs[i].Name()) < sortingName(file.funcs[k].Name())
 	})
+	sort.SliceStable(file.data, func(i, k int) bool {
+		return sortingName(file.data[i].Name()) < sortingName(file.data[k].Name())
+	})
 
 	return file, nil
 }
 
+// callName returns the listed name of the function that the code in dis
+// calls by its symbol name. Static functions may be defined in several
+// entries, so the one in the same entry is preferred, then the one in the
+// Go object.
+func (file *File) callName(dis *godisasm.Disasm, call string) string {
+	fns := file.bySymbol[call]
+	for _, fn := range fns {
+		if fn.disasm == dis {
+			return fn.name
+		}
+	}
+	for _, fn := range fns {
+		if fn.name == call {
+			return call
+		}
+	}
+	if len(fns) > 0 {
+		return fns[0].name
+	}
+	return call
+}
+
+// addDynamic adds the PLT stubs of dynamically linked executables and
+// shared libraries to dis, so that the calls to imported functions are
+// resolved.
+func (file *File) addDynamic(dis *godisasm.Disasm) error {
+	dyn, err := loadDynamic(file.region)
+	if err != nil || dyn == nil {
+		return err
+	}
+	file.base = dyn.base
+	dis.AddCode(dyn.start, dyn.code, dyn.syms)
+	return nil
+}
+
 func (fn *Func) Load(opts disasm.Options) (*disasm.Code, error) {
 	return fn.obj.LoadCode(fn, opts)
 }
@@ -102,10 +249,10 @@ func (fn *Func) Load(opts disasm.Options) (*disasm.Code, error) {
 func (file *File) LoadCode(fn *Func, opts disasm.Options) (*disasm.Code, error) {
 	file.mu.Lock()
 	defer file.mu.Unlock()
-	key := cacheKey{fn: fn, context: opts.Context}
+	key := cacheKey{fn: fn, context: opts.Context, sourceMap: opts.SourceMap}
 	entry, ok := file.cache[key]
 	if !ok {
-		entry.code, entry.err = Disassemble(fn.obj.disasm, fn, opts)
+		entry.code, entry.err = Disassemble(fn.disasm, fn, opts)
 		file.cache[key] = entry
 	}
 	return entry.code, entry.err
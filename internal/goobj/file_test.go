@@ -4,10 +4,15 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
 	"sync"
 	"testing"
 
 	"loov.dev/lensm/internal/disasm"
+	godisasm "loov.dev/lensm/internal/go/src/disasm"
+	"loov.dev/lensm/internal/go/src/objfile"
 )
 
 func TestLoadCode_ConcurrentCallsShareCache(t *testing.T) {
@@ -53,3 +58,527 @@ func add(a, b int) int { return a + b }
 	}
 	wg.Wait()
 }
+
+func TestLoad_NativeBinaryUsesDWARF(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a test binary")
+	}
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("no C compiler")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "add.c")
+	err = os.WriteFile(src, []byte(`int add(int a, int b) {
+	if (a > b) return a - b;
+	return a + b;
+}
+
+int main(void) { return add(1, 2); }
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin := filepath.Join(dir, "add")
+	if out, err := exec.Command(cc, "-g", "-O1", "-o", bin, src).CombinedOutput(); err != nil {
+		t.Skipf("cc: %v\n%s", err, out)
+	}
+
+	file, err := Load(bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = file.Close() })
+
+	var add disasm.Func
+	for _, fn := range file.Funcs() {
+		if fn.Name() == "add" {
+			add = fn
+		}
+	}
+	if add == nil {
+		t.Fatal("add not found")
+	}
+
+	code, err := add.Load(disasm.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code.File != src {
+		t.Errorf("code.File = %q, want %q", code.File, src)
+	}
+	if len(code.Source) != 1 || len(code.Source[0].Blocks) == 0 {
+		t.Fatalf("sources not loaded: %+v", code.Source)
+	}
+	related := false
+	for _, block := range code.Source[0].Blocks {
+		for _, ranges := range block.Related {
+			related = related || len(ranges) > 0
+		}
+	}
+	if !related {
+		t.Error("no source lines related to instructions")
+	}
+}
+
+func TestLoad_ArchiveEntries(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a test archive")
+	}
+	if _, err := exec.LookPath("cc"); err != nil {
+		t.Skip("no C compiler for cgo")
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/archive\n\ngo 1.22\n",
+		"add.go": `package archive
+
+//go:noinline
+func Add(a, b int) int { return a + b }
+
+func Sum(xs []int) (t int) {
+	for _, x := range xs {
+		t = Add(t, x)
+	}
+	return t
+}
+`,
+		"twice.go": `package archive
+
+// int twice(int x) { return x * 2; }
+import "C"
+
+func Twice(x int) int { return int(C.twice(C.int(x))) }
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archive := filepath.Join(dir, "archive.a")
+	cmd := exec.Command("go", "build", "-o", archive, ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "CGO_ENABLED=1")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+
+	file, err := Load(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = file.Close() })
+
+	var sum disasm.Func
+	nativeTwice := false
+	for _, fn := range file.Funcs() {
+		switch {
+		case fn.Name() == "example.com/archive.Sum":
+			sum = fn
+		case strings.HasSuffix(fn.Name(), ".o: twice"):
+			nativeTwice = true
+		}
+	}
+	if sum == nil {
+		t.Fatal("Sum not found in the Go object")
+	}
+	if !nativeTwice {
+		t.Error("twice not found in the cgo objects")
+	}
+
+	code, err := sum.Load(disasm.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, inst := range code.Insts {
+		if inst.Call == "example.com/archive.Add" {
+			return
+		}
+	}
+	t.Error("call to Add not resolved from relocations")
+}
+
+func TestCallName_ArchiveEntries(t *testing.T) {
+	goobj, cgo, other := &godisasm.Disasm{}, &godisasm.Disasm{}, &godisasm.Disasm{}
+	file := &File{}
+	for _, fn := range []*Func{
+		{disasm: goobj, sym: objfile.Sym{Name: "pkg.Twice"}, name: "pkg.Twice"},
+		{disasm: cgo, sym: objfile.Sym{Name: "twice"}, name: "_x002.o: twice"},
+		{disasm: cgo, sym: objfile.Sym{Name: "helper"}, name: "_x002.o: helper"},
+		{disasm: other, sym: objfile.Sym{Name: "helper"}, name: "_x003.o: helper"},
+		{disasm: other, sym: objfile.Sym{Name: "pkg.Twice"}, name: "_x003.o: pkg.Twice"},
+	} {
+		if file.bySymbol == nil {
+			file.bySymbol = make(map[string][]*Func)
+		}
+		file.bySymbol[fn.sym.Name] = append(file.bySymbol[fn.sym.Name], fn)
+	}
+
+	for _, test := range []struct {
+		dis  *godisasm.Disasm
+		call string
+		want string
+	}{
+		{cgo, "twice", "_x002.o: twice"},
+		{goobj, "twice", "_x002.o: twice"},
+		{cgo, "helper", "_x002.o: helper"},
+		{other, "helper", "_x003.o: helper"},
+		{cgo, "pkg.Twice", "pkg.Twice"},
+		{cgo, "memcpy", "memcpy"},
+		{cgo, "", ""},
+	} {
+		if got := file.callName(test.dis, test.call); got != test.want {
+			t.Errorf("callName(%q) = %q, want %q", test.call, got, test.want)
+		}
+	}
+}
+
+func TestLoad_StrippedBinaryUsesPclntab(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a test binary")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "main.go")
+	err := os.WriteFile(src, []byte(`package main
+
+func main() { println(add(1, 2)) }
+
+//go:noinline
+func add(a, b int) int { return a + b }
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin := filepath.Join(dir, "stripped.exe")
+	if out, err := exec.Command("go", "build", "-ldflags=-s -w", "-o", bin, src).CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+
+	file, err := Load(bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = file.Close() })
+
+	var main disasm.Func
+	for _, fn := range file.Funcs() {
+		if fn.Name() == "main.main" {
+			main = fn
+		}
+	}
+	if main == nil {
+		t.Fatalf("main.main not found in %d funcs", len(file.Funcs()))
+	}
+
+	code, err := main.Load(disasm.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code.File != src {
+		t.Errorf("code.File = %q, want %q", code.File, src)
+	}
+	for _, inst := range code.Insts {
+		if inst.Call == "main.add" {
+			return
+		}
+	}
+	t.Error("call to main.add not resolved")
+}
+
+func TestLoad_InlineTree(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a test binary")
+	}
+
+	file, codes := buildFuncs(t, runtime.GOARCH, `package main
+
+func main() { println(outer(3)) }
+
+//go:noinline
+func outer(x int) int { return middle(x) + 1 }
+
+func middle(x int) int { return inner(x) * 2 }
+
+func inner(x int) int { return x*x + 7 }
+`, "main.outer")
+	code := codes[0]
+	src := code.File
+
+	want := []disasm.InlineFrame{
+		{Func: "main.inner", File: src, Line: 8},
+		{Func: "main.middle", File: src, Line: 6},
+	}
+	found := false
+	for _, inst := range code.Insts {
+		if len(inst.Inlined) == len(want) && inst.Inlined[0] == want[0] && inst.Inlined[1] == want[1] {
+			if inst.Line != 10 {
+				t.Errorf("inlined instruction at line %d, want 10", inst.Line)
+			}
+			found = true
+			break
+		}
+	}
+	if !found {
+		for _, inst := range code.Insts {
+			t.Logf("%s:%d %v", inst.File, inst.Line, inst.Inlined)
+		}
+		t.Error("no instruction with the inline chain inner ← middle")
+	}
+
+	// Code inlined through middle also counts for inner.
+	for _, frame := range want {
+		sites, err := file.InlinedInto(frame.Func)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, site := range sites {
+			if site.Caller == "main.outer" && site.File == frame.File && site.Line == frame.Line {
+				found = site.Size > 0 && len(site.Ranges) > 0
+			}
+		}
+		if !found {
+			t.Errorf("%s: inlined into main.outer at line %d not found in %+v", frame.Func, frame.Line, sites)
+		}
+	}
+}
+
+func TestLoad_SharedLibraryResolvesPLT(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a test library")
+	}
+	if _, err := exec.LookPath("cc"); err != nil {
+		t.Skip("no C compiler for cgo")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "lib.go")
+	err := os.WriteFile(src, []byte(`package main
+
+// #include <stdio.h>
+// static void hello(void) { puts("hello"); }
+import "C"
+
+//export Hello
+func Hello() { C.hello() }
+
+func main() {}
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lib := filepath.Join(dir, "lib.so")
+	cmd := exec.Command("go", "build", "-buildmode=c-shared", "-o", lib, src)
+	cmd.Env = append(os.Environ(), "CGO_ENABLED=1")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("go build: %v\n%s", err, out)
+	}
+
+	file, err := Load(lib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = file.Close() })
+
+	var hello, stub disasm.Func
+	for _, fn := range file.Funcs() {
+		switch {
+		case strings.HasSuffix(fn.Name(), "_Cfunc_hello"):
+			hello = fn
+		case fn.Name() == "puts@plt":
+			stub = fn
+		}
+	}
+	if hello == nil || stub == nil {
+		t.Fatal("_Cfunc_hello or puts@plt not found")
+	}
+
+	code, err := stub.Load(disasm.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code.Insts) == 0 || code.Insts[0].Call != "puts@got" {
+		t.Errorf("puts@plt doesn't jump through puts@got: %+v", code.Insts)
+	}
+
+	// The static C function is inlined into the cgo wrapper.
+	code, err = hello.Load(disasm.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := []string{}
+	for _, inst := range code.Insts {
+		if inst.Call != "" {
+			calls = append(calls, inst.Call)
+		}
+	}
+	if !slices.Contains(calls, "puts@plt") {
+		t.Errorf("calls = %v, want puts@plt", calls)
+	}
+}
+
+func TestLoad_PIERelativeToBase(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a test binary")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "main.go")
+	err := os.WriteFile(src, []byte(`package main
+
+func main() { println(add(1, 2)) }
+
+//go:noinline
+func add(a, b int) int { return a + b }
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin := filepath.Join(dir, "pie.exe")
+	cmd := exec.Command("go", "build", "-buildmode=pie", "-o", bin, src)
+	// The base is only read from ELF.
+	cmd.Env = append(os.Environ(), "GOOS=linux")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("go build: %v\n%s", err, out)
+	}
+
+	file, err := Load(bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = file.Close() })
+	if file.base == 0 {
+		t.Fatal("base of the PIE not found")
+	}
+
+	var main, add disasm.Func
+	for _, fn := range file.Funcs() {
+		switch fn.Name() {
+		case "main.main":
+			main = fn
+		case "main.add":
+			add = fn
+		}
+	}
+	if main == nil || add == nil {
+		t.Fatal("main.main or main.add not found")
+	}
+	addCode, err := add.Load(disasm.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pc := addCode.Insts[0].PC; pc >= file.base {
+		t.Errorf("main.add at %#x, want relative to %#x", pc, file.base)
+	}
+
+	code, err := main.Load(disasm.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, inst := range code.Insts {
+		if inst.Call == "main.add" {
+			return
+		}
+	}
+	t.Error("call to main.add not resolved")
+}
+
+func TestLoad_DataSymbols(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a test binary")
+	}
+
+	file, codes := buildFuncs(t, runtime.GOARCH, `package main
+
+var greeting = "hello, data"
+var counter int
+var ptr = &counter
+
+//go:noinline
+func greet() string { return greeting }
+
+func main() { println(greet(), ptr) }
+`, "main.greet")
+
+	load := func(name string) *disasm.Code {
+		t.Helper()
+		for _, data := range file.Data() {
+			if data.Name() == name {
+				code, err := data.Load(disasm.Options{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return code
+			}
+		}
+		t.Fatalf("%s not found in the data symbols", name)
+		return nil
+	}
+
+	code := load("main.greeting")
+	if refs := code.Data.Refs; len(refs) != 1 || !refs[0].String || refs[0].Value != "hello, data" {
+		t.Errorf("main.greeting refs = %+v", refs)
+	}
+	code = load("main.ptr")
+	if refs := code.Data.Refs; len(refs) != 1 || refs[0].Symbol != "main.counter" {
+		t.Errorf("main.ptr refs = %+v", refs)
+	}
+	code = load("main.counter")
+	if code.Data.Bytes != nil && !slices.Equal(code.Data.Bytes, make([]byte, 8)) {
+		t.Errorf("main.counter = %x, want zeros", code.Data.Bytes)
+	}
+
+	for _, inst := range codes[0].Insts {
+		if inst.Symbol == "main.greeting" {
+			return
+		}
+	}
+	t.Error("reference to main.greeting not resolved")
+}
+
+func TestLoad_MemoryOperands(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds test binaries")
+	}
+
+	const src = `package main
+
+var table = []string{"alpha", "beta"}
+
+//go:noinline
+func greet(name string) string { return "hello, " + name + table[1] }
+
+//go:noinline
+func scale(x float64) float64 { return x * 1.5 }
+
+func main() { println(greet("world"), scale(2)) }
+`
+
+	for _, arch := range []string{"amd64", "arm64", "386", "arm"} {
+		t.Run(arch, func(t *testing.T) {
+			operands := map[string]string{}
+			_, codes := buildFuncs(t, arch, src, "main.greet", "main.main")
+			for _, code := range codes {
+				for _, inst := range code.Insts {
+					for _, op := range inst.Operands {
+						operands[op.Symbol] += op.Value
+					}
+				}
+			}
+			if _, ok := operands["main.table"]; !ok {
+				t.Errorf("main.table not referenced: %q", operands)
+			}
+			if got := operands["go:string.*"]; !strings.Contains(got, `"hello, "`) || !strings.Contains(got, `"world"`) {
+				t.Errorf("string literals = %s", got)
+			}
+			// arm encodes the float as an immediate.
+			if got := operands["$f64.4000000000000000"]; arch != "arm" && !strings.HasPrefix(got, "2") {
+				t.Errorf("float constant = %q", got)
+			}
+		})
+	}
+}
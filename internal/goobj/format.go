@@ -0,0 +1,76 @@
+package goobj
+
+import (
+	"bytes"
+	"debug/macho"
+	"encoding/binary"
+
+	"loov.dev/lensm/internal/disasm"
+)
+
+func init() {
+	open := func(path string) (disasm.File, error) { return Load(path) }
+
+	disasm.RegisterFormat(disasm.Format{Name: "ELF", Probe: hasPrefix("\x7fELF"), Open: open})
+	thin := hasPrefix(
+		"\xfe\xed\xfa\xce", "\xce\xfa\xed\xfe", // 32-bit
+		"\xfe\xed\xfa\xcf", "\xcf\xfa\xed\xfe", // 64-bit
+	)
+	disasm.RegisterFormat(disasm.Format{
+		Name: "Mach-O",
+		Probe: func(header []byte) bool {
+			return thin(header) || isUniversalHeader(header)
+		},
+		Open: func(path string) (disasm.File, error) {
+			if isUniversal(path) {
+				return LoadUniversal(path)
+			}
+			return Load(path)
+		},
+	})
+	disasm.RegisterFormat(disasm.Format{Name: "PE", Probe: hasPrefix("MZ"), Open: open})
+	disasm.RegisterFormat(disasm.Format{Name: "XCOFF", Probe: hasPrefix("\x01\xdf", "\x01\xf7"), Open: open})
+	disasm.RegisterFormat(disasm.Format{
+		Name: "Go object",
+		// The compiler writes archives, older toolchains plain object files.
+		Probe: hasPrefix("!<arch>\n", "go object "),
+		Open:  open,
+	})
+}
+
+// maxUniversalArchs is the most architectures expected in an universal
+// binary.
+const maxUniversalArchs = 20
+
+// isUniversalHeader reports whether header starts an universal Mach-O
+// binary. Java class files share the magic, they are told apart like
+// file(1) does by the architecture count, where a class file has its
+// version, which is 45 or more.
+func isUniversalHeader(header []byte) bool {
+	if len(header) < 8 || !bytes.HasPrefix(header, []byte("\xca\xfe\xba\xbe")) {
+		return false
+	}
+	return binary.BigEndian.Uint32(header[4:]) < maxUniversalArchs
+}
+
+// isUniversal reports whether path is an universal Mach-O binary.
+func isUniversal(path string) bool {
+	fat, err := macho.OpenFat(path)
+	if err != nil {
+		return false
+	}
+	_ = fat.Close()
+	return true
+}
+
+// hasPrefix returns a probe that matches any of the magic prefixes.
+func hasPrefix(magics ...string) func(header []byte) bool {
+	return func(header []byte) bool {
+		for _, magic := range magics {
+			if bytes.HasPrefix(header, []byte(magic)) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,36 @@
+package goobj
+
+import (
+	"testing"
+
+	"loov.dev/lensm/internal/disasm"
+)
+
+func TestFormatProbes(t *testing.T) {
+	cases := []struct {
+		header string
+		format string
+	}{
+		{"\x7fELF\x02\x01\x01", "ELF"},
+		{"\xcf\xfa\xed\xfe\x07\x00\x00\x01", "Mach-O"},
+		{"\xca\xfe\xba\xbe\x00\x00\x00\x02", "Mach-O"},
+		{"\xca\xfe\xba\xbe\x00\x00\x00\x41", ""}, // Java class file
+		{"\xca\xfe\xba\xbe", ""},
+		{"MZ\x90\x00", "PE"},
+		{"\x01\xf7\x00\x04", "XCOFF"},
+		{"!<arch>\n__.PKGDEF", "Go object"},
+		{"\x00asm\x01\x00\x00\x00", ""},
+	}
+	for _, tc := range cases {
+		got := ""
+		for _, format := range disasm.Formats() {
+			if format.Probe([]byte(tc.header)) {
+				got = format.Name
+				break
+			}
+		}
+		if got != tc.format {
+			t.Errorf("probe(%q) = %q, want %q", tc.header, got, tc.format)
+		}
+	}
+}
@@ -0,0 +1,499 @@
+package goobj
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"sort"
+
+	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/go/src/objfile"
+)
+
+// inlineTable decodes the inline trees in the pclntab of Go 1.18 and newer
+// executables, which record for each instruction the chain of calls that
+// were inlined into the function.
+//
+// The trees are stored as funcdata, which lives outside of the pclntab, so
+// the executable is read by address through memory.
+type inlineTable struct {
+	mem   *memory
+	order binary.ByteOrder
+	// go118 is set for the Go 1.18 and 1.19 layouts.
+	go118 bool
+	minLC uint64
+	pcln  objfile.Liner
+
+	textStart   uint64
+	functab     []byte
+	nfunc       int
+	funcnametab []byte
+	pctab       []byte
+	// gofunc is the address that funcdata offsets are relative to.
+	gofunc uint64
+	// addr and size are the location of the whole pclntab.
+	addr, size uint64
+}
+
+const (
+	pcdataInlTreeIndex = 2
+	funcdataInlTree    = 3
+
+	go118magic = 0xfffffff0
+	go120magic = 0xfffffff1
+)
+
+// loadInlineTable loads the inline table for the executable at region. It
+// needs the symbol table to find the funcdata, so it returns an error for
+// stripped binaries. textStart is used when the header doesn't contain
+// it, e.g. in position independent executables.
+func loadInlineTable(region fileRegion, syms []objfile.Sym, pcln objfile.Liner, textStart uint64) (*inlineTable, error) {
+	var pclntab, epclntab, gofunc uint64
+	for _, sym := range syms {
+		switch sym.Name {
+		case "runtime.pclntab":
+			pclntab = sym.Addr
+		case "runtime.epclntab":
+			epclntab = sym.Addr
+		case "go:func.*", "go.func.*":
+			gofunc = sym.Addr
+		}
+	}
+	if pclntab == 0 || epclntab <= pclntab || gofunc == 0 {
+		return nil, errors.New("no pclntab symbols")
+	}
+
+	mem, err := openMemory(region)
+	if err != nil {
+		return nil, err
+	}
+	data, err := mem.read(pclntab, epclntab-pclntab)
+	if err != nil {
+		_ = mem.Close()
+		return nil, err
+	}
+
+	table, err := parseInlineTable(data, pcln)
+	if err != nil {
+		_ = mem.Close()
+		return nil, err
+	}
+	table.mem = mem
+	table.gofunc = gofunc
+	table.addr = pclntab
+	if table.textStart == 0 {
+		table.textStart = textStart
+	}
+	return table, nil
+}
+
+// parseInlineTable parses the pclntab header.
+func parseInlineTable(data []byte, pcln objfile.Liner) (*inlineTable, error) {
+	if len(data) < 8 {
+		return nil, errors.New("pclntab too short")
+	}
+
+	table := &inlineTable{pcln: pcln, size: uint64(len(data))}
+	var magic uint32
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		magic = order.Uint32(data)
+		if magic == go118magic || magic == go120magic {
+			table.order = order
+			break
+		}
+	}
+	if table.order == nil {
+		return nil, errors.New("unsupported pclntab version")
+	}
+	table.go118 = magic == go118magic
+	table.minLC = uint64(data[6])
+	ptrSize := int(data[7])
+	if ptrSize != 4 && ptrSize != 8 {
+		return nil, errors.New("invalid pclntab pointer size")
+	}
+
+	word := func(i int) uint64 {
+		off := 8 + i*ptrSize
+		if off+ptrSize > len(data) {
+			return 0
+		}
+		if ptrSize == 4 {
+			return uint64(table.order.Uint32(data[off:]))
+		}
+		return table.order.Uint64(data[off:])
+	}
+	slice := func(from uint64) []byte {
+		if from > uint64(len(data)) {
+			return nil
+		}
+		return data[from:]
+	}
+
+	table.nfunc = int(word(0))
+	table.textStart = word(2)
+	table.funcnametab = slice(word(3))
+	table.pctab = slice(word(6))
+	table.functab = slice(word(7))
+	if len(table.functab) < (table.nfunc+1)*8 {
+		return nil, errors.New("pclntab functab truncated")
+	}
+	return table, nil
+}
+
+func (table *inlineTable) Close() error {
+	if table == nil {
+		return nil
+	}
+	return table.mem.Close()
+}
+
+// inlineFunc is the inline tree of a single function.
+type inlineFunc struct {
+	table *inlineTable
+	entry uint64
+	// index is the offset of the inline tree index table in pctab.
+	index uint32
+	// tree is the address of the inline tree.
+	tree uint64
+}
+
+// lookup finds the inline tree of the function starting at entry, it
+// returns nil when nothing was inlined into it.
+func (table *inlineTable) lookup(entry uint64) *inlineFunc {
+	if table == nil || entry < table.textStart {
+		return nil
+	}
+	entryOff := entry - table.textStart
+	i := sort.Search(table.nfunc, func(i int) bool {
+		return uint64(table.order.Uint32(table.functab[i*8:])) >= entryOff
+	})
+	if i >= table.nfunc || uint64(table.order.Uint32(table.functab[i*8:])) != entryOff {
+		return nil
+	}
+	_, fn := table.funcAt(i)
+	return fn
+}
+
+// funcAt returns the name and the inline tree of the i-th function in
+// the pclntab, the tree is nil when nothing was inlined into it.
+func (table *inlineTable) funcAt(i int) (string, *inlineFunc) {
+	entry := table.textStart + uint64(table.order.Uint32(table.functab[i*8:]))
+	funcOff := table.order.Uint32(table.functab[i*8+4:])
+	if uint64(funcOff) >= uint64(len(table.functab)) {
+		return "", nil
+	}
+	fn := table.functab[funcOff:]
+
+	// The _func header is followed by npcdata pcdata offsets and
+	// nfuncdata funcdata offsets.
+	header := table.funcHeaderSize()
+	if len(fn) < header {
+		return "", nil
+	}
+	name := table.funcName(table.order.Uint32(fn[4:]))
+	npcdata := table.order.Uint32(fn[28:])
+	nfuncdata := uint32(fn[header-1])
+	if npcdata <= pcdataInlTreeIndex || nfuncdata <= funcdataInlTree {
+		return name, nil
+	}
+	pcdata := fn[header:]
+	if uint64(len(pcdata)) < uint64(npcdata+nfuncdata)*4 {
+		return name, nil
+	}
+	funcdata := pcdata[npcdata*4:]
+
+	index := table.order.Uint32(pcdata[pcdataInlTreeIndex*4:])
+	tree := table.order.Uint32(funcdata[funcdataInlTree*4:])
+	if index == 0 || tree == ^uint32(0) {
+		return name, nil
+	}
+	return name, &inlineFunc{
+		table: table,
+		entry: entry,
+		index: index,
+		tree:  table.gofunc + uint64(tree),
+	}
+}
+
+// funcHeaderSize returns the size of _func without the offsets that
+// follow it.
+func (table *inlineTable) funcHeaderSize() int {
+	if table.go118 {
+		return 40
+	}
+	return 44
+}
+
+// Stack returns the inlined calls that pc belongs to, innermost first.
+func (fn *inlineFunc) Stack(pc uint64) []disasm.InlineFrame {
+	if fn == nil {
+		return nil
+	}
+	var frames []disasm.InlineFrame
+	ix := fn.treeIndex(pc)
+	// The depth is bounded, in case the data is corrupt.
+	for ix >= 0 && len(frames) < 100 {
+		nameOff, parentPC, ok := fn.call(ix)
+		if !ok {
+			break
+		}
+		callPC := fn.entry + parentPC
+		file, line, _ := fn.table.pcln.PCToLine(callPC)
+		frames = append(frames, disasm.InlineFrame{
+			Func: fn.table.funcName(nameOff),
+			File: file,
+			Line: line,
+		})
+		ix = fn.treeIndex(callPC)
+	}
+	return frames
+}
+
+// call reads the inlinedCall at index ix of the tree.
+func (fn *inlineFunc) call(ix int32) (nameOff uint32, parentPC uint64, ok bool) {
+	calls, ok := fn.calls(ix, 1)
+	if !ok {
+		return 0, 0, false
+	}
+	return calls[0].nameOff, calls[0].parentPC, true
+}
+
+// inlinedCall is an entry of the inline tree.
+type inlinedCall struct {
+	nameOff  uint32
+	parentPC uint64
+}
+
+// calls reads n entries of the tree starting at index ix.
+func (fn *inlineFunc) calls(ix int32, n int) ([]inlinedCall, bool) {
+	// Go 1.20 and newer:
+	//   funcID uint8; _ [3]byte; nameOff int32; parentPc int32; startLine int32
+	// Go 1.18 and 1.19:
+	//   parent int16; funcID uint8; _ byte; file int32; line int32; func_ int32; parentPc int32
+	size, nameAt, parentAt := uint64(16), 4, 8
+	if fn.table.go118 {
+		size, nameAt, parentAt = 20, 12, 16
+	}
+	data, err := fn.table.mem.read(fn.tree+uint64(ix)*size, uint64(n)*size)
+	if err != nil {
+		return nil, false
+	}
+	calls := make([]inlinedCall, n)
+	for i := range calls {
+		entry := data[uint64(i)*size:]
+		calls[i] = inlinedCall{
+			nameOff:  fn.table.order.Uint32(entry[nameAt:]),
+			parentPC: uint64(fn.table.order.Uint32(entry[parentAt:])),
+		}
+	}
+	return calls, true
+}
+
+// treeIndex evaluates the inline tree index table at pc, -1 means that pc
+// is not in inlined code.
+func (fn *inlineFunc) treeIndex(pc uint64) int32 {
+	value := int32(-1)
+	fn.runs(func(start, end uint64, ix int32) bool {
+		if pc < start {
+			return false
+		}
+		if pc < end {
+			value = ix
+			return false
+		}
+		return true
+	})
+	return value
+}
+
+// runs calls f for each range [start, end) of the inline tree index
+// table, until f returns false.
+func (fn *inlineFunc) runs(f func(start, end uint64, ix int32) bool) {
+	table := fn.table
+	if uint64(fn.index) >= uint64(len(table.pctab)) {
+		return
+	}
+	p := table.pctab[fn.index:]
+	value, at := int32(-1), fn.entry
+	first := true
+	for {
+		uvdelta, n := binary.Uvarint(p)
+		if n <= 0 || (uvdelta == 0 && !first) {
+			return
+		}
+		p = p[n:]
+		first = false
+
+		if uvdelta&1 != 0 {
+			uvdelta = ^(uvdelta >> 1)
+		} else {
+			uvdelta >>= 1
+		}
+		value += int32(uvdelta)
+
+		pcdelta, n := binary.Uvarint(p)
+		if n <= 0 {
+			return
+		}
+		p = p[n:]
+		start := at
+		at += pcdelta * table.minLC
+		if !f(start, at, value) {
+			return
+		}
+	}
+}
+
+// funcName reads a name from the function name table.
+func (table *inlineTable) funcName(off uint32) string {
+	if uint64(off) >= uint64(len(table.funcnametab)) {
+		return "?"
+	}
+	name := table.funcnametab[off:]
+	if end := bytes.IndexByte(name, 0); end >= 0 {
+		name = name[:end]
+	}
+	return string(name)
+}
+
+// memory reads an executable by virtual address.
+type memory struct {
+	file     io.Closer
+	sections []memorySection
+	order    binary.ByteOrder
+	ptrSize  int
+}
+
+type memorySection struct {
+	name       string
+	addr, size uint64
+	// data is nil for zero initialized sections.
+	data io.ReaderAt
+}
+
+// fileRegion is the part of a file that contains the executable, a
+// universal binary contains one for each architecture.
+type fileRegion struct {
+	path string
+	// offset and size are zero when the executable is the whole file.
+	offset, size int64
+}
+
+// openMemory opens the sections of an ELF, Mach-O or PE executable.
+func openMemory(region fileRegion) (*memory, error) {
+	f, err := os.Open(region.path)
+	if err != nil {
+		return nil, err
+	}
+	var r io.ReaderAt = f
+	if region.size > 0 {
+		r = io.NewSectionReader(f, region.offset, region.size)
+	}
+
+	mem := &memory{file: f, ptrSize: 8}
+	if ef, err := elf.NewFile(r); err == nil {
+		mem.order = ef.ByteOrder
+		if ef.Class == elf.ELFCLASS32 {
+			mem.ptrSize = 4
+		}
+		for _, section := range ef.Sections {
+			if section.Flags&elf.SHF_ALLOC == 0 {
+				continue
+			}
+			var data io.ReaderAt = section
+			if section.Type == elf.SHT_NOBITS {
+				data = nil
+			}
+			mem.sections = append(mem.sections, memorySection{section.Name, section.Addr, section.Size, data})
+		}
+		return mem, nil
+	}
+	if mf, err := macho.NewFile(r); err == nil {
+		mem.order = mf.ByteOrder
+		if mf.Magic == macho.Magic32 {
+			mem.ptrSize = 4
+		}
+		for _, section := range mf.Sections {
+			var data io.ReaderAt = section
+			// S_ZEROFILL, S_GB_ZEROFILL and S_THREAD_LOCAL_ZEROFILL
+			switch section.Flags & 0xff {
+			case 0x1, 0xc, 0x12:
+				data = nil
+			}
+			mem.sections = append(mem.sections, memorySection{section.Name, section.Addr, section.Size, data})
+		}
+		return mem, nil
+	}
+	if pf, err := pe.NewFile(r); err == nil {
+		mem.order = binary.LittleEndian
+		var imageBase uint64
+		switch header := pf.OptionalHeader.(type) {
+		case *pe.OptionalHeader32:
+			imageBase = uint64(header.ImageBase)
+			mem.ptrSize = 4
+		case *pe.OptionalHeader64:
+			imageBase = header.ImageBase
+		}
+		for _, section := range pf.Sections {
+			if section.Size == 0 {
+				// .bss has no contents in the file.
+				mem.sections = append(mem.sections, memorySection{section.Name, imageBase + uint64(section.VirtualAddress), uint64(section.VirtualSize), nil})
+				continue
+			}
+			mem.sections = append(mem.sections, memorySection{section.Name, imageBase + uint64(section.VirtualAddress), uint64(section.Size), section})
+		}
+		return mem, nil
+	}
+	_ = f.Close()
+	return nil, errors.New("unsupported executable format")
+}
+
+// read reads size bytes at addr.
+func (mem *memory) read(addr, size uint64) ([]byte, error) {
+	for _, section := range mem.sections {
+		if section.data != nil && section.addr <= addr && addr+size <= section.addr+section.size {
+			data := make([]byte, size)
+			if _, err := section.data.ReadAt(data, int64(addr-section.addr)); err != nil {
+				return nil, err
+			}
+			return data, nil
+		}
+	}
+	return nil, errors.New("address not mapped")
+}
+
+// readPointer reads the pointer at addr.
+func (mem *memory) readPointer(addr uint64) (uint64, error) {
+	word, err := mem.read(addr, uint64(mem.ptrSize))
+	if err != nil {
+		return 0, err
+	}
+	if mem.ptrSize == 8 {
+		return mem.order.Uint64(word), nil
+	}
+	return uint64(mem.order.Uint32(word)), nil
+}
+
+// sectionAt returns the section that contains addr, nil when it's not
+// mapped.
+func (mem *memory) sectionAt(addr uint64) *memorySection {
+	for i := range mem.sections {
+		section := &mem.sections[i]
+		if section.addr <= addr && addr < section.addr+section.size {
+			return section
+		}
+	}
+	return nil
+}
+
+func (mem *memory) Close() error {
+	if mem == nil {
+		return nil
+	}
+	return mem.file.Close()
+}
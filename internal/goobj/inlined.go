@@ -0,0 +1,133 @@
+package goobj
+
+import (
+	"errors"
+	"sort"
+
+	"loov.dev/lensm/internal/disasm"
+)
+
+var _ disasm.InlineIndex = (*File)(nil)
+
+// errNoInlineInfo is returned for files without inline trees, e.g. object
+// files and stripped executables.
+var errNoInlineInfo = errors.New("no inlining information")
+
+// inlineSite is a call that was inlined, the line is resolved on lookup.
+type inlineSite struct {
+	caller   string
+	parentPC uint64
+	ranges   []disasm.PCRange
+	size     uint64
+}
+
+func (site *inlineSite) add(start, end uint64) {
+	site.size += end - start
+	if n := len(site.ranges); n > 0 && site.ranges[n-1].End == start {
+		site.ranges[n-1].End = end
+		return
+	}
+	site.ranges = append(site.ranges, disasm.PCRange{Start: start, End: end})
+}
+
+// inlineIndex maps the inlined functions to the calls.
+type inlineIndex map[string][]*inlineSite
+
+// index walks the inline trees of all functions. Code inlined through
+// several calls counts for each function in the chain.
+func (table *inlineTable) index() inlineIndex {
+	type run struct {
+		start, end uint64
+		ix         int32
+	}
+	type siteKey struct {
+		callee   string
+		parentPC uint64
+	}
+
+	index := inlineIndex{}
+	for i := range table.nfunc {
+		caller, fn := table.funcAt(i)
+		if fn == nil {
+			continue
+		}
+
+		var runs []run
+		maxIndex := int32(-1)
+		fn.runs(func(start, end uint64, ix int32) bool {
+			runs = append(runs, run{start: start, end: end, ix: ix})
+			maxIndex = max(maxIndex, ix)
+			return true
+		})
+		if maxIndex < 0 {
+			continue
+		}
+		calls, ok := fn.calls(0, int(maxIndex)+1)
+		if !ok {
+			continue
+		}
+		indexAt := func(pc uint64) int32 {
+			k := sort.Search(len(runs), func(k int) bool { return pc < runs[k].end })
+			if k < len(runs) && runs[k].start <= pc {
+				return runs[k].ix
+			}
+			return -1
+		}
+
+		sites := map[siteKey]*inlineSite{}
+		for _, r := range runs {
+			// The depth is bounded, in case the data is corrupt.
+			for ix, depth := r.ix, 0; ix >= 0 && int(ix) < len(calls) && depth < 100; depth++ {
+				call := calls[ix]
+				key := siteKey{callee: table.funcName(call.nameOff), parentPC: fn.entry + call.parentPC}
+				site, ok := sites[key]
+				if !ok {
+					site = &inlineSite{caller: caller, parentPC: key.parentPC}
+					sites[key] = site
+					index[key.callee] = append(index[key.callee], site)
+				}
+				site.add(r.start, r.end)
+				ix = indexAt(key.parentPC)
+			}
+		}
+	}
+	return index
+}
+
+// InlinedInto returns the calls where the named function was inlined,
+// the largest first. The first call indexes the whole binary.
+func (file *File) InlinedInto(name string) ([]disasm.InlineSite, error) {
+	table := file.inlineTable()
+	if table == nil {
+		return nil, errNoInlineInfo
+	}
+	file.indexOnce.Do(func() { file.index = table.index() })
+
+	// The line table is shared with disassembly.
+	file.mu.Lock()
+	defer file.mu.Unlock()
+
+	var sites []disasm.InlineSite
+	for _, site := range file.index[name] {
+		srcFile, line, _ := table.pcln.PCToLine(site.parentPC)
+		// The ranges are relative to the base, as the instructions.
+		ranges := make([]disasm.PCRange, len(site.ranges))
+		for i, r := range site.ranges {
+			ranges[i] = disasm.PCRange{Start: r.Start - file.base, End: r.End - file.base}
+		}
+		sites = append(sites, disasm.InlineSite{
+			Caller: site.caller,
+			File:   srcFile,
+			Line:   line,
+			Ranges: ranges,
+			Size:   site.size,
+		})
+	}
+	sort.SliceStable(sites, func(i, k int) bool {
+		if sites[i].Size != sites[k].Size {
+			return sites[i].Size > sites[k].Size
+		}
+		return sites[i].Caller < sites[k].Caller
+	})
+	return sites, nil
+}
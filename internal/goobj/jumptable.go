@@ -0,0 +1,111 @@
+package goobj
+
+import (
+	"regexp"
+	"strconv"
+
+	"loov.dev/lensm/internal/disasm"
+	godisasm "loov.dev/lensm/internal/go/src/disasm"
+	"loov.dev/lensm/internal/go/src/objfile"
+)
+
+// rxTableBound matches the bounds check of a jump table index, e.g.
+// "CMPQ AX, $0x8" or "CMP $8, R0", loong64 loads the bound into a register
+// for the comparison, e.g. "MOVW $8, R5".
+var rxTableBound = regexp.MustCompile(`^(?:CMP\w*\s+(?:\w+,\s*)?\$(0x[\da-fA-F]+|\d+)(?:,\s*\w+)?|MOV\w*\s+\$(0x[\da-fA-F]+|\d+),\s*\w+)$`)
+
+// maxTableDistance is the number of instructions before an indirect jump
+// that are searched for the table and the bounds check.
+const maxTableDistance = 8
+
+// maxTableEntries limits the jump tables without a bounds check.
+const maxTableEntries = 1024
+
+// resolveJumpTables finds the indirect jumps in fn that dispatch through a
+// table and sets their targets. The table address is the nearest memory
+// operand before the jump, see resolveOperands. The entries are either
+// absolute addresses, which the Go compiler emits, or 32-bit offsets from
+// the table, which C compilers emit, and must point into fn.
+func (file *File) resolveJumpTables(dis *godisasm.Disasm, fn objfile.Sym, base uint64, insts []disasm.Inst) {
+	var mem *memory
+	defer func() { _ = mem.Close() }()
+
+	for i := range insts {
+		inst := &insts[i]
+		if !dis.IndirectJump(inst.PC + base) {
+			continue
+		}
+
+		var table disasm.Operand
+		var found bool
+		entries := uint64(maxTableEntries)
+		for k := i; k >= 0 && k >= i-maxTableDistance; k-- {
+			if !found && len(insts[k].Operands) > 0 {
+				table, found = insts[k].Operands[0], true
+			}
+			if match := rxTableBound.FindStringSubmatch(insts[k].Text); match != nil {
+				if n, err := strconv.ParseUint(match[1]+match[2], 0, 64); err == nil && n < maxTableEntries {
+					entries = n + 1
+				}
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+
+		if mem == nil {
+			var err error
+			if mem, err = openMemory(file.region); err != nil {
+				return
+			}
+		}
+		if targets := tableTargets(mem, fn, table.Addr+base, entries); len(targets) > 1 {
+			for k := range targets {
+				targets[k] -= base
+			}
+			inst.SetTargets(targets)
+		}
+	}
+}
+
+// tableTargets reads the jump table at addr with at most n entries, the
+// table ends at the first entry that doesn't point into fn.
+func tableTargets(mem *memory, fn objfile.Sym, addr, n uint64) []uint64 {
+	inside := func(target uint64) bool {
+		return fn.Addr <= target && target < fn.Addr+uint64(fn.Size)
+	}
+
+	var targets []uint64
+	size := uint64(mem.ptrSize)
+	for k := range n {
+		word, err := mem.read(addr+k*size, size)
+		if err != nil {
+			break
+		}
+		target := uint64(mem.order.Uint32(word))
+		if size == 8 {
+			target = mem.order.Uint64(word)
+		}
+		if !inside(target) {
+			break
+		}
+		targets = append(targets, target)
+	}
+	if len(targets) > 0 {
+		return targets
+	}
+
+	for k := range n {
+		word, err := mem.read(addr+k*4, 4)
+		if err != nil {
+			break
+		}
+		target := addr + uint64(int64(int32(mem.order.Uint32(word))))
+		if !inside(target) {
+			break
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
@@ -0,0 +1,165 @@
+package goobj
+
+import (
+	"bytes"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"loov.dev/lensm/internal/disasm"
+	godisasm "loov.dev/lensm/internal/go/src/disasm"
+	"loov.dev/lensm/internal/go/src/objfile"
+)
+
+// rxMoveConst matches a move of a constant, e.g. the length of a string in
+// "MOVL $5, BX" after the pointer was loaded, arm64 spells the move as
+// "ORR $5, ZR, R1".
+var rxMoveConst = regexp.MustCompile(`^(?:MOV\w*|ORR)\s+\$(0x[\da-fA-F]+|\d+),(?: ZR,)?\s*[^,]+$`)
+
+// maxStringDistance is the number of instructions before and after a
+// string pointer that are searched for the length.
+const maxStringDistance = 6
+
+// maxCString is the longest NUL terminated string that is decoded.
+const maxCString = 256
+
+// resolveOperands resolves the memory operands of insts in the function fn
+// to symbols and decodes the strings and floats that they load. The pcs are
+// relative to base. The literal pools in fn, which arm uses for addresses,
+// are followed to the symbol that they point to.
+func (file *File) resolveOperands(dis *godisasm.Disasm, fn objfile.Sym, base uint64, insts []disasm.Inst) {
+	syms := dis.Syms()
+
+	var mem *memory
+	var memErr error
+	open := func() *memory {
+		if mem == nil && memErr == nil {
+			mem, memErr = openMemory(file.region)
+		}
+		return mem
+	}
+	defer func() { _ = mem.Close() }()
+
+	for i := range insts {
+		inst := &insts[i]
+		for _, addr := range dis.MemoryOperands(inst.PC + base) {
+			sym, ok := symbolAt(syms, addr)
+			if !ok {
+				// C compilers leave the literals unnamed.
+				if sym, ok = sectionSymbol(open(), addr); !ok {
+					continue
+				}
+			}
+			if sym.Addr == fn.Addr {
+				mem := open()
+				if mem == nil {
+					continue
+				}
+				ptr, err := mem.readPointer(addr)
+				if err != nil {
+					continue
+				}
+				if sym, ok = symbolAt(syms, ptr); !ok {
+					continue
+				}
+				addr = ptr
+			}
+
+			op := disasm.Operand{
+				Addr:   addr - base,
+				Symbol: sym.Name,
+				Offset: addr - sym.Addr,
+			}
+			if sym.Code == 'R' || sym.Code == 'r' {
+				if mem := open(); mem != nil {
+					op.Value = constantValue(mem, sym, addr, insts, i)
+				}
+			}
+			inst.Operands = append(inst.Operands, op)
+
+			// The operand is more precise than the name in the text, which
+			// drops the $ of e.g. $f64.3ff0000000000000.
+			if inst.Call == "" && strings.Contains(inst.Text, strings.TrimPrefix(sym.Name, "$")) {
+				inst.Symbol, inst.SymbolOffset = sym.Name, op.Offset
+			}
+		}
+	}
+}
+
+// sectionSymbol names addr after the data section that contains it, e.g.
+// ".rodata+0x10".
+func sectionSymbol(mem *memory, addr uint64) (objfile.Sym, bool) {
+	if mem == nil {
+		return objfile.Sym{}, false
+	}
+	section := mem.sectionAt(addr)
+	if section == nil || strings.Contains(section.name, "text") {
+		return objfile.Sym{}, false
+	}
+	code := 'd'
+	if strings.Contains(section.name, "rodata") || strings.Contains(section.name, "rdata") ||
+		strings.Contains(section.name, "const") || strings.Contains(section.name, "cstring") {
+		code = 'r'
+	}
+	return objfile.Sym{Name: section.name, Addr: section.addr, Size: int64(section.size), Code: code}, true
+}
+
+// constantValue decodes the constant at addr in the rodata symbol sym that
+// insts[at] loads.
+func constantValue(mem *memory, sym objfile.Sym, addr uint64, insts []disasm.Inst, at int) string {
+	end := sym.Addr + uint64(sym.Size)
+	mnemonic := insts[at].Mnemonic
+
+	// The compiler names the float constants after their bits, e.g.
+	// $f64.3ff0000000000000, C compilers leave them unnamed.
+	switch {
+	case strings.HasPrefix(sym.Name, "$f64.") || strings.HasSuffix(mnemonic, "SD") || strings.HasSuffix(mnemonic, "SD_XMM"):
+		if b, err := mem.read(addr, 8); err == nil && addr+8 <= end {
+			return strconv.FormatFloat(math.Float64frombits(mem.order.Uint64(b)), 'g', -1, 64)
+		}
+		return ""
+	case strings.HasPrefix(sym.Name, "$f32.") || strings.HasSuffix(mnemonic, "SS"):
+		if b, err := mem.read(addr, 4); err == nil && addr+4 <= end {
+			return strconv.FormatFloat(float64(math.Float32frombits(mem.order.Uint32(b))), 'g', -1, 32)
+		}
+		return ""
+	}
+
+	// The Go strings are grouped, e.g. in go:string.*, without a NUL
+	// between them. C strings are terminated.
+	if !strings.HasSuffix(sym.Name, "*") {
+		s, err := mem.read(addr, min(maxCString, end-addr))
+		if err != nil {
+			return ""
+		}
+		if n := bytes.IndexByte(s, 0); n > 1 && isText(s[:n]) {
+			return strconv.Quote(string(s[:n]))
+		}
+		return ""
+	}
+
+	// A Go string is passed as the pointer and the length, the length is
+	// usually loaded next to the pointer, the nearest move is used.
+	for d := 1; d <= maxStringDistance*2; d++ {
+		k := at + (d+1)/2
+		if d%2 == 0 {
+			k = at - d/2
+		}
+		if k < 0 || k >= len(insts) {
+			continue
+		}
+		match := rxMoveConst.FindStringSubmatch(insts[k].Text)
+		if match == nil {
+			continue
+		}
+		n, err := strconv.ParseUint(match[1], 0, 64)
+		if err != nil || n == 0 || n > maxString || addr+n > end {
+			continue
+		}
+		if s, err := mem.read(addr, n); err == nil && isText(s) {
+			return strconv.Quote(string(s))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,129 @@
+package goobj
+
+import (
+	"encoding/binary"
+
+	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/go/src/objfile"
+)
+
+var _ disasm.SymbolSizer = (*File)(nil)
+
+// SymbolSizes returns the text of the functions, the data symbols and the
+// pclntab of the functions. Archives have only the text.
+func (file *File) SymbolSizes() ([]disasm.SymbolSize, error) {
+	var sizes []disasm.SymbolSize
+	for _, fn := range file.funcs {
+		if size := fn.(*Func).Size(); size > 0 {
+			sizes = append(sizes, disasm.SymbolSize{Name: fn.Name(), Kind: disasm.InText, Size: size})
+		}
+	}
+
+	table := file.inlineTable()
+	if len(file.data) > 0 {
+		mem, err := openMemory(file.region)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = mem.Close() }()
+		for _, fn := range file.data {
+			if size, ok := file.dataSize(mem, table, fn.(*Data).sym); ok {
+				sizes = append(sizes, size)
+			}
+		}
+	}
+	return append(sizes, table.sizes()...), nil
+}
+
+// dataSize returns the bytes of the data symbol in the file, the zero
+// initialized data takes none.
+func (file *File) dataSize(mem *memory, table *inlineTable, sym objfile.Sym) (disasm.SymbolSize, bool) {
+	section := mem.sectionAt(sym.Addr)
+	if section == nil || section.data == nil {
+		return disasm.SymbolSize{}, false
+	}
+	size := min(uint64(sym.Size), section.addr+section.size-sym.Addr)
+	// The groups are sized up to the next symbol, which may be the
+	// pclntab.
+	if table != nil && sym.Addr < table.addr && sym.Addr+size > table.addr {
+		size = table.addr - sym.Addr
+	}
+	kind := disasm.InData
+	if sym.Code == 'R' || sym.Code == 'r' {
+		kind = disasm.InROData
+	}
+	return disasm.SymbolSize{Name: sym.Name, Kind: kind, Size: size}, true
+}
+
+// sizes attributes the bytes of the pclntab to the functions: the entry in
+// the function table, the _func with the offsets, the name and the
+// pc-value tables, e.g. the line numbers. The tables that are shared are
+// counted for the first function. The rest, e.g. the file names, is listed
+// as runtime.pclntab.
+func (table *inlineTable) sizes() []disasm.SymbolSize {
+	if table == nil {
+		return nil
+	}
+	header := table.funcHeaderSize()
+	counted := map[uint32]bool{}
+	var sizes []disasm.SymbolSize
+	var attributed uint64
+	for i := range table.nfunc {
+		funcOff := table.order.Uint32(table.functab[i*8+4:])
+		if uint64(funcOff) >= uint64(len(table.functab)) {
+			continue
+		}
+		fn := table.functab[funcOff:]
+		if len(fn) < header {
+			continue
+		}
+		name := table.funcName(table.order.Uint32(fn[4:]))
+		npcdata := int(table.order.Uint32(fn[28:]))
+		nfuncdata := int(fn[header-1])
+		size := uint64(8 + header + 4*(npcdata+nfuncdata) + len(name) + 1)
+
+		// pcsp, pcfile and pcln are followed by the pcdata.
+		offsets := []int{16, 20, 24}
+		for k := range npcdata {
+			offsets = append(offsets, header+4*k)
+		}
+		for _, at := range offsets {
+			if at+4 > len(fn) {
+				break
+			}
+			off := table.order.Uint32(fn[at:])
+			if off == 0 || counted[off] || uint64(off) >= uint64(len(table.pctab)) {
+				continue
+			}
+			counted[off] = true
+			size += pcvalueSize(table.pctab[off:])
+		}
+
+		sizes = append(sizes, disasm.SymbolSize{Name: name, Kind: disasm.InPCLN, Size: size})
+		attributed += size
+	}
+	if table.size > attributed {
+		sizes = append(sizes, disasm.SymbolSize{Name: "runtime.pclntab", Kind: disasm.InPCLN, Size: table.size - attributed})
+	}
+	return sizes
+}
+
+// pcvalueSize returns the length of the pc-value table at the start of p,
+// which is a sequence of value and pc deltas ending with a zero value delta.
+func pcvalueSize(p []byte) uint64 {
+	size := 0
+	for first := true; ; first = false {
+		value, n := binary.Uvarint(p[size:])
+		if n <= 0 {
+			return uint64(size)
+		}
+		size += n
+		if value == 0 && !first {
+			return uint64(size)
+		}
+		if _, n = binary.Uvarint(p[size:]); n <= 0 {
+			return uint64(size)
+		}
+		size += n
+	}
+}
@@ -0,0 +1,173 @@
+//go:build ignore
+
+// gen_universal writes universal.macho, a minimal universal Mach-O binary
+// with an amd64 and an arm64 slice. Both contain the same functions:
+//
+//	add:  return a + b
+//	main: call add
+//
+// The binary can't be built with the toolchain on Linux and a real one would
+// be several megabytes, so it's assembled by hand.
+package main
+
+import (
+	"bytes"
+	"debug/macho"
+	"encoding/binary"
+	"os"
+)
+
+const textAddr = 0x100000000
+
+type slice struct {
+	cpu    macho.Cpu
+	subcpu uint32
+	add    []byte
+	// main assembles a call to add, which is at rel bytes from main.
+	main func(rel int32) []byte
+}
+
+func main() {
+	slices := []slice{
+		{
+			cpu:    macho.CpuAmd64,
+			subcpu: 3,
+			add:    []byte{0x8d, 0x04, 0x37, 0xc3}, // LEAL (DI)(SI*1), AX; RET
+			main: func(rel int32) []byte {
+				// CALL add; RET, relative to the next instruction.
+				return append(le32(0xe8, uint32(rel-5)), 0xc3)
+			},
+		},
+		{
+			cpu:    macho.CpuArm64,
+			subcpu: 0,
+			add:    []byte{0x00, 0x00, 0x01, 0x0b, 0xc0, 0x03, 0x5f, 0xd6}, // ADDW R1, R0, R0; RET
+			main: func(rel int32) []byte {
+				// BL add; RET
+				bl := 0x94000000 | uint32(rel/4)&0x03ffffff
+				return append(le32(0, bl)[1:], 0xc0, 0x03, 0x5f, 0xd6)
+			},
+		},
+	}
+
+	// Slices are aligned to 1<<align bytes.
+	const align = 4
+	pad := func(n int) int { return (n + 1<<align - 1) &^ (1<<align - 1) }
+
+	be := binary.BigEndian
+	out := be.AppendUint32(nil, macho.MagicFat)
+	out = be.AppendUint32(out, uint32(len(slices)))
+
+	datas := make([][]byte, len(slices))
+	offset := pad(8 + 20*len(slices))
+	for i, s := range slices {
+		datas[i] = s.build()
+		for _, v := range []uint32{uint32(s.cpu), s.subcpu, uint32(offset), uint32(len(datas[i])), align} {
+			out = be.AppendUint32(out, v)
+		}
+		offset = pad(offset + len(datas[i]))
+	}
+	for _, data := range datas {
+		out = append(out, make([]byte, pad(len(out))-len(out))...)
+		out = append(out, data...)
+	}
+
+	if err := os.WriteFile("universal.macho", out, 0644); err != nil {
+		panic(err)
+	}
+}
+
+// build assembles a 64-bit Mach-O executable with a single __TEXT segment.
+func (s slice) build() []byte {
+	const (
+		headerSize  = 32
+		segmentSize = 72 + 80
+		symtabSize  = 24
+		textOffset  = headerSize + segmentSize + symtabSize
+	)
+
+	add := s.add
+	main := s.main(-int32(len(add)))
+	text := append(append([]byte{}, add...), main...)
+	for len(text)%8 != 0 {
+		text = append(text, 0)
+	}
+
+	strtab := []byte("\x00_add\x00_main\x00_etext\x00")
+	type nlist struct {
+		Strx  uint32
+		Type  uint8
+		Sect  uint8
+		Desc  uint16
+		Value uint64
+	}
+	const sectExt = 0x0e | 0x01 // N_SECT | N_EXT
+	symbols := []nlist{
+		{Strx: 1, Type: sectExt, Sect: 1, Value: textAddr + textOffset},
+		{Strx: 6, Type: sectExt, Sect: 1, Value: textAddr + textOffset + uint64(len(add))},
+		// _etext marks the end of the last function.
+		{Strx: 12, Type: sectExt, Sect: 1, Value: textAddr + textOffset + uint64(len(add)+len(main))},
+	}
+	symoff := uint32(textOffset + len(text))
+	stroff := symoff + uint32(16*len(symbols))
+	size := stroff + uint32(len(strtab))
+
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	write := func(v any) { binary.Write(&buf, le, v) }
+
+	write(macho.FileHeader{
+		Magic:  macho.Magic64,
+		Cpu:    s.cpu,
+		SubCpu: s.subcpu,
+		Type:   macho.TypeExec,
+		Ncmd:   2,
+		Cmdsz:  segmentSize + symtabSize,
+	})
+	write(uint32(0)) // reserved
+
+	write(macho.Segment64{
+		Cmd:     macho.LoadCmdSegment64,
+		Len:     segmentSize,
+		Name:    name16("__TEXT"),
+		Addr:    textAddr,
+		Memsz:   0x1000,
+		Offset:  0,
+		Filesz:  uint64(size),
+		Maxprot: 5,
+		Prot:    5,
+		Nsect:   1,
+	})
+	write(macho.Section64{
+		Name:   name16("__text"),
+		Seg:    name16("__TEXT"),
+		Addr:   textAddr + textOffset,
+		Size:   uint64(len(add) + len(main)),
+		Offset: textOffset,
+		Align:  2,
+		Flags:  0x80000400, // S_ATTR_PURE_INSTRUCTIONS | S_ATTR_SOME_INSTRUCTIONS
+	})
+	write(macho.SymtabCmd{
+		Cmd:     macho.LoadCmdSymtab,
+		Len:     symtabSize,
+		Symoff:  symoff,
+		Nsyms:   uint32(len(symbols)),
+		Stroff:  stroff,
+		Strsize: uint32(len(strtab)),
+	})
+
+	buf.Write(text)
+	write(symbols)
+	buf.Write(strtab)
+	return buf.Bytes()
+}
+
+func name16(s string) (name [16]byte) {
+	copy(name[:], s)
+	return name
+}
+
+// le32 returns prefix followed by v in little endian.
+func le32(prefix byte, v uint32) []byte {
+	return binary.LittleEndian.AppendUint32([]byte{prefix}, v)
+}
@@ -0,0 +1,140 @@
+package goobj
+
+import (
+	"debug/macho"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/go/src/objfile"
+)
+
+var _ disasm.File = (*Universal)(nil)
+var _ disasm.ArchSelector = (*Universal)(nil)
+var _ disasm.InlineIndex = (*Universal)(nil)
+var _ disasm.CallIndex = (*Universal)(nil)
+var _ disasm.SymbolSizer = (*Universal)(nil)
+var _ disasm.DataFile = (*Universal)(nil)
+
+// Universal contains a file for each architecture of an universal Mach-O
+// binary. The functions are listed for the selected architecture.
+type Universal struct {
+	arches []string
+	files  []*File
+
+	mu       sync.Mutex
+	selected int
+}
+
+// LoadUniversal loads every architecture of the universal binary at path,
+// the current GOARCH is selected by default when it's available.
+func LoadUniversal(path string) (*Universal, error) {
+	fat, err := macho.OpenFat(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fat.Close()
+
+	universal := &Universal{}
+	var errs []error
+	for _, arch := range fat.Arches {
+		file, err := loadSlice(fileRegion{path: path, offset: int64(arch.Offset), size: int64(arch.Size)})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", arch.Cpu, err))
+			continue
+		}
+
+		name := file.objfile.GOARCH()
+		if name == "" {
+			name = strings.ToLower(strings.TrimPrefix(arch.Cpu.String(), "Cpu"))
+		}
+		universal.arches = append(universal.arches, name)
+		universal.files = append(universal.files, file)
+		if name == runtime.GOARCH {
+			universal.selected = len(universal.files) - 1
+		}
+	}
+	if len(universal.files) == 0 {
+		return nil, fmt.Errorf("open %s: %w", path, errors.Join(errs...))
+	}
+	return universal, nil
+}
+
+// loadSlice loads a single architecture of an universal binary.
+func loadSlice(region fileRegion) (*File, error) {
+	r, err := os.Open(region.path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := objfile.OpenSection(r, io.NewSectionReader(r, region.offset, region.size))
+	if err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	return load(f, region)
+}
+
+func (universal *Universal) file() *File {
+	universal.mu.Lock()
+	defer universal.mu.Unlock()
+	return universal.files[universal.selected]
+}
+
+// Archs returns the architectures in the order they are stored.
+func (universal *Universal) Archs() []string { return universal.arches }
+
+// Arch returns the selected architecture.
+func (universal *Universal) Arch() string {
+	universal.mu.Lock()
+	defer universal.mu.Unlock()
+	return universal.arches[universal.selected]
+}
+
+// SelectArch selects the architecture used by Funcs, Data, InlinedInto and
+// the call index.
+func (universal *Universal) SelectArch(arch string) error {
+	universal.mu.Lock()
+	defer universal.mu.Unlock()
+	for i, name := range universal.arches {
+		if name == arch {
+			universal.selected = i
+			return nil
+		}
+	}
+	return fmt.Errorf("architecture %q not found, available: %s", arch, strings.Join(universal.arches, ", "))
+}
+
+func (universal *Universal) Funcs() []disasm.Func { return universal.file().Funcs() }
+
+func (universal *Universal) Data() []disasm.Func { return universal.file().Data() }
+
+func (universal *Universal) InlinedInto(name string) ([]disasm.InlineSite, error) {
+	return universal.file().InlinedInto(name)
+}
+
+func (universal *Universal) IndexCalls() { universal.file().IndexCalls() }
+
+func (universal *Universal) Callers(name string) ([]disasm.CallSite, error) {
+	return universal.file().Callers(name)
+}
+
+func (universal *Universal) Callees(name string) ([]disasm.CallSite, error) {
+	return universal.file().Callees(name)
+}
+
+func (universal *Universal) SymbolSizes() ([]disasm.SymbolSize, error) {
+	return universal.file().SymbolSizes()
+}
+
+func (universal *Universal) Close() error {
+	var errs []error
+	for _, file := range universal.files {
+		errs = append(errs, file.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,69 @@
+package goobj
+
+import (
+	"slices"
+	"testing"
+
+	"loov.dev/lensm/internal/disasm"
+)
+
+//go:generate go run testdata/gen_universal.go
+
+func TestLoadUniversal(t *testing.T) {
+	file, err := disasm.Open("testdata/universal.macho")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = file.Close() })
+
+	if _, ok := file.(disasm.DataFile); !ok {
+		t.Errorf("%T does not implement disasm.DataFile", file)
+	}
+
+	selector, ok := file.(disasm.ArchSelector)
+	if !ok {
+		t.Fatalf("%T does not implement disasm.ArchSelector", file)
+	}
+	if got, want := selector.Archs(), []string{"amd64", "arm64"}; !slices.Equal(got, want) {
+		t.Fatalf("Archs() = %v, want %v", got, want)
+	}
+
+	for _, arch := range selector.Archs() {
+		if err := disasm.SelectArch(file, arch); err != nil {
+			t.Fatal(err)
+		}
+		if got := selector.Arch(); got != arch {
+			t.Errorf("Arch() = %q, want %q", got, arch)
+		}
+
+		var main disasm.Func
+		var names []string
+		for _, fn := range file.Funcs() {
+			names = append(names, fn.Name())
+			if fn.Name() == "_main" {
+				main = fn
+			}
+		}
+		if !slices.Equal(names, []string{"_add", "_main"}) {
+			t.Errorf("%s: Funcs() = %v, want [_add _main]", arch, names)
+		}
+		if main == nil {
+			continue
+		}
+
+		code, err := main.Load(disasm.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code.Arch != arch {
+			t.Errorf("code.Arch = %q, want %q", code.Arch, arch)
+		}
+		if len(code.Insts) != 2 || code.Insts[0].Call != "_add" {
+			t.Errorf("%s: main doesn't call _add: %+v", arch, code.Insts)
+		}
+	}
+
+	if err := selector.SelectArch("ppc64"); err == nil {
+		t.Error("SelectArch(ppc64) succeeded")
+	}
+}
@@ -27,6 +27,10 @@ type FilterList[T FilterListItem] struct {
 	Selected     string
 	SelectedItem T
 
+	// Label returns the text of an item, the Name when it's nil. The
+	// filter always matches the Name.
+	Label func(item T) string
+
 	List SelectList
 }
 
@@ -133,6 +137,9 @@ func (ui *FilterList[T]) Layout(th *Theme, gtx layout.Context) layout.Dimensions
 		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
 			return ui.List.Layout(th.Theme, gtx, len(ui.Filtered),
 				StringListItem(th.Theme, &ui.List, func(index int) string {
+					if ui.Label != nil {
+						return ui.Label(ui.Filtered[index])
+					}
 					return ui.Filtered[index].Name()
 				}))
 		}),
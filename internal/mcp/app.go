@@ -14,6 +14,8 @@ import (
 	"time"
 
 	"loov.dev/lensm/internal/comments"
+	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/profile"
 )
 
 type AppServer struct {
@@ -27,6 +29,7 @@ type AppServer struct {
 	session    *Session
 	loadError  error
 	generation uint64
+	profile    *profile.Profile
 	// active counts in-flight requests using session; a replaced
 	// session is closed only once they have finished.
 	active *sync.WaitGroup
@@ -73,7 +76,9 @@ func (server *AppServer) URL() string {
 	return server.url
 }
 
-func (server *AppServer) SetPath(path string, store *comments.Store) {
+// SetPath loads the session for path, arch selects the architecture of
+// universal binaries.
+func (server *AppServer) SetPath(path string, store *comments.Store, sourceMap *disasm.SourceMap, arch string) {
 	if server == nil {
 		return
 	}
@@ -102,10 +107,30 @@ func (server *AppServer) SetPath(path string, store *comments.Store) {
 			server.replaceSession(generation, nil, err)
 			return
 		}
+		session.SourceMap = sourceMap
+		if err := disasm.SelectArch(session.File, arch); err != nil {
+			_ = session.Close()
+			server.replaceSession(generation, nil, err)
+			return
+		}
 		server.replaceSession(generation, session, nil)
 	}()
 }
 
+// SetProfile attributes the samples of p to the functions of the current
+// and the later sessions, nil removes the profile.
+func (server *AppServer) SetProfile(p *profile.Profile) {
+	if server == nil {
+		return
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.profile = p
+	if server.session != nil {
+		server.session.SetProfile(p)
+	}
+}
+
 func (server *AppServer) Close() error {
 	if server == nil {
 		return nil
@@ -139,6 +164,9 @@ func (server *AppServer) replaceSession(generation uint64, session *Session, loa
 		return
 	}
 	old, oldActive := server.session, server.active
+	if session != nil {
+		session.SetProfile(server.profile)
+	}
 	server.session = session
 	server.loadError = loadErr
 	server.active = &sync.WaitGroup{}
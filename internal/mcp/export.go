@@ -3,6 +3,7 @@ package mcp
 import (
 	"loov.dev/lensm/internal/comments"
 	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/profile"
 )
 
 type LineRangeDTO struct {
@@ -18,11 +19,35 @@ type FunctionCodeDTO struct {
 	GoAsm     []AsmLineDTO      `json:"go_asm"`
 	NativeAsm []AsmLineDTO      `json:"native_asm"`
 	Comments  []comments.Record `json:"comments,omitempty"`
+	// Profile is the share of the samples in the function, set when a
+	// profile is loaded.
+	Profile *FunctionProfileDTO `json:"profile,omitempty"`
+}
+
+type FunctionProfileDTO struct {
+	Type  string `json:"type"`
+	Unit  string `json:"unit"`
+	Total int64  `json:"total"`
+	WeightDTO
+}
+
+// WeightDTO is the weight of the samples that were executing at a place in
+// the code, flat, or were calling from there, cum.
+type WeightDTO struct {
+	Flat        int64   `json:"flat"`
+	Cum         int64   `json:"cum"`
+	FlatPercent float64 `json:"flat_percent"`
+	CumPercent  float64 `json:"cum_percent"`
 }
 
 type SourceFileDTO struct {
-	File   string           `json:"file"`
-	Blocks []SourceBlockDTO `json:"blocks"`
+	File    string `json:"file"`
+	Missing bool   `json:"missing,omitempty"`
+	// Stale is set when the file changed since the binary was built, the
+	// lines may then not match the assembly.
+	Stale       bool             `json:"stale,omitempty"`
+	StaleReason string           `json:"stale_reason,omitempty"`
+	Blocks      []SourceBlockDTO `json:"blocks"`
 }
 
 type SourceBlockDTO struct {
@@ -37,20 +62,67 @@ type SourceLineDTO struct {
 	Text    string         `json:"text"`
 	Related []LineRangeDTO `json:"related,omitempty"`
 	Comment string         `json:"comment,omitempty"`
+	Profile *WeightDTO     `json:"profile,omitempty"`
+}
+
+type InlineFrameDTO struct {
+	Func string `json:"func"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+type InlineSiteDTO struct {
+	Caller string       `json:"caller"`
+	File   string       `json:"file"`
+	Line   int          `json:"line"`
+	Size   uint64       `json:"size"`
+	Ranges []PCRangeDTO `json:"ranges"`
+}
+
+type CallSiteDTO struct {
+	Caller string `json:"caller"`
+	Callee string `json:"callee"`
+	PC     uint64 `json:"pc"`
+	PCHex  string `json:"pc_hex"`
+	Kind   string `json:"kind"`
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+}
+
+type PCRangeDTO struct {
+	Start    uint64 `json:"start"`
+	End      uint64 `json:"end"`
+	StartHex string `json:"start_hex"`
+	EndHex   string `json:"end_hex"`
 }
 
 type AsmLineDTO struct {
-	Index     int    `json:"index"`
-	PC        uint64 `json:"pc"`
-	PCHex     string `json:"pc_hex"`
-	Text      string `json:"text"`
-	File      string `json:"file,omitempty"`
-	Line      int    `json:"line,omitempty"`
-	Call      string `json:"call,omitempty"`
-	RefPC     uint64 `json:"ref_pc,omitempty"`
-	RefPCHex  string `json:"ref_pc_hex,omitempty"`
-	RefOffset int    `json:"ref_offset,omitempty"`
-	Comment   string `json:"comment,omitempty"`
+	Index        int              `json:"index"`
+	PC           uint64           `json:"pc"`
+	PCHex        string           `json:"pc_hex"`
+	Text         string           `json:"text"`
+	File         string           `json:"file,omitempty"`
+	Line         int              `json:"line,omitempty"`
+	Inlined      []InlineFrameDTO `json:"inlined,omitempty"`
+	Call         string           `json:"call,omitempty"`
+	Symbol       string           `json:"symbol,omitempty"`
+	SymbolOffset uint64           `json:"symbol_offset,omitempty"`
+	Operands     []OperandDTO     `json:"operands,omitempty"`
+	RefPC        uint64           `json:"ref_pc,omitempty"`
+	RefPCHex     string           `json:"ref_pc_hex,omitempty"`
+	RefOffset    int              `json:"ref_offset,omitempty"`
+	Targets      []uint64         `json:"targets,omitempty"`
+	TargetsHex   []string         `json:"targets_hex,omitempty"`
+	Comment      string           `json:"comment,omitempty"`
+	Profile      *WeightDTO       `json:"profile,omitempty"`
+}
+
+type OperandDTO struct {
+	Addr    uint64 `json:"addr"`
+	AddrHex string `json:"addr_hex"`
+	Symbol  string `json:"symbol"`
+	Offset  uint64 `json:"offset,omitempty"`
+	Value   string `json:"value,omitempty"`
 }
 
 func BuildFunctionCodeDTO(binary string, code *disasm.Code, store *comments.Store) FunctionCodeDTO {
@@ -65,7 +137,12 @@ func BuildFunctionCodeDTO(binary string, code *disasm.Code, store *comments.Stor
 		Comments: store.Filter(code.Name, ""),
 	}
 	for _, src := range code.Source {
-		srcDTO := SourceFileDTO{File: src.File}
+		srcDTO := SourceFileDTO{
+			File:        src.File,
+			Missing:     src.Missing,
+			Stale:       src.Stale,
+			StaleReason: src.StaleReason,
+		}
 		for _, block := range src.Blocks {
 			blockDTO := SourceBlockDTO{
 				From: block.From,
@@ -107,6 +184,54 @@ func BuildFunctionCodeDTO(binary string, code *disasm.Code, store *comments.Stor
 	return dto
 }
 
+// addProfile adds the weights of the function, its instructions and its
+// source lines in the profile.
+func addProfile(dto *FunctionCodeDTO, code *disasm.Code, overlay *profile.Overlay) {
+	if overlay == nil || code == nil || code.Data != nil {
+		return
+	}
+	dto.Profile = &FunctionProfileDTO{
+		Type:      overlay.Type.Type,
+		Unit:      overlay.Type.Unit,
+		Total:     overlay.Total,
+		WeightDTO: weightDTO(overlay, overlay.Funcs[code.Name]),
+	}
+	weights := overlay.Code(code)
+	if weights == nil {
+		return
+	}
+	for i, w := range weights.Insts {
+		if w == (profile.Weight{}) || i >= len(dto.GoAsm) {
+			continue
+		}
+		weight := weightDTO(overlay, w)
+		dto.GoAsm[i].Profile = &weight
+		dto.NativeAsm[i].Profile = &weight
+	}
+	for _, src := range dto.Source {
+		for _, block := range src.Blocks {
+			for i := range block.Lines {
+				line := &block.Lines[i]
+				w, ok := weights.Lines[profile.SourceLine{File: line.File, Line: line.Line}]
+				if !ok {
+					continue
+				}
+				weight := weightDTO(overlay, w)
+				line.Profile = &weight
+			}
+		}
+	}
+}
+
+func weightDTO(overlay *profile.Overlay, w profile.Weight) WeightDTO {
+	return WeightDTO{
+		Flat:        w.Flat,
+		Cum:         w.Cum,
+		FlatPercent: overlay.Percent(w.Flat),
+		CumPercent:  overlay.Percent(w.Cum),
+	}
+}
+
 func lineRangesDTO(ranges []disasm.LineRange) []LineRangeDTO {
 	if len(ranges) == 0 {
 		return nil
@@ -118,21 +243,78 @@ func lineRangesDTO(ranges []disasm.LineRange) []LineRangeDTO {
 	return out
 }
 
+func inlineFramesDTO(frames []disasm.InlineFrame) []InlineFrameDTO {
+	if len(frames) == 0 {
+		return nil
+	}
+	out := make([]InlineFrameDTO, 0, len(frames))
+	for _, frame := range frames {
+		out = append(out, InlineFrameDTO{Func: frame.Func, File: frame.File, Line: frame.Line})
+	}
+	return out
+}
+
+func inlineSiteDTO(site disasm.InlineSite) InlineSiteDTO {
+	dto := InlineSiteDTO{
+		Caller: site.Caller,
+		File:   site.File,
+		Line:   site.Line,
+		Size:   site.Size,
+	}
+	for _, r := range site.Ranges {
+		dto.Ranges = append(dto.Ranges, PCRangeDTO{
+			Start:    r.Start,
+			End:      r.End,
+			StartHex: comments.FormatPC(r.Start),
+			EndHex:   comments.FormatPC(r.End),
+		})
+	}
+	return dto
+}
+
+func callSiteDTO(site disasm.CallSite) CallSiteDTO {
+	return CallSiteDTO{
+		Caller: site.Caller,
+		Callee: site.Callee,
+		PC:     site.PC,
+		PCHex:  comments.FormatPC(site.PC),
+		Kind:   site.Kind.String(),
+		File:   site.File,
+		Line:   site.Line,
+	}
+}
+
 func asmLineDTO(index int, inst disasm.Inst, text string) AsmLineDTO {
 	line := AsmLineDTO{
-		Index:     index,
-		PC:        inst.PC,
-		PCHex:     comments.FormatPC(inst.PC),
-		Text:      text,
-		File:      inst.File,
-		Line:      inst.Line,
-		Call:      inst.Call,
-		RefPC:     inst.RefPC,
-		RefOffset: inst.RefOffset,
+		Index:        index,
+		PC:           inst.PC,
+		PCHex:        comments.FormatPC(inst.PC),
+		Text:         text,
+		File:         inst.File,
+		Line:         inst.Line,
+		Inlined:      inlineFramesDTO(inst.Inlined),
+		Call:         inst.Call,
+		Symbol:       inst.Symbol,
+		SymbolOffset: inst.SymbolOffset,
+		RefPC:        inst.RefPC,
+		RefOffset:    inst.RefOffset,
+	}
+	for _, op := range inst.Operands {
+		line.Operands = append(line.Operands, OperandDTO{
+			Addr:    op.Addr,
+			AddrHex: comments.FormatPC(op.Addr),
+			Symbol:  op.Symbol,
+			Offset:  op.Offset,
+			Value:   op.Value,
+		})
 	}
 	if inst.RefPC != 0 {
 		line.RefPCHex = comments.FormatPC(inst.RefPC)
 	}
+	for _, target := range inst.Targets {
+		line.Targets = append(line.Targets, target)
+		line.TargetsHex = append(line.TargetsHex, comments.FormatPC(target))
+	}
 	return line
 }
 
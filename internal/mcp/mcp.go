@@ -3,6 +3,7 @@ package mcp
 import (
 	"bufio"
 	"bytes"
+	"cmp"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -10,10 +11,13 @@ import (
 	"io"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
 	"loov.dev/lensm/internal/comments"
+	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/profile"
 )
 
 const mcpProtocolVersion = "2025-06-18"
@@ -67,11 +71,19 @@ func RunCommand(load LoadFile, args []string) int {
 	fs := flag.NewFlagSet("lensm mcp", flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
 	commentsPath := fs.String("comments", "", "comments sidecar path")
+	sourceMap := &disasm.SourceMap{}
+	fs.Func("source-map", "rewrite source path prefix `from=to`, can be repeated", func(s string) error {
+		rule, err := disasm.ParsePathRule(s)
+		sourceMap.Rules = append(sourceMap.Rules, rule)
+		return err
+	})
+	arch := fs.String("arch", "", "architecture of an universal binary")
+	profilePath := fs.String("profile", "", "pprof CPU profile to attribute to the functions")
 	if err := fs.Parse(args); err != nil {
 		return 2
 	}
 	if fs.NArg() != 1 {
-		fmt.Fprintln(os.Stderr, "usage: lensm mcp [-comments path] <exePath>")
+		fmt.Fprintln(os.Stderr, "usage: lensm mcp [-comments path] [-source-map from=to] [-arch goarch] [-profile cpu.pprof] <exePath>")
 		return 2
 	}
 
@@ -81,6 +93,19 @@ func RunCommand(load LoadFile, args []string) int {
 		return 1
 	}
 	defer session.Close()
+	session.SourceMap = sourceMap
+	if err := disasm.SelectArch(session.File, *arch); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *profilePath != "" {
+		p, err := profile.Load(*profilePath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		session.SetProfile(p)
+	}
 
 	server := &mcpServer{
 		session: session,
@@ -208,6 +233,12 @@ func (server *mcpServer) handleToolCall(params json.RawMessage) (any, *rpcError)
 		result, err = server.toolSetComment(req.Arguments)
 	case "get_comments":
 		result, err = server.toolGetComments(req.Arguments)
+	case "find_inlined_into":
+		result, err = server.toolFindInlinedInto(req.Arguments)
+	case "find_callers":
+		result, err = server.toolFindCalls(req.Arguments, server.session.Callers)
+	case "find_callees":
+		result, err = server.toolFindCalls(req.Arguments, server.session.Callees)
 	default:
 		return nil, &rpcError{Code: -32602, Message: "unknown tool: " + req.Name}
 	}
@@ -220,20 +251,24 @@ func (server *mcpServer) handleToolCall(params json.RawMessage) (any, *rpcError)
 func (server *mcpServer) toolListFunctions(args json.RawMessage) (any, error) {
 	var req struct {
 		Filter string `json:"filter"`
+		Sort   string `json:"sort"`
 		Limit  int    `json:"limit"`
 		Offset int    `json:"offset"`
 	}
 	if err := decodeJSON(args, &req); err != nil {
 		return nil, err
 	}
-	if req.Limit <= 0 {
-		req.Limit = 100
-	}
-	if req.Limit > 1000 {
-		req.Limit = 1000
-	}
-	if req.Offset < 0 {
-		req.Offset = 0
+	req.Limit, req.Offset = pageBounds(req.Limit, req.Offset)
+
+	overlay := server.session.Overlay()
+	switch req.Sort {
+	case "", "index":
+	case "flat", "cum":
+		if overlay == nil {
+			return nil, errors.New("sorting by weight requires a profile")
+		}
+	default:
+		return nil, fmt.Errorf("unknown sort %q", req.Sort)
 	}
 
 	var rx *regexp.Regexp
@@ -246,8 +281,11 @@ func (server *mcpServer) toolListFunctions(args json.RawMessage) (any, error) {
 	}
 
 	type functionInfo struct {
-		Index int    `json:"index"`
-		Name  string `json:"name"`
+		Index   int        `json:"index"`
+		Name    string     `json:"name"`
+		Profile *WeightDTO `json:"profile,omitempty"`
+
+		weight profile.Weight
 	}
 	var all []functionInfo
 	for i, fn := range server.session.Funcs() {
@@ -255,22 +293,54 @@ func (server *mcpServer) toolListFunctions(args json.RawMessage) (any, error) {
 		if rx != nil && !rx.MatchString(name) {
 			continue
 		}
-		all = append(all, functionInfo{Index: i, Name: name})
+		info := functionInfo{Index: i, Name: name}
+		if overlay != nil {
+			if w, ok := overlay.Funcs[name]; ok {
+				weight := weightDTO(overlay, w)
+				info.Profile, info.weight = &weight, w
+			}
+		}
+		all = append(all, info)
 	}
-
-	var page []functionInfo
-	if req.Offset < len(all) {
-		page = all[req.Offset:min(req.Offset+req.Limit, len(all))]
+	switch req.Sort {
+	case "flat":
+		slices.SortStableFunc(all, func(a, b functionInfo) int {
+			return cmp.Or(cmp.Compare(b.weight.Flat, a.weight.Flat), cmp.Compare(b.weight.Cum, a.weight.Cum))
+		})
+	case "cum":
+		slices.SortStableFunc(all, func(a, b functionInfo) int {
+			return cmp.Or(cmp.Compare(b.weight.Cum, a.weight.Cum), cmp.Compare(b.weight.Flat, a.weight.Flat))
+		})
 	}
+
 	return map[string]any{
 		"binary":    server.session.Path,
-		"functions": page,
+		"functions": page(all, req.Limit, req.Offset),
 		"total":     len(all),
 		"offset":    req.Offset,
 		"limit":     req.Limit,
 	}, nil
 }
 
+// pageBounds applies the defaults and the caps of the paginated tools.
+func pageBounds(limit, offset int) (int, int) {
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	return limit, max(offset, 0)
+}
+
+// page returns the items from offset on, at most limit.
+func page[T any](all []T, limit, offset int) []T {
+	if offset >= len(all) {
+		return nil
+	}
+	return all[offset:min(offset+limit, len(all))]
+}
+
 func (server *mcpServer) toolGetFunction(args json.RawMessage) (any, error) {
 	var req struct {
 		Name    string `json:"name"`
@@ -296,7 +366,38 @@ func (server *mcpServer) toolGetFunction(args json.RawMessage) (any, error) {
 	if err != nil {
 		return nil, err
 	}
-	return BuildFunctionCodeDTO(server.session.Path, code, server.session.Comments), nil
+	dto := BuildFunctionCodeDTO(server.session.Path, code, server.session.Comments)
+	addProfile(&dto, code, server.session.Overlay())
+	return dto, nil
+}
+
+func (server *mcpServer) toolFindInlinedInto(args json.RawMessage) (any, error) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := decodeJSON(args, &req); err != nil {
+		return nil, err
+	}
+	if req.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	sites, err := server.session.InlinedInto(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	var total uint64
+	dtos := make([]InlineSiteDTO, 0, len(sites))
+	for _, site := range sites {
+		total += site.Size
+		dtos = append(dtos, inlineSiteDTO(site))
+	}
+	return map[string]any{
+		"binary": server.session.Path,
+		"name":   req.Name,
+		"sites":  dtos,
+		"size":   total,
+	}, nil
 }
 
 func (server *mcpServer) toolSetComment(args json.RawMessage) (any, error) {
@@ -449,14 +550,48 @@ func toolError(err error) mcpToolResult {
 	}
 }
 
+// toolFindCalls pages the calls to or from a function that lookup returns.
+func (server *mcpServer) toolFindCalls(args json.RawMessage, lookup func(name string) ([]disasm.CallSite, error)) (any, error) {
+	var req struct {
+		Name   string `json:"name"`
+		Limit  int    `json:"limit"`
+		Offset int    `json:"offset"`
+	}
+	if err := decodeJSON(args, &req); err != nil {
+		return nil, err
+	}
+	if req.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	req.Limit, req.Offset = pageBounds(req.Limit, req.Offset)
+
+	sites, err := lookup(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	calls := make([]CallSiteDTO, 0, req.Limit)
+	for _, site := range page(sites, req.Limit, req.Offset) {
+		calls = append(calls, callSiteDTO(site))
+	}
+	return map[string]any{
+		"binary": server.session.Path,
+		"name":   req.Name,
+		"calls":  calls,
+		"total":  len(sites),
+		"offset": req.Offset,
+		"limit":  req.Limit,
+	}, nil
+}
+
 func mcpTools() []mcpTool {
 	return []mcpTool{
 		{
 			Name:        "list_functions",
 			Title:       "List Functions",
-			Description: "List functions in the loaded executable. The optional filter is a case-insensitive Go regexp.",
+			Description: "List functions in the loaded executable. The optional filter is a case-insensitive Go regexp. With a profile loaded the functions include their share of the samples and can be sorted by it.",
 			InputSchema: objectSchema(map[string]any{
 				"filter": stringSchema("Optional case-insensitive regexp matched against function names."),
+				"sort":   enumSchema("Order of the functions, by index in the executable or by the flat or cumulative weight in the profile.", []string{"index", "flat", "cum"}),
 				"limit":  integerSchema("Maximum number of functions to return. Defaults to 100, capped at 1000."),
 				"offset": integerSchema("Number of matching functions to skip."),
 			}, nil),
@@ -464,7 +599,7 @@ func mcpTools() []mcpTool {
 		{
 			Name:        "get_function",
 			Title:       "Get Function Code",
-			Description: "Return Go source, Go assembly, native assembly, source-to-asm mappings, and comments for a function.",
+			Description: "Return Go source, Go assembly, native assembly, source-to-asm mappings, inlined call chains, and comments for a function. Data symbols, such as the symbol of an instruction, are returned as a hex dump with the pointers resolved. Source files marked stale changed since the binary was built, their lines may not match the assembly. With a profile loaded the function, the instructions and the source lines include their flat and cumulative share of the samples.",
 			InputSchema: objectSchema(map[string]any{
 				"name":    stringSchema("Exact function name."),
 				"context": integerSchema("Number of extra source lines to include before and after referenced lines. Defaults to 3."),
@@ -487,6 +622,34 @@ func mcpTools() []mcpTool {
 				"text": stringSchema("Comment text. Empty string deletes the comment."),
 			}, []string{"name", "view", "text"}),
 		},
+		{
+			Name:        "find_inlined_into",
+			Title:       "Find Inlined Into",
+			Description: "List the functions and call sites where a function was inlined, with the PC ranges and the size of the inlined code, largest first. Code inlined through other inlined calls is included.",
+			InputSchema: objectSchema(map[string]any{
+				"name": stringSchema("Exact name of the inlined function."),
+			}, []string{"name"}),
+		},
+		{
+			Name:        "find_callers",
+			Title:       "Find Callers",
+			Description: "List the calls to a function from the whole executable, grouped by the calling function. Direct calls, tail calls and closures whose target is known are included, the kind is call, tail or closure. The first call waits for the executable to be indexed.",
+			InputSchema: objectSchema(map[string]any{
+				"name":   stringSchema("Exact name of the called function."),
+				"limit":  integerSchema("Maximum number of calls to return. Defaults to 100, capped at 1000."),
+				"offset": integerSchema("Number of calls to skip."),
+			}, []string{"name"}),
+		},
+		{
+			Name:        "find_callees",
+			Title:       "Find Callees",
+			Description: "List the calls from a function in the order of the instructions, with the same kinds as find_callers.",
+			InputSchema: objectSchema(map[string]any{
+				"name":   stringSchema("Exact name of the calling function."),
+				"limit":  integerSchema("Maximum number of calls to return. Defaults to 100, capped at 1000."),
+				"offset": integerSchema("Number of calls to skip."),
+			}, []string{"name"}),
+		},
 		{
 			Name:        "get_comments",
 			Title:       "Get Comments",
@@ -3,6 +3,7 @@ package mcp
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http/httptest"
 	"path/filepath"
 	"strings"
@@ -11,6 +12,7 @@ import (
 
 	"loov.dev/lensm/internal/disasm"
 	"loov.dev/lensm/internal/goobj"
+	"loov.dev/lensm/internal/profile"
 )
 
 func TestAppMCPServerSetPathClearsStaleSessionOnLoadFailure(t *testing.T) {
@@ -19,7 +21,7 @@ func TestAppMCPServerSetPathClearsStaleSessionOnLoadFailure(t *testing.T) {
 		session: &Session{Path: "old"},
 	}
 
-	server.SetPath(filepath.Join(t.TempDir(), "missing-binary"), nil)
+	server.SetPath(filepath.Join(t.TempDir(), "missing-binary"), nil, nil, "")
 
 	server.mu.Lock()
 	if server.session != nil {
@@ -111,3 +113,112 @@ func TestMCPToolCallReportsLoadError(t *testing.T) {
 		t.Fatalf("tool error content = %#v", toolResult.Content)
 	}
 }
+
+// callIndexTestFile calls each function from five others.
+type callIndexTestFile struct{}
+
+func (callIndexTestFile) Funcs() []disasm.Func { return nil }
+func (callIndexTestFile) Close() error         { return nil }
+func (callIndexTestFile) IndexCalls()          {}
+
+func (callIndexTestFile) Callers(name string) ([]disasm.CallSite, error) {
+	var sites []disasm.CallSite
+	for i := range 5 {
+		sites = append(sites, disasm.CallSite{Caller: fmt.Sprintf("main.f%d", i), Callee: name, PC: uint64(0x10 * i), Kind: disasm.TailCall})
+	}
+	return sites, nil
+}
+
+func (callIndexTestFile) Callees(name string) ([]disasm.CallSite, error) { return nil, nil }
+
+func TestMCPFindCallersPaginates(t *testing.T) {
+	server := &mcpServer{session: &Session{Path: "main", File: callIndexTestFile{}}}
+	result, rpcErr := server.handleToolCall(json.RawMessage(`{"name":"find_callers","arguments":{"name":"main.add","limit":2,"offset":3}}`))
+	if rpcErr != nil {
+		t.Fatal(rpcErr.Message)
+	}
+	toolResult := result.(mcpToolResult)
+	if toolResult.IsError {
+		t.Fatalf("tool error: %#v", toolResult.Content)
+	}
+	var got struct {
+		Calls []CallSiteDTO `json:"calls"`
+		Total int           `json:"total"`
+	}
+	if err := json.Unmarshal([]byte(toolResult.Content[0].Text), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Total != 5 || len(got.Calls) != 2 || got.Calls[0].Caller != "main.f3" || got.Calls[1].Caller != "main.f4" {
+		t.Fatalf("page = %+v", got)
+	}
+	if call := got.Calls[0]; call.Kind != "tail" || call.PCHex != "0x30" {
+		t.Errorf("call = %+v", call)
+	}
+}
+
+// profileTestFile has two adjacent functions with a known address.
+type profileTestFile struct{}
+
+func (profileTestFile) Funcs() []disasm.Func {
+	return []disasm.Func{profileTestFunc{"main.a", 0x1000}, profileTestFunc{"main.b", 0x1010}}
+}
+func (profileTestFile) Close() error { return nil }
+
+type profileTestFunc struct {
+	name string
+	addr uint64
+}
+
+func (fn profileTestFunc) Name() string                              { return fn.name }
+func (fn profileTestFunc) Addr() uint64                              { return fn.addr }
+func (fn profileTestFunc) Size() uint64                              { return 0x10 }
+func (fn profileTestFunc) Load(disasm.Options) (*disasm.Code, error) { return nil, nil }
+
+func TestMCPListFunctionsSortsByProfile(t *testing.T) {
+	server := &mcpServer{session: &Session{Path: "main", File: profileTestFile{}}}
+	list := func(sort string) ([]string, bool) {
+		t.Helper()
+		result, rpcErr := server.handleToolCall(json.RawMessage(`{"name":"list_functions","arguments":{"sort":"` + sort + `"}}`))
+		if rpcErr != nil {
+			t.Fatal(rpcErr.Message)
+		}
+		toolResult := result.(mcpToolResult)
+		if toolResult.IsError {
+			return nil, false
+		}
+		var got struct {
+			Functions []struct {
+				Name    string     `json:"name"`
+				Profile *WeightDTO `json:"profile"`
+			} `json:"functions"`
+		}
+		if err := json.Unmarshal([]byte(toolResult.Content[0].Text), &got); err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, fn := range got.Functions {
+			names = append(names, fn.Name)
+			if fn.Profile == nil || fn.Profile.FlatPercent+fn.Profile.CumPercent == 0 {
+				t.Errorf("%s profile = %+v", fn.Name, fn.Profile)
+			}
+		}
+		return names, true
+	}
+
+	if _, ok := list("flat"); ok {
+		t.Error("sorted by weight without a profile")
+	}
+	server.session.SetProfile(&profile.Profile{
+		SampleTypes: []profile.ValueType{{Type: "cpu", Unit: "nanoseconds"}},
+		Samples: []profile.Sample{
+			{Addrs: []uint64{0x1014, 0x1004}, Values: []int64{30}},
+			{Addrs: []uint64{0x1004}, Values: []int64{10}},
+		},
+	})
+	if names, _ := list("flat"); strings.Join(names, ",") != "main.b,main.a" {
+		t.Errorf("by flat = %v", names)
+	}
+	if names, _ := list("cum"); strings.Join(names, ",") != "main.a,main.b" {
+		t.Errorf("by cum = %v", names)
+	}
+}
@@ -1,16 +1,26 @@
 package mcp
 
 import (
+	"errors"
 	"fmt"
+	"sync"
 
 	"loov.dev/lensm/internal/comments"
 	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/profile"
 )
 
 type Session struct {
 	Path     string
 	File     disasm.File
 	Comments *comments.Store
+	// SourceMap resolves the source paths recorded in the binary.
+	SourceMap *disasm.SourceMap
+
+	// mu guards the profile, it can be replaced while requests run.
+	mu      sync.Mutex
+	profile *profile.Profile
+	overlay *profile.Overlay
 }
 
 // LoadFile opens a binary for disassembly. The caller injects an
@@ -40,6 +50,28 @@ func NewSessionWithComments(load LoadFile, path string, commentsPath string, sto
 	}, nil
 }
 
+// SetProfile sets the profile whose samples are attributed to the
+// functions, nil removes it.
+func (s *Session) SetProfile(p *profile.Profile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profile, s.overlay = p, nil
+}
+
+// Overlay returns the profile attributed to the functions, nil without a
+// profile.
+func (s *Session) Overlay() *profile.Overlay {
+	if s == nil || s.File == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.profile != nil && s.overlay == nil {
+		s.overlay = profile.NewOverlay(s.profile, s.File)
+	}
+	return s.overlay
+}
+
 func (s *Session) Close() error {
 	if s == nil || s.File == nil {
 		return nil
@@ -54,12 +86,21 @@ func (s *Session) Funcs() []disasm.Func {
 	return s.File.Funcs()
 }
 
+// FindFunc finds a function or a data symbol by name.
 func (s *Session) FindFunc(name string) disasm.Func {
 	for _, fn := range s.Funcs() {
 		if fn.Name() == name {
 			return fn
 		}
 	}
+	if s == nil || s.File == nil {
+		return nil
+	}
+	for _, data := range disasm.DataSymbols(s.File) {
+		if data.Name() == name {
+			return data
+		}
+	}
 	return nil
 }
 
@@ -68,5 +109,35 @@ func (s *Session) LoadCode(name string, context int) (*disasm.Code, error) {
 	if fn == nil {
 		return nil, fmt.Errorf("function %q not found", name)
 	}
-	return fn.Load(disasm.Options{Context: context})
+	return fn.Load(disasm.Options{Context: context, SourceMap: s.SourceMap})
+}
+
+// InlinedInto returns where the named function was inlined.
+func (s *Session) InlinedInto(name string) ([]disasm.InlineSite, error) {
+	index, ok := s.File.(disasm.InlineIndex)
+	if !ok {
+		return nil, errors.New("the executable format has no inlining information")
+	}
+	return index.InlinedInto(name)
+}
+
+// errNoCallIndex is returned for files that don't index the calls.
+var errNoCallIndex = errors.New("the executable format has no call index")
+
+// Callers returns the calls to the named function.
+func (s *Session) Callers(name string) ([]disasm.CallSite, error) {
+	index, ok := s.File.(disasm.CallIndex)
+	if !ok {
+		return nil, errNoCallIndex
+	}
+	return index.Callers(name)
+}
+
+// Callees returns the calls from the named function.
+func (s *Session) Callees(name string) ([]disasm.CallSite, error) {
+	index, ok := s.File.(disasm.CallIndex)
+	if !ok {
+		return nil, errNoCallIndex
+	}
+	return index.Callees(name)
 }
@@ -0,0 +1,250 @@
+package profile
+
+import (
+	"cmp"
+	"slices"
+	"sort"
+
+	"loov.dev/lensm/internal/disasm"
+)
+
+// Weight is the value of the samples at a place in the code. Flat counts
+// the samples that were executing there, Cum also the ones that were
+// calling from there.
+type Weight struct {
+	Flat, Cum int64
+}
+
+// Overlay is a profile attributed to the functions of an executable.
+type Overlay struct {
+	// Type is the kind of the weights, e.g. "cpu/nanoseconds".
+	Type ValueType
+	// Total is the weight of all samples, including the ones outside of
+	// the executable, e.g. in the vDSO.
+	Total int64
+	// Matched is the weight of the samples in the functions of the
+	// executable, it's small when the profile is from another build.
+	Matched int64
+	// Funcs are the weights of the functions by name.
+	Funcs map[string]Weight
+
+	funcs  []funcRange
+	byName map[string]funcRange
+	stacks []stack
+}
+
+// funcRange is the code of a function.
+type funcRange struct {
+	name       string
+	start, end uint64
+}
+
+// stack is a sample with the addresses relative to the executable.
+type stack struct {
+	addrs []uint64
+	value int64
+}
+
+// NewOverlay attributes the samples of the profile to the functions of
+// file that implement Addresser and Sizer. The executable may have been
+// loaded at another address, e.g. a position independent one, so the
+// addresses are moved by the mapping that matches most of the samples.
+func NewOverlay(profile *Profile, file disasm.File) *Overlay {
+	overlay := &Overlay{
+		Funcs:  map[string]Weight{},
+		byName: map[string]funcRange{},
+	}
+	for _, fn := range file.Funcs() {
+		addr, ok1 := fn.(disasm.Addresser)
+		size, ok2 := fn.(disasm.Sizer)
+		if !ok1 || !ok2 || size.Size() == 0 {
+			continue
+		}
+		r := funcRange{name: fn.Name(), start: addr.Addr(), end: addr.Addr() + size.Size()}
+		overlay.funcs = append(overlay.funcs, r)
+		overlay.byName[r.name] = r
+	}
+	slices.SortFunc(overlay.funcs, func(a, b funcRange) int { return cmp.Compare(a.start, b.start) })
+
+	index := profile.TypeIndex()
+	if index < 0 {
+		return overlay
+	}
+	overlay.Type = profile.SampleTypes[index]
+
+	shift := overlay.shift(profile, index)
+	for _, sample := range profile.Samples {
+		value := sample.Values[index]
+		if value == 0 {
+			continue
+		}
+		overlay.Total += value
+		s := stack{value: value, addrs: make([]uint64, len(sample.Addrs))}
+		for i, addr := range sample.Addrs {
+			s.addrs[i] = addr - shift
+		}
+		overlay.stacks = append(overlay.stacks, s)
+	}
+
+	var names []string
+	for _, s := range overlay.stacks {
+		names = names[:0]
+		for i, addr := range s.addrs {
+			fn, ok := overlay.funcAt(addr)
+			if !ok {
+				continue
+			}
+			w := overlay.Funcs[fn.name]
+			if i == 0 {
+				w.Flat += s.value
+				overlay.Matched += s.value
+			}
+			// Recursive calls are counted once.
+			if !slices.Contains(names, fn.name) {
+				names = append(names, fn.name)
+				w.Cum += s.value
+			}
+			overlay.Funcs[fn.name] = w
+		}
+	}
+	return overlay
+}
+
+// shift returns the load address to subtract from the addresses of the
+// samples, zero when they already match the executable.
+func (overlay *Overlay) shift(profile *Profile, index int) uint64 {
+	candidates := []uint64{0}
+	for _, m := range profile.Mappings {
+		if m.Start > m.Offset {
+			candidates = append(candidates, m.Start-m.Offset)
+		}
+	}
+	best, bestMatched := uint64(0), int64(0)
+	for _, shift := range candidates {
+		var matched int64
+		for _, sample := range profile.Samples {
+			if len(sample.Addrs) == 0 {
+				continue
+			}
+			if _, ok := overlay.funcAt(sample.Addrs[0] - shift); ok {
+				matched += max(sample.Values[index], 1)
+			}
+		}
+		if matched > bestMatched {
+			best, bestMatched = shift, matched
+		}
+	}
+	return best
+}
+
+// funcAt returns the function that contains addr.
+func (overlay *Overlay) funcAt(addr uint64) (funcRange, bool) {
+	i := sort.Search(len(overlay.funcs), func(i int) bool { return overlay.funcs[i].end > addr })
+	if i < len(overlay.funcs) && overlay.funcs[i].start <= addr {
+		return overlay.funcs[i], true
+	}
+	return funcRange{}, false
+}
+
+// Percent returns the share of the weight in the total.
+func (overlay *Overlay) Percent(weight int64) float64 {
+	if overlay.Total == 0 {
+		return 0
+	}
+	return float64(weight) * 100 / float64(overlay.Total)
+}
+
+// SourceLine is a line of the source code.
+type SourceLine struct {
+	File string
+	Line int
+}
+
+// CodeWeights are the weights of the instructions and the source lines of
+// a function.
+type CodeWeights struct {
+	// Insts are the weights by the index in Code.Insts.
+	Insts []Weight
+	// Lines are the weights of the source lines, the calls of the inlined
+	// functions include the inlined code in Cum.
+	Lines map[SourceLine]Weight
+	// MaxInst and MaxLine are the largest flat weights of an instruction
+	// and of a line, for the heat.
+	MaxInst, MaxLine int64
+}
+
+// Code attributes the samples to the instructions of code, nil when the
+// function isn't in the executable or wasn't sampled.
+func (overlay *Overlay) Code(code *disasm.Code) *CodeWeights {
+	fn, ok := overlay.byName[code.Name]
+	if !ok || code.Data != nil || overlay.Funcs[code.Name] == (Weight{}) {
+		return nil
+	}
+
+	// The instructions sorted by the pc, without the empty rows before
+	// the jump targets.
+	var insts []int
+	for i, inst := range code.Insts {
+		if inst.Text != "" || inst.NativeText != "" {
+			insts = append(insts, i)
+		}
+	}
+	sort.SliceStable(insts, func(a, b int) bool { return code.Insts[insts[a]].PC < code.Insts[insts[b]].PC })
+	instAt := func(addr uint64) (int, bool) {
+		if addr < fn.start || addr >= fn.end {
+			return 0, false
+		}
+		k := sort.Search(len(insts), func(k int) bool { return code.Insts[insts[k]].PC > addr })
+		if k == 0 {
+			return 0, false
+		}
+		return insts[k-1], true
+	}
+
+	weights := &CodeWeights{
+		Insts: make([]Weight, len(code.Insts)),
+		Lines: map[SourceLine]Weight{},
+	}
+	var seenInsts []int
+	var seenLines []SourceLine
+	for _, s := range overlay.stacks {
+		seenInsts, seenLines = seenInsts[:0], seenLines[:0]
+		for depth, addr := range s.addrs {
+			i, ok := instAt(addr)
+			if !ok {
+				continue
+			}
+			if depth == 0 {
+				weights.Insts[i].Flat += s.value
+			}
+			if !slices.Contains(seenInsts, i) {
+				seenInsts = append(seenInsts, i)
+				weights.Insts[i].Cum += s.value
+			}
+
+			inst := &code.Insts[i]
+			lines := []SourceLine{{inst.File, inst.Line}}
+			for _, frame := range inst.Inlined {
+				lines = append(lines, SourceLine{frame.File, frame.Line})
+			}
+			for k, line := range lines {
+				w := weights.Lines[line]
+				if depth == 0 && k == 0 {
+					w.Flat += s.value
+				}
+				if !slices.Contains(seenLines, line) {
+					seenLines = append(seenLines, line)
+					w.Cum += s.value
+				}
+				weights.Lines[line] = w
+			}
+		}
+	}
+	for _, w := range weights.Insts {
+		weights.MaxInst = max(weights.MaxInst, w.Flat)
+	}
+	for _, w := range weights.Lines {
+		weights.MaxLine = max(weights.MaxLine, w.Flat)
+	}
+	return weights
+}
@@ -0,0 +1,271 @@
+// Package profile reads pprof profiles, such as the ones that
+// runtime/pprof and `go test -cpuprofile` write, and attributes the
+// samples to the functions and the instructions of the executable.
+package profile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Profile is the part of a pprof profile that's needed to attribute the
+// samples to the addresses.
+type Profile struct {
+	// SampleTypes are the kinds of the values in each sample, e.g.
+	// "samples/count" and "cpu/nanoseconds" for a CPU profile.
+	SampleTypes []ValueType
+	// DefaultType is the sample type to show, empty when the profile
+	// doesn't specify it.
+	DefaultType string
+	Samples     []Sample
+	Mappings    []Mapping
+
+	Duration time.Duration
+}
+
+// ValueType describes the values of the samples.
+type ValueType struct {
+	Type, Unit string
+}
+
+// Sample is a stack with its values, one per sample type.
+type Sample struct {
+	// Addrs are the addresses of the stack, the leaf first.
+	Addrs  []uint64
+	Values []int64
+}
+
+// Mapping is a region of the address space of the profiled process, e.g.
+// where the executable was loaded.
+type Mapping struct {
+	Start, Limit, Offset uint64
+	File                 string
+}
+
+// Load reads the profile at path.
+func Load(path string) (*Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return profile, nil
+}
+
+// Parse parses a profile in the protocol buffer format, it may be gzipped.
+func Parse(data []byte) (*Profile, error) {
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		data, err = io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The strings and the locations are referenced by their index and id,
+	// they may come after the fields that reference them.
+	var (
+		stringTable []string
+		sampleTypes [][2]uint64
+		samples     []rawSample
+		mappings    []rawMapping
+		locations   = map[uint64]uint64{}
+		defaultType uint64
+	)
+	profile := &Profile{}
+	d := decoder{data: data}
+	for {
+		ok, err := d.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		switch d.field {
+		case 1: // sample_type
+			t, err := parseValueType(d.bytes)
+			if err != nil {
+				return nil, err
+			}
+			sampleTypes = append(sampleTypes, t)
+		case 2: // sample
+			s, err := parseSample(d.bytes)
+			if err != nil {
+				return nil, err
+			}
+			samples = append(samples, s)
+		case 3: // mapping
+			m, err := parseMapping(d.bytes)
+			if err != nil {
+				return nil, err
+			}
+			mappings = append(mappings, m)
+		case 4: // location
+			id, addr, err := parseLocation(d.bytes)
+			if err != nil {
+				return nil, err
+			}
+			locations[id] = addr
+		case 6: // string_table
+			stringTable = append(stringTable, string(d.bytes))
+		case 10: // duration_nanos
+			profile.Duration = time.Duration(d.value)
+		case 14: // default_sample_type
+			defaultType = d.value
+		}
+	}
+
+	str := func(i uint64) (string, error) {
+		if i >= uint64(len(stringTable)) {
+			return "", fmt.Errorf("string index %d out of range", i)
+		}
+		return stringTable[i], nil
+	}
+	for _, t := range sampleTypes {
+		typ, err := str(t[0])
+		if err != nil {
+			return nil, err
+		}
+		unit, err := str(t[1])
+		if err != nil {
+			return nil, err
+		}
+		profile.SampleTypes = append(profile.SampleTypes, ValueType{Type: typ, Unit: unit})
+	}
+	if defaultType != 0 {
+		var err error
+		if profile.DefaultType, err = str(defaultType); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range mappings {
+		file, err := str(m.file)
+		if err != nil {
+			return nil, err
+		}
+		profile.Mappings = append(profile.Mappings, Mapping{Start: m.start, Limit: m.limit, Offset: m.offset, File: file})
+	}
+	for _, s := range samples {
+		if len(s.values) != len(sampleTypes) {
+			return nil, fmt.Errorf("sample has %d values, expected %d", len(s.values), len(sampleTypes))
+		}
+		sample := Sample{Values: s.values, Addrs: make([]uint64, 0, len(s.locations))}
+		for _, id := range s.locations {
+			addr, ok := locations[id]
+			if !ok {
+				return nil, fmt.Errorf("unknown location %d", id)
+			}
+			sample.Addrs = append(sample.Addrs, addr)
+		}
+		profile.Samples = append(profile.Samples, sample)
+	}
+	return profile, nil
+}
+
+// TypeIndex returns the index of the sample type to show: the default one,
+// the CPU time or else the last one.
+func (profile *Profile) TypeIndex() int {
+	index := len(profile.SampleTypes) - 1
+	for i, t := range profile.SampleTypes {
+		switch {
+		case profile.DefaultType != "" && t.Type == profile.DefaultType:
+			return i
+		case t.Type == "cpu":
+			index = i
+		}
+	}
+	return index
+}
+
+type rawSample struct {
+	locations []uint64
+	values    []int64
+}
+
+type rawMapping struct {
+	start, limit, offset, file uint64
+}
+
+func parseValueType(data []byte) (t [2]uint64, err error) {
+	d := decoder{data: data}
+	for {
+		var ok bool
+		if ok, err = d.next(); err != nil || !ok {
+			return t, err
+		}
+		switch d.field {
+		case 1:
+			t[0] = d.value
+		case 2:
+			t[1] = d.value
+		}
+	}
+}
+
+func parseSample(data []byte) (s rawSample, err error) {
+	d := decoder{data: data}
+	for {
+		var ok bool
+		if ok, err = d.next(); err != nil || !ok {
+			return s, err
+		}
+		switch d.field {
+		case 1:
+			s.locations, err = d.uint64s(s.locations)
+		case 2:
+			s.values, err = d.int64s(s.values)
+		}
+		if err != nil {
+			return s, err
+		}
+	}
+}
+
+func parseMapping(data []byte) (m rawMapping, err error) {
+	d := decoder{data: data}
+	for {
+		var ok bool
+		if ok, err = d.next(); err != nil || !ok {
+			return m, err
+		}
+		switch d.field {
+		case 2:
+			m.start = d.value
+		case 3:
+			m.limit = d.value
+		case 4:
+			m.offset = d.value
+		case 5:
+			m.file = d.value
+		}
+	}
+}
+
+// parseLocation returns the id and the address of a location, the lines
+// are not needed since the executable has them.
+func parseLocation(data []byte) (id, addr uint64, err error) {
+	d := decoder{data: data}
+	for {
+		var ok bool
+		if ok, err = d.next(); err != nil || !ok {
+			return id, addr, err
+		}
+		switch d.field {
+		case 1:
+			id = d.value
+		case 3:
+			addr = d.value
+		}
+	}
+}
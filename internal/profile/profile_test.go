@@ -0,0 +1,193 @@
+package profile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"loov.dev/lensm/internal/disasm"
+)
+
+// encoder writes the fields of a protocol buffer message.
+type encoder struct{ data []byte }
+
+func (e *encoder) varint(field int, v uint64) {
+	e.data = binary.AppendUvarint(e.data, uint64(field)<<3|wireVarint)
+	e.data = binary.AppendUvarint(e.data, v)
+}
+
+func (e *encoder) bytes(field int, b []byte) {
+	e.data = binary.AppendUvarint(e.data, uint64(field)<<3|wireBytes)
+	e.data = binary.AppendUvarint(e.data, uint64(len(b)))
+	e.data = append(e.data, b...)
+}
+
+func (e *encoder) packed(field int, values ...uint64) {
+	var packed []byte
+	for _, v := range values {
+		packed = binary.AppendUvarint(packed, v)
+	}
+	e.bytes(field, packed)
+}
+
+func (e *encoder) message(field int, fn func(m *encoder)) {
+	var m encoder
+	fn(&m)
+	e.bytes(field, m.data)
+}
+
+// The executable is loaded at base, as a position independent one.
+const base = 0x5500_0000_0000
+
+// testProfile encodes a CPU profile of main.hot called from main.caller.
+func testProfile() []byte {
+	var e encoder
+	for _, t := range [][2]uint64{{1, 2}, {3, 4}} {
+		e.message(1, func(m *encoder) {
+			m.varint(1, t[0])
+			m.varint(2, t[1])
+		})
+	}
+	sample := func(value uint64, locations ...uint64) {
+		e.message(2, func(m *encoder) {
+			m.packed(1, locations...)
+			m.packed(2, 1, value)
+		})
+	}
+	sample(30, 1, 2)
+	sample(10, 3, 2)
+	sample(10, 4, 2) // in the vDSO
+	sample(10, 1, 3, 2)
+	e.message(3, func(m *encoder) {
+		m.varint(1, 1)
+		m.varint(2, base)
+		m.varint(3, base+0x10000)
+		m.varint(5, 5)
+	})
+	for id, addr := range []uint64{base + 0x1010, base + 0x1120, base + 0x1018, 0x7fff_0000} {
+		e.message(4, func(m *encoder) {
+			m.varint(1, uint64(id+1))
+			m.varint(3, addr)
+		})
+	}
+	for _, s := range []string{"", "samples", "count", "cpu", "nanoseconds", "/bin/prog"} {
+		e.bytes(6, []byte(s))
+	}
+	e.varint(10, 1e9)
+	return e.data
+}
+
+type testFunc struct {
+	name       string
+	addr, size uint64
+}
+
+func (fn testFunc) Name() string                              { return fn.name }
+func (fn testFunc) Addr() uint64                              { return fn.addr }
+func (fn testFunc) Size() uint64                              { return fn.size }
+func (fn testFunc) Load(disasm.Options) (*disasm.Code, error) { return nil, nil }
+
+type testFile []disasm.Func
+
+func (file testFile) Close() error         { return nil }
+func (file testFile) Funcs() []disasm.Func { return file }
+
+func TestParse(t *testing.T) {
+	var gzipped bytes.Buffer
+	w := gzip.NewWriter(&gzipped)
+	_, _ = w.Write(testProfile())
+	_ = w.Close()
+
+	for _, data := range [][]byte{testProfile(), gzipped.Bytes()} {
+		p, err := Parse(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(p.SampleTypes) != 2 || p.SampleTypes[1] != (ValueType{"cpu", "nanoseconds"}) || p.TypeIndex() != 1 {
+			t.Errorf("sample types = %+v", p.SampleTypes)
+		}
+		if len(p.Samples) != 4 || p.Samples[3].Addrs[1] != base+0x1018 || p.Samples[0].Values[1] != 30 {
+			t.Errorf("samples = %+v", p.Samples)
+		}
+		if len(p.Mappings) != 1 || p.Mappings[0].Start != base || p.Mappings[0].File != "/bin/prog" {
+			t.Errorf("mappings = %+v", p.Mappings)
+		}
+		if p.Duration.Seconds() != 1 {
+			t.Errorf("duration = %v", p.Duration)
+		}
+	}
+
+	data := testProfile()
+	if _, err := Parse(data[:len(data)-3]); !errors.Is(err, errTruncated) {
+		t.Errorf("truncated: got %v", err)
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	p, err := Parse(testProfile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	overlay := NewOverlay(p, testFile{
+		testFunc{"main.hot", 0x1000, 0x40},
+		testFunc{"main.caller", 0x1100, 0x40},
+		testFunc{"main.idle", 0x1200, 0x40},
+	})
+	if overlay.Total != 60 || overlay.Matched != 50 {
+		t.Errorf("total = %d, matched = %d", overlay.Total, overlay.Matched)
+	}
+	if got := overlay.Funcs["main.hot"]; got != (Weight{Flat: 50, Cum: 50}) {
+		t.Errorf("main.hot = %+v", got)
+	}
+	if got := overlay.Funcs["main.caller"]; got != (Weight{Flat: 0, Cum: 60}) {
+		t.Errorf("main.caller = %+v", got)
+	}
+	if _, ok := overlay.Funcs["main.idle"]; ok {
+		t.Errorf("main.idle has samples")
+	}
+
+	code := &disasm.Code{
+		Name: "main.hot",
+		Insts: []disasm.Inst{
+			{PC: 0x1000, Text: "MOVQ", File: "a.go", Line: 10},
+			{}, // the row before a jump target
+			{PC: 0x1010, Text: "ADDQ", File: "a.go", Line: 12},
+			{PC: 0x1018, Text: "CALL", File: "b.go", Line: 20, Inlined: []disasm.InlineFrame{
+				{Func: "main.inlined", File: "a.go", Line: 14},
+			}},
+			{PC: 0x1020, Text: "RET", File: "a.go", Line: 16},
+		},
+	}
+	weights := overlay.Code(code)
+	if weights == nil {
+		t.Fatal("no weights")
+	}
+	wantInsts := []Weight{{}, {}, {Flat: 40, Cum: 40}, {Flat: 10, Cum: 20}, {}}
+	for i, want := range wantInsts {
+		if got := weights.Insts[i]; got != want {
+			t.Errorf("inst %d = %+v, want %+v", i, got, want)
+		}
+	}
+	wantLines := map[SourceLine]Weight{
+		{"a.go", 12}: {Flat: 40, Cum: 40},
+		{"b.go", 20}: {Flat: 10, Cum: 20},
+		{"a.go", 14}: {Flat: 0, Cum: 20},
+	}
+	if len(weights.Lines) != len(wantLines) {
+		t.Errorf("lines = %+v", weights.Lines)
+	}
+	for line, want := range wantLines {
+		if got := weights.Lines[line]; got != want {
+			t.Errorf("line %v = %+v, want %+v", line, got, want)
+		}
+	}
+	if weights.MaxInst != 40 || weights.MaxLine != 40 {
+		t.Errorf("max = %d, %d", weights.MaxInst, weights.MaxLine)
+	}
+
+	if overlay.Code(&disasm.Code{Name: "main.idle"}) != nil {
+		t.Errorf("main.idle has weights")
+	}
+}
@@ -0,0 +1,109 @@
+package profile
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// The wire types of the protocol buffer encoding.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("truncated protobuf")
+
+// decoder reads the fields of a protocol buffer message.
+type decoder struct {
+	data []byte
+
+	// field and wire are the tag of the last field, value is the value of
+	// a varint or a fixed field and bytes the content of a bytes field.
+	field int
+	wire  int
+	value uint64
+	bytes []byte
+}
+
+// next reads the next field, it returns false at the end of the message.
+func (d *decoder) next() (bool, error) {
+	if len(d.data) == 0 {
+		return false, nil
+	}
+	tag, err := d.varint()
+	if err != nil {
+		return false, err
+	}
+	d.field, d.wire = int(tag>>3), int(tag&7)
+	switch d.wire {
+	case wireVarint:
+		d.value, err = d.varint()
+	case wireFixed64:
+		if len(d.data) < 8 {
+			return false, errTruncated
+		}
+		d.value, d.data = binary.LittleEndian.Uint64(d.data), d.data[8:]
+	case wireFixed32:
+		if len(d.data) < 4 {
+			return false, errTruncated
+		}
+		d.value, d.data = uint64(binary.LittleEndian.Uint32(d.data)), d.data[4:]
+	case wireBytes:
+		var n uint64
+		n, err = d.varint()
+		if err == nil && n > uint64(len(d.data)) {
+			err = errTruncated
+		}
+		if err == nil {
+			d.bytes, d.data = d.data[:n], d.data[n:]
+		}
+	default:
+		err = fmt.Errorf("unsupported protobuf wire type %d", d.wire)
+	}
+	return err == nil, err
+}
+
+func (d *decoder) varint() (uint64, error) {
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	d.data = d.data[n:]
+	return v, nil
+}
+
+// uint64s appends the values of a repeated varint field, which is either a
+// single value or packed in a bytes field.
+func (d *decoder) uint64s(values []uint64) ([]uint64, error) {
+	if d.wire != wireBytes {
+		return append(values, d.value), nil
+	}
+	packed := decoder{data: d.bytes}
+	for len(packed.data) > 0 {
+		v, err := packed.varint()
+		if err != nil {
+			return values, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// int64s is uint64s for the signed values.
+func (d *decoder) int64s(values []int64) ([]int64, error) {
+	if d.wire != wireBytes {
+		return append(values, int64(d.value)), nil
+	}
+	packed := decoder{data: d.bytes}
+	for len(packed.data) > 0 {
+		v, err := packed.varint()
+		if err != nil {
+			return values, err
+		}
+		values = append(values, int64(v))
+	}
+	return values, nil
+}
@@ -0,0 +1,361 @@
+package wasmobj
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/tetratelabs/wabin/leb128"
+	"github.com/tetratelabs/wabin/wasm"
+)
+
+// Opcodes that wabin doesn't name, or names differently from the text format.
+const (
+	opcodeReturnCall         wasm.Opcode = 0x12
+	opcodeReturnCallIndirect wasm.Opcode = 0x13
+)
+
+// instruction is a single decoded WebAssembly instruction.
+type instruction struct {
+	// offset is the position of the opcode from the start of the body.
+	offset int
+	// size is the encoded length including immediates.
+	size int
+	// opcode is the first opcode byte; prefixed opcodes keep the prefix
+	// here and the sub-opcode in sub.
+	opcode wasm.Opcode
+	sub    uint32
+	// name is the text format mnemonic, e.g. "i32.load".
+	name string
+	// args is the text format rendering of the immediates.
+	args string
+
+	// labels are the relative branch depths for br, br_if and br_table;
+	// the br_table default label is last.
+	labels []uint32
+	// callee is the function index for call and return_call.
+	callee    wasm.Index
+	hasCallee bool
+	// immediate is the index of local and global accesses and the value
+	// of integer constants.
+	immediate int64
+}
+
+// invalid reports whether the instruction could not be decoded.
+func (inst *instruction) invalid() bool { return inst.name == "" }
+
+// decodeBody decodes a function body expression into instructions.
+//
+// Bytes that do not form a known instruction are returned as a single-byte
+// instruction with an empty name, and decoding resumes at the next byte.
+func decodeBody(body []byte, names func(wasm.Index) string) []instruction {
+	var insts []instruction
+	for offset := 0; offset < len(body); {
+		inst, err := decodeInstruction(body[offset:], names)
+		if err != nil {
+			inst = instruction{opcode: body[offset], size: 1}
+		}
+		inst.offset = offset
+		insts = append(insts, inst)
+		offset += inst.size
+	}
+	return insts
+}
+
+var errUnknownOpcode = errors.New("unknown opcode")
+
+func decodeInstruction(code []byte, names func(wasm.Index) string) (instruction, error) {
+	r := bytes.NewReader(code)
+	op, _ := r.ReadByte()
+	inst := instruction{opcode: op, name: wasm.InstructionName(op)}
+
+	var args []string
+	arg := func(format string, values ...any) {
+		args = append(args, fmt.Sprintf(format, values...))
+	}
+
+	var err error
+	switch op {
+	case wasm.OpcodeBlock, wasm.OpcodeLoop, wasm.OpcodeIf:
+		var blockType string
+		blockType, err = readBlockType(r)
+		if blockType != "" {
+			arg("%s", blockType)
+		}
+	case wasm.OpcodeBr, wasm.OpcodeBrIf:
+		var label uint32
+		label, err = readU32(r)
+		inst.labels = []uint32{label}
+		arg("%d", label)
+	case wasm.OpcodeBrTable:
+		var count uint32
+		count, err = readU32(r)
+		for i := uint32(0); i <= count && err == nil; i++ {
+			var label uint32
+			label, err = readU32(r)
+			inst.labels = append(inst.labels, label)
+			arg("%d", label)
+		}
+	case wasm.OpcodeCall, opcodeReturnCall:
+		if op == opcodeReturnCall {
+			inst.name = "return_call"
+		}
+		inst.callee, err = readU32(r)
+		inst.hasCallee = true
+		arg("%s", names(inst.callee))
+	case wasm.OpcodeCallIndirect, opcodeReturnCallIndirect:
+		if op == opcodeReturnCallIndirect {
+			inst.name = "return_call_indirect"
+		}
+		var typeIndex, tableIndex uint32
+		typeIndex, err = readU32(r)
+		if err == nil {
+			tableIndex, err = readU32(r)
+		}
+		if tableIndex != 0 {
+			arg("%d", tableIndex)
+		}
+		arg("(type %d)", typeIndex)
+	case wasm.OpcodeTypedSelect:
+		inst.name = "select"
+		var count uint32
+		count, err = readU32(r)
+		var types []string
+		for i := uint32(0); i < count && err == nil; i++ {
+			var vt byte
+			vt, err = r.ReadByte()
+			types = append(types, wasm.ValueTypeName(vt))
+		}
+		arg("(result %s)", strings.Join(types, " "))
+	case wasm.OpcodeLocalGet, wasm.OpcodeLocalSet, wasm.OpcodeLocalTee,
+		wasm.OpcodeGlobalGet, wasm.OpcodeGlobalSet,
+		wasm.OpcodeTableGet, wasm.OpcodeTableSet:
+		var index uint32
+		index, err = readU32(r)
+		inst.immediate = int64(index)
+		arg("%d", index)
+	case wasm.OpcodeMemorySize, wasm.OpcodeMemoryGrow:
+		var memory byte
+		memory, err = r.ReadByte()
+		if memory != 0 {
+			arg("%d", memory)
+		}
+	case wasm.OpcodeI32Const:
+		var v int32
+		v, _, err = leb128.DecodeInt32(r)
+		inst.immediate = int64(v)
+		arg("%d", v)
+	case wasm.OpcodeI64Const:
+		var v int64
+		v, _, err = leb128.DecodeInt64(r)
+		inst.immediate = v
+		arg("%d", v)
+	case wasm.OpcodeF32Const:
+		var buf [4]byte
+		_, err = readFull(r, buf[:])
+		arg("%s", formatFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[:]))), 32))
+	case wasm.OpcodeF64Const:
+		var buf [8]byte
+		_, err = readFull(r, buf[:])
+		arg("%s", formatFloat(math.Float64frombits(binary.LittleEndian.Uint64(buf[:])), 64))
+	case wasm.OpcodeRefNull:
+		var refType byte
+		refType, err = r.ReadByte()
+		arg("%s", refTypeName(refType))
+	case wasm.OpcodeRefFunc:
+		inst.callee, err = readU32(r)
+		arg("%s", names(inst.callee))
+	case wasm.OpcodeMiscPrefix:
+		inst.sub, err = readU32(r)
+		if err == nil {
+			err = decodeMisc(r, &inst, arg)
+		}
+	case wasm.OpcodeVecPrefix:
+		inst.sub, err = readU32(r)
+		if err == nil {
+			err = decodeVector(r, &inst, arg)
+		}
+	default:
+		switch {
+		case op >= wasm.OpcodeI32Load && op <= wasm.OpcodeI64Store32:
+			err = readMemArg(r, arg)
+		case inst.name == "":
+			err = errUnknownOpcode
+		}
+	}
+	if err != nil {
+		return instruction{}, err
+	}
+	if inst.name == "" {
+		return instruction{}, errUnknownOpcode
+	}
+
+	inst.size = len(code) - r.Len()
+	inst.args = strings.Join(args, " ")
+	return inst, nil
+}
+
+func decodeMisc(r *bytes.Reader, inst *instruction, arg func(string, ...any)) error {
+	if inst.sub > 0xff {
+		return errUnknownOpcode
+	}
+	inst.name = wasm.MiscInstructionName(byte(inst.sub))
+
+	// Reserved memory indices are always zero in the MVP encoding,
+	// but are still part of the instruction.
+	switch byte(inst.sub) {
+	case wasm.OpcodeMiscMemoryInit:
+		data, err := readU32(r)
+		if err != nil {
+			return err
+		}
+		arg("%d", data)
+		_, err = r.ReadByte()
+		return err
+	case wasm.OpcodeMiscDataDrop, wasm.OpcodeMiscElemDrop,
+		wasm.OpcodeMiscTableGrow, wasm.OpcodeMiscTableSize, wasm.OpcodeMiscTableFill:
+		index, err := readU32(r)
+		arg("%d", index)
+		return err
+	case wasm.OpcodeMiscMemoryCopy:
+		if _, err := r.ReadByte(); err != nil {
+			return err
+		}
+		_, err := r.ReadByte()
+		return err
+	case wasm.OpcodeMiscMemoryFill:
+		_, err := r.ReadByte()
+		return err
+	case wasm.OpcodeMiscTableInit, wasm.OpcodeMiscTableCopy:
+		first, err := readU32(r)
+		if err != nil {
+			return err
+		}
+		second, err := readU32(r)
+		arg("%d %d", first, second)
+		return err
+	}
+	return nil
+}
+
+func decodeVector(r *bytes.Reader, inst *instruction, arg func(string, ...any)) error {
+	if inst.sub > 0xff {
+		return errUnknownOpcode
+	}
+	op := byte(inst.sub)
+	inst.name = wasm.VectorInstructionName(op)
+
+	switch {
+	case op <= wasm.OpcodeVecV128Store,
+		op == wasm.OpcodeVecV128Load32zero, op == wasm.OpcodeVecV128Load64zero:
+		return readMemArg(r, arg)
+	case op >= wasm.OpcodeVecV128Load8Lane && op <= wasm.OpcodeVecV128Store64Lane:
+		if err := readMemArg(r, arg); err != nil {
+			return err
+		}
+		lane, err := r.ReadByte()
+		arg("%d", lane)
+		return err
+	case op == wasm.OpcodeVecV128Const:
+		var buf [16]byte
+		if _, err := readFull(r, buf[:]); err != nil {
+			return err
+		}
+		arg("i32x4 0x%08x 0x%08x 0x%08x 0x%08x",
+			binary.LittleEndian.Uint32(buf[0:]), binary.LittleEndian.Uint32(buf[4:]),
+			binary.LittleEndian.Uint32(buf[8:]), binary.LittleEndian.Uint32(buf[12:]))
+	case op == wasm.OpcodeVecV128i8x16Shuffle:
+		inst.name = "i8x16.shuffle"
+		var lanes [16]byte
+		if _, err := readFull(r, lanes[:]); err != nil {
+			return err
+		}
+		for _, lane := range lanes {
+			arg("%d", lane)
+		}
+	case op >= wasm.OpcodeVecI8x16ExtractLaneS && op <= wasm.OpcodeVecF64x2ReplaceLane:
+		lane, err := r.ReadByte()
+		arg("%d", lane)
+		return err
+	}
+	return nil
+}
+
+func readBlockType(r *bytes.Reader) (string, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	switch b {
+	case 0x40:
+		return "", nil
+	case wasm.ValueTypeI32, wasm.ValueTypeI64, wasm.ValueTypeF32, wasm.ValueTypeF64,
+		wasm.ValueTypeV128, wasm.ValueTypeFuncref, wasm.ValueTypeExternref:
+		return "(result " + wasm.ValueTypeName(b) + ")", nil
+	}
+	if err := r.UnreadByte(); err != nil {
+		return "", err
+	}
+	index, _, err := leb128.DecodeInt33AsInt64(r)
+	if err != nil {
+		return "", err
+	}
+	return "(type " + strconv.FormatInt(index, 10) + ")", nil
+}
+
+func readMemArg(r *bytes.Reader, arg func(string, ...any)) error {
+	align, err := readU32(r)
+	if err != nil {
+		return err
+	}
+	offset, _, err := leb128.DecodeUint64(r)
+	if err != nil {
+		return err
+	}
+	if offset != 0 {
+		arg("offset=%d", offset)
+	}
+	if align < 64 {
+		arg("align=%d", uint64(1)<<align)
+	}
+	return nil
+}
+
+func readU32(r *bytes.Reader) (uint32, error) {
+	v, _, err := leb128.DecodeUint32(r)
+	return v, err
+}
+
+func readFull(r *bytes.Reader, buf []byte) (int, error) {
+	n, err := r.Read(buf)
+	if err == nil && n < len(buf) {
+		err = errors.New("unexpected end of code")
+	}
+	return n, err
+}
+
+func refTypeName(b byte) string {
+	switch b {
+	case wasm.ValueTypeFuncref:
+		return "func"
+	case wasm.ValueTypeExternref:
+		return "extern"
+	}
+	return fmt.Sprintf("0x%02x", b)
+}
+
+func formatFloat(v float64, bits int) string {
+	switch {
+	case math.IsNaN(v):
+		return "nan"
+	case math.IsInf(v, 1):
+		return "inf"
+	case math.IsInf(v, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, bits)
+}
@@ -0,0 +1,38 @@
+package wasmobj
+
+import (
+	"debug/dwarf"
+	"strings"
+
+	"github.com/tetratelabs/wabin/wasm"
+)
+
+// loadDWARF reads the debug information from the custom sections,
+// returns nil when the module has no debug information.
+func loadDWARF(module *wasm.Module) (*dwarf.Data, error) {
+	sections := map[string][]byte{}
+	for _, section := range module.CustomSections {
+		if name, ok := strings.CutPrefix(section.Name, ".debug_"); ok {
+			sections[name] = section.Data
+		}
+	}
+	if sections["info"] == nil {
+		return nil, nil
+	}
+
+	data, err := dwarf.New(
+		sections["abbrev"], sections["aranges"], sections["frame"],
+		sections["info"], sections["line"], sections["pubnames"],
+		sections["ranges"], sections["str"])
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"addr", "line_str", "str_offsets", "rnglists"} {
+		if section, ok := sections[name]; ok {
+			if err := data.AddSection(".debug_"+name, section); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return data, nil
+}
@@ -1,23 +1,37 @@
 package wasmobj
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"sort"
 	"strings"
 
 	"github.com/tetratelabs/wabin/binary"
+	"github.com/tetratelabs/wabin/leb128"
 	"github.com/tetratelabs/wabin/wasm"
 
 	"loov.dev/lensm/internal/disasm"
+	"loov.dev/lensm/internal/dwarfline"
 )
 
 var _ disasm.File = (*File)(nil)
 var _ disasm.Func = (*Func)(nil)
+var _ disasm.Sizer = (*Func)(nil)
 
 // File contains information about the object file.
 type File struct {
 	module *wasm.Module
+	// importedFuncs is the number of imported functions, which precede
+	// the defined functions in the function index space.
+	importedFuncs wasm.Index
+	// names maps function indices to the name section entries.
+	names map[wasm.Index]string
+	// lines maps code section offsets to source, nil without DWARF.
+	lines *dwarfline.Table
+	// stamp is used to detect stale sources.
+	stamp *disasm.BuildStamp
 
 	funcs []disasm.Func
 }
@@ -26,21 +40,26 @@ func (file *File) Funcs() []disasm.Func { return file.funcs }
 
 // Func contains information about the executable.
 type Func struct {
-	obj      *File
-	index    wasm.Index
-	name     string
-	code     *wasm.Code
+	obj   *File
+	index wasm.Index
+	name  string
+	code  *wasm.Code
+	// offset is where the body starts relative to the code section.
+	offset uint64
+	// goFunc is the pclntab entry of Go functions, nil otherwise.
+	goFunc   *goFunc
 	sortName string
 }
 
 func (fn *Func) Name() string { return fn.name }
+func (fn *Func) Size() uint64 { return uint64(len(fn.code.Body)) }
 
 func (file *File) Close() error {
 	return nil
 }
 
 func Load(path string) (*File, error) {
-	obj := &File{}
+	obj := &File{stamp: disasm.ReadBuildStamp(path)}
 
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -53,14 +72,62 @@ func Load(path string) (*File, error) {
 	}
 	obj.module = module
 
-	for i, fnname := range module.NameSection.FunctionNames {
-		code := module.CodeSection[i]
+	offsets, err := codeBodyOffsets(data, module)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, imp := range module.ImportSection {
+		if imp.Type == wasm.ExternTypeFunc {
+			obj.importedFuncs++
+		}
+	}
+	obj.names = make(map[wasm.Index]string)
+	if module.NameSection != nil {
+		for _, fnname := range module.NameSection.FunctionNames {
+			obj.names[fnname.Index] = fnname.Name
+		}
+	}
+
+	// Go doesn't emit DWARF for WebAssembly, the functions and lines are
+	// described by the pclntab, which also names them in stripped modules.
+	table, err := loadPclntab(module)
+	if err != nil {
+		return nil, err
+	}
+	var goFuncs map[int]*goFunc
+	if table != nil {
+		goFuncs = table.funcs()
+		for code, fn := range goFuncs {
+			index := obj.importedFuncs + wasm.Index(code)
+			if _, ok := obj.names[index]; !ok && fn.name != "" {
+				obj.names[index] = fn.name
+			}
+		}
+	}
+
+	debug, err := loadDWARF(module)
+	if err != nil {
+		return nil, err
+	}
+	if debug != nil {
+		obj.lines, err = dwarfline.Load(debug)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i, code := range module.CodeSection {
+		index := obj.importedFuncs + wasm.Index(i)
+		name := obj.funcName(index)
 		sym := &Func{
 			obj:      obj,
-			index:    fnname.Index,
-			name:     fnname.Name,
+			index:    index,
+			name:     name,
 			code:     code,
-			sortName: strings.ToLower(fnname.Name),
+			offset:   offsets[i],
+			goFunc:   goFuncs[i],
+			sortName: strings.ToLower(name),
 		}
 		obj.funcs = append(obj.funcs, sym)
 	}
@@ -72,6 +139,61 @@ func Load(path string) (*File, error) {
 	return obj, nil
 }
 
+// funcName returns the name section entry for the function index, or a
+// placeholder for functions without a name.
+func (file *File) funcName(index wasm.Index) string {
+	if name, ok := file.names[index]; ok {
+		return name
+	}
+	return fmt.Sprintf("func[%d]", index)
+}
+
+// codeBodyOffsets returns the offset of each function body expression
+// relative to the start of the code section contents, which is the address
+// space DWARF uses for WebAssembly.
+func codeBodyOffsets(data []byte, module *wasm.Module) ([]uint64, error) {
+	r := bytes.NewReader(data[8:])
+	for r.Len() > 0 {
+		id, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		size, _, err := leb128.DecodeUint32(r)
+		if err != nil {
+			return nil, err
+		}
+		if id != wasm.SectionIDCode {
+			if _, err := r.Seek(int64(size), io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		sectionStart := r.Size() - int64(r.Len())
+		count, _, err := leb128.DecodeUint32(r)
+		if err != nil {
+			return nil, err
+		}
+		if int(count) != len(module.CodeSection) {
+			return nil, fmt.Errorf("code section has %d entries, decoded %d", count, len(module.CodeSection))
+		}
+		offsets := make([]uint64, count)
+		for i := range offsets {
+			entrySize, _, err := leb128.DecodeUint32(r)
+			if err != nil {
+				return nil, err
+			}
+			entryEnd := r.Size() - int64(r.Len()) + int64(entrySize)
+			offsets[i] = uint64(entryEnd - int64(len(module.CodeSection[i].Body)) - sectionStart)
+			if _, err := r.Seek(entryEnd, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+		return offsets, nil
+	}
+	return nil, nil
+}
+
 func (fn *Func) Load(opts disasm.Options) (*disasm.Code, error) {
 	return fn.obj.LoadCode(fn, opts), nil
 }
@@ -79,62 +201,169 @@ func (fn *Func) Load(opts disasm.Options) (*disasm.Code, error) {
 func (file *File) LoadCode(fn *Func, opts disasm.Options) *disasm.Code {
 	code := &disasm.Code{
 		Name: fn.name,
+		Arch: "wasm",
 	}
 
-	// TODO: https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#binary-instr
-
-	for i, b := range fn.code.Body {
-		code.Insts = append(code.Insts, disasm.Inst{
-			PC:   uint64(i),
-			Text: fmt.Sprintf("BYTE 0x%02x", b),
-		})
+	decoded := decodeBody(fn.code.Body, file.funcName)
+	targets, tables := branchTargets(decoded)
+	var positions []goLine
+	if fn.goFunc != nil && file.lines == nil {
+		positions = fn.goFunc.positions(decoded)
 	}
-	return code
-}
 
-/*
-func (file *File) LoadCode(fn *Func, opts disasm.Options) *disasm.Code {
-	dis, err := wasmdisasm.NewDisassembly(*fn.fn, file.module)
-	if err != nil {
-		return &disasm.Code{Name: err.Error()}
-	}
+	neededLines := make(map[string]*disasm.LineSet)
+	insts := make([]disasm.Inst, 0, len(decoded))
+	depth := 0
+	for i, ix := range decoded {
+		switch ix.opcode {
+		case wasm.OpcodeElse, wasm.OpcodeEnd:
+			depth = max(depth-1, 0)
+		}
 
-	code := &disasm.Code{
-		Name: fn.fn.Name,
-	}
+		inst := disasm.Inst{
+			PC:       fn.offset + uint64(ix.offset),
+			Mnemonic: ix.name,
+		}
+		switch {
+		case ix.invalid():
+			inst.Text = fmt.Sprintf("BYTE 0x%02x", ix.opcode)
+		case ix.args != "":
+			inst.Text = strings.Repeat("  ", depth) + ix.name + " " + ix.args
+		default:
+			inst.Text = strings.Repeat("  ", depth) + ix.name
+		}
+		srcFile, line, ok := file.lines.Find(inst.PC)
+		if positions != nil && positions[i].line > 0 {
+			srcFile, line, ok = positions[i].file, positions[i].line, true
+		}
+		if ok {
+			inst.File, inst.Line = srcFile, line
+			if code.File == "" {
+				code.File = srcFile
+			}
 
-	for i, ix := range dis.Code {
-		code.Insts = append(code.Insts, file.toInstr(dis, i, ix))
+			lineset, ok := neededLines[srcFile]
+			if !ok {
+				lineset = &disasm.LineSet{}
+				neededLines[srcFile] = lineset
+			}
+			lineset.Add(line)
+		}
+		if ix.hasCallee {
+			inst.Call = file.funcName(ix.callee)
+		}
+		if target := targets[i]; target >= 0 {
+			inst.RefPC = fn.offset + uint64(decoded[target].offset)
+		}
+		if table := tables[i]; len(table) > 1 {
+			pcs := make([]uint64, len(table))
+			for k, target := range table {
+				pcs[k] = fn.offset + uint64(decoded[target].offset)
+			}
+			inst.SetTargets(pcs)
+		}
+		insts = append(insts, inst)
+
+		switch ix.opcode {
+		case wasm.OpcodeBlock, wasm.OpcodeLoop, wasm.OpcodeIf, wasm.OpcodeElse:
+			depth++
+		}
 	}
 
+	disasm.LayoutJumps(code, insts)
+
+	code.Source = disasm.LoadSources(neededLines, code.File, file.stamp, opts)
+	disasm.RelateSources(code)
 	return code
 }
-func (file *File) toInstr(dis *wasmdisasm.Disassembly, i int, ix wasmdisasm.Instr) disasm.Inst {
-	inst := disasm.Inst{
-		PC:   uint64(i),
-		Text: ix.Op.Name + " " + file.immediatesToString(ix.Immediates),
-	}
-
-	switch ix.Op.Code {
-	case operators.Call:
-		target := ix.Immediates[0].(uint32)
-		fn := file.module.FunctionIndexSpace[target]
-		inst.Text = ix.Op.Name + " " + fn.Name
-		inst.Call = fn.Name
 
-	// TODO: figure out ix.Branches and ix.Block.IfElseIndex (similar)
-	default:
+// branchTargets returns for each instruction the index of the instruction
+// that control transfers to, or -1. Branches to a block or if land on its
+// end, branches to a loop on the loop itself; an if without a taken
+// condition continues at its else or end, and else skips to the end.
+// br_table has several targets, targets has the default one and tables
+// all of them.
+func branchTargets(insts []instruction) (targets []int, tables map[int][]int) {
+	type frame struct {
+		opcode   wasm.Opcode
+		start    int
+		elseAt   int
+		end      int
+		branches []int
+		// tables are the br_tables that have a label for the frame.
+		tables []int
+	}
 
+	targets = make([]int, len(insts))
+	tables = map[int][]int{}
+	for i := range targets {
+		targets[i] = -1
 	}
 
-	return inst
-}
+	// The function body is an implicit block whose end is the final
+	// instruction, so a branch to it behaves like a return.
+	var frames []*frame
+	stack := []*frame{{opcode: wasm.OpcodeBlock, start: -1, elseAt: -1, end: -1}}
+	frames = append(frames, stack[0])
+	for i, inst := range insts {
+		switch inst.opcode {
+		case wasm.OpcodeBlock, wasm.OpcodeLoop, wasm.OpcodeIf:
+			if inst.invalid() {
+				continue
+			}
+			f := &frame{opcode: inst.opcode, start: i, elseAt: -1, end: -1}
+			stack = append(stack, f)
+			frames = append(frames, f)
+		case wasm.OpcodeElse:
+			if len(stack) > 0 {
+				stack[len(stack)-1].elseAt = i
+			}
+		case wasm.OpcodeEnd:
+			if len(stack) > 0 {
+				stack[len(stack)-1].end = i
+				stack = stack[:len(stack)-1]
+			}
+		case wasm.OpcodeBr, wasm.OpcodeBrIf, wasm.OpcodeBrTable:
+			if len(inst.labels) == 0 {
+				continue
+			}
+			label := int(inst.labels[len(inst.labels)-1])
+			if label < len(stack) {
+				f := stack[len(stack)-1-label]
+				f.branches = append(f.branches, i)
+			}
+			if inst.opcode == wasm.OpcodeBrTable {
+				for _, label := range inst.labels {
+					if int(label) < len(stack) {
+						f := stack[len(stack)-1-int(label)]
+						f.tables = append(f.tables, i)
+					}
+				}
+			}
+		}
+	}
 
-func (file *File) immediatesToString(xs []interface{}) string {
-	var str strings.Builder
-	for _, im := range xs {
-		fmt.Fprintf(&str, " %v", im)
+	for _, f := range frames {
+		target := f.end
+		if f.opcode == wasm.OpcodeLoop {
+			target = f.start
+		}
+		if target >= 0 {
+			for _, i := range f.branches {
+				targets[i] = target
+			}
+			for _, i := range f.tables {
+				tables[i] = append(tables[i], target)
+			}
+		}
+		if f.opcode == wasm.OpcodeIf && f.end >= 0 {
+			if f.elseAt >= 0 {
+				targets[f.start] = f.elseAt
+				targets[f.elseAt] = f.end
+			} else {
+				targets[f.start] = f.end
+			}
+		}
 	}
-	return str.String()
+	return targets, tables
 }
-*/
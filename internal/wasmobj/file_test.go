@@ -2,34 +2,180 @@ package wasmobj
 
 import (
 	"path/filepath"
-	"regexp"
+	"slices"
+	"strings"
 	"testing"
 
 	"loov.dev/lensm/internal/disasm"
 )
 
-var rxByteInst = regexp.MustCompile(`^BYTE 0x[0-9a-f]{2}$`)
-
-func TestLoadFormatsBytes(t *testing.T) {
-	file, err := Load(filepath.Join("..", "..", "testdata", "c-wasm", "example.wasm"))
+func loadTestFunc(t *testing.T, module, name string) *disasm.Code {
+	t.Helper()
+	file, err := Load(filepath.Join("..", "..", "testdata", "c-wasm", module))
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	for _, fn := range file.funcs {
+	for _, fn := range file.Funcs() {
+		if fn.Name() != name {
+			continue
+		}
 		code, err := fn.Load(disasm.Options{})
 		if err != nil {
 			t.Fatal(err)
 		}
-		if len(code.Insts) == 0 {
-			continue
+		return code
+	}
+	t.Fatalf("function %q not found in %s", name, module)
+	return nil
+}
+
+func TestLoadDecodesInstructions(t *testing.T) {
+	for _, module := range []string{"example.wasm", "example-clang.wasm"} {
+		file, err := Load(filepath.Join("..", "..", "testdata", "c-wasm", module))
+		if err != nil {
+			t.Fatal(err)
 		}
-		for _, inst := range code.Insts {
-			if !rxByteInst.MatchString(inst.Text) {
-				t.Fatalf("instruction text = %q", inst.Text)
+		for _, fn := range file.Funcs() {
+			code, err := fn.Load(disasm.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, inst := range code.Insts {
+				if strings.HasPrefix(inst.Text, "BYTE ") {
+					t.Errorf("%s %s: undecoded instruction at %#x: %q", module, fn.Name(), inst.PC, inst.Text)
+				}
+			}
+			if n := len(code.Insts); n > 0 && strings.TrimSpace(code.Insts[n-1].Text) != "end" {
+				t.Errorf("%s %s: last instruction = %q, want end", module, fn.Name(), code.Insts[n-1].Text)
+			}
+		}
+	}
+}
+
+func TestLoadResolvesCalls(t *testing.T) {
+	code := loadTestFunc(t, "example-clang.wasm", "add")
+	for _, inst := range code.Insts {
+		if inst.Call == "internal_add" {
+			if inst.Text != "call internal_add" {
+				t.Fatalf("call text = %q", inst.Text)
 			}
+			return
+		}
+	}
+	t.Fatal("call to internal_add not found")
+}
+
+func TestLoadResolvesBranches(t *testing.T) {
+	code := loadTestFunc(t, "example-clang.wasm", "internal_add")
+
+	pcToIndex := map[uint64]int{}
+	for i, inst := range code.Insts {
+		if inst.Text != "" {
+			pcToIndex[inst.PC] = i
+		}
+	}
+
+	branches := 0
+	for i, inst := range code.Insts {
+		text := strings.TrimSpace(inst.Text)
+		if !strings.HasPrefix(text, "br") {
+			continue
+		}
+		branches++
+		target, ok := pcToIndex[inst.RefPC]
+		if !ok {
+			t.Fatalf("%q at %#x has no target", text, inst.PC)
+		}
+		if target != i+inst.RefOffset {
+			t.Fatalf("%q RefOffset = %d, want %d", text, inst.RefOffset, target-i)
+		}
+		if got := strings.TrimSpace(code.Insts[target].Text); got != "end" {
+			t.Fatalf("%q targets %q, want end of block", text, got)
+		}
+	}
+	if branches == 0 {
+		t.Fatal("no branches found")
+	}
+}
+
+func TestBranchTargetsBrTable(t *testing.T) {
+	// block block block i32.const 1 br_table 0 1 2 end end end end
+	body := []byte{0x02, 0x40, 0x02, 0x40, 0x02, 0x40, 0x41, 0x01, 0x0e, 0x02, 0x00, 0x01, 0x02, 0x0b, 0x0b, 0x0b, 0x0b}
+	insts := decodeBody(body, func(uint32) string { return "f" })
+	targets, tables := branchTargets(insts)
+
+	const brTable = 4
+	if got := targets[brTable]; got != 7 {
+		t.Errorf("default target = %d, want the end of the outer block at 7", got)
+	}
+	got := slices.Sorted(slices.Values(tables[brTable]))
+	if want := []int{5, 6, 7}; !slices.Equal(got, want) {
+		t.Errorf("table targets = %v, want %v", got, want)
+	}
+}
+
+func TestDecodeInstruction(t *testing.T) {
+	names := func(index uint32) string { return "f" }
+	cases := []struct {
+		code []byte
+		text string
+		size int
+	}{
+		{code: []byte{0x41, 0x7f}, text: "i32.const -1", size: 2},
+		{code: []byte{0x0e, 0x02, 0x00, 0x01, 0x02}, text: "br_table 0 1 2", size: 5},
+		{code: []byte{0x11, 0x03, 0x00}, text: "call_indirect (type 3)", size: 3},
+		{code: []byte{0x02, 0x7f}, text: "block (result i32)", size: 2},
+		{code: []byte{0x28, 0x02, 0x08}, text: "i32.load offset=8 align=4", size: 3},
+		{code: []byte{0xfc, 0x0a, 0x00, 0x00}, text: "memory.copy", size: 4},
+		{code: []byte{0xfd, 0x0f}, text: "i8x16.splat", size: 2},
+		{code: []byte{0xfd, 0x15, 0x03}, text: "i8x16.extract_lane_s 3", size: 3},
+	}
+	for _, tc := range cases {
+		inst, err := decodeInstruction(tc.code, names)
+		if err != nil {
+			t.Fatalf("% x: %v", tc.code, err)
+		}
+		text := inst.name
+		if inst.args != "" {
+			text += " " + inst.args
+		}
+		if text != tc.text || inst.size != tc.size {
+			t.Errorf("% x: got %q size %d, want %q size %d", tc.code, text, inst.size, tc.text, tc.size)
+		}
+	}
+}
+
+func TestLoadMapsSourceLines(t *testing.T) {
+	code := loadTestFunc(t, "example-clang.wasm", "internal_add")
+
+	// The module was compiled on Windows, so only compare the file name.
+	if !strings.HasSuffix(code.File, "example.c") {
+		t.Fatalf("code.File = %q, want example.c", code.File)
+	}
+
+	lines := map[int]bool{}
+	for _, inst := range code.Insts {
+		if inst.Text == "" {
+			continue
 		}
-		return
+		if inst.File != "" && inst.File != code.File {
+			t.Errorf("%q at %#x is in %q", inst.Text, inst.PC, inst.File)
+		}
+		lines[inst.Line] = true
+	}
+	for _, line := range []int{1, 2, 3, 5, 6} {
+		if !lines[line] {
+			t.Errorf("no instructions for line %d", line)
+		}
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	format, err := disasm.Detect(filepath.Join("..", "..", "testdata", "c-wasm", "example.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format.Name != "WebAssembly" {
+		t.Fatalf("format = %q, want WebAssembly", format.Name)
 	}
-	t.Fatal("no wasm function with body found")
 }
@@ -0,0 +1,17 @@
+package wasmobj
+
+import (
+	"bytes"
+
+	"loov.dev/lensm/internal/disasm"
+)
+
+func init() {
+	disasm.RegisterFormat(disasm.Format{
+		Name: "WebAssembly",
+		Probe: func(header []byte) bool {
+			return bytes.HasPrefix(header, []byte("\x00asm"))
+		},
+		Open: func(path string) (disasm.File, error) { return Load(path) },
+	})
+}
@@ -0,0 +1,375 @@
+package wasmobj
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/tetratelabs/wabin/leb128"
+	"github.com/tetratelabs/wabin/wasm"
+)
+
+// pclntab decodes the function table that the Go linker stores in the data
+// segments of GOOS=js and GOOS=wasip1 modules.
+//
+// Go doesn't use code addresses on WebAssembly, a PC is PC_F<<16 + PC_B,
+// where PC_F is funcValueOffset plus the index of the function in the code
+// section and PC_B the resume point inside the function. The functab
+// contains PC_F and the line tables are indexed by PC_B.
+type pclntab struct {
+	order binary.ByteOrder
+	// go118 is set for the Go 1.18 and 1.19 layouts.
+	go118 bool
+	minLC uint64
+
+	nfunc       int
+	funcnametab []byte
+	cutab       []byte
+	filetab     []byte
+	pctab       []byte
+	functab     []byte
+}
+
+// funcValueOffset is the PC_F of the first function in the code section,
+// see cmd/link/internal/wasm.
+const funcValueOffset = 0x1000
+
+// pclntab magics, the ptrSize is always 8 and minLC 1 on WebAssembly.
+var pclntabMagics = [][]byte{
+	{0xf1, 0xff, 0xff, 0xff, 0, 0, 1, 8}, // Go 1.20 and newer
+	{0xf0, 0xff, 0xff, 0xff, 0, 0, 1, 8}, // Go 1.18 and 1.19
+}
+
+// loadPclntab finds the pclntab in the data segments, it returns nil when
+// the module wasn't built by Go 1.18 or newer.
+func loadPclntab(module *wasm.Module) (*pclntab, error) {
+	mem, err := linearMemory(module)
+	if err != nil {
+		return nil, err
+	}
+	// The header is 8 byte aligned, skip matches in other data.
+	for _, magic := range pclntabMagics {
+		for at := 0; ; {
+			i := bytes.Index(mem[at:], magic)
+			if i < 0 {
+				break
+			}
+			at += i
+			if at%8 == 0 {
+				if table, err := parsePclntab(mem[at:], len(module.CodeSection)); err == nil {
+					return table, nil
+				}
+			}
+			at++
+		}
+	}
+	return nil, nil
+}
+
+// linearMemory returns the initial contents of the memory, as written by
+// the active data segments.
+func linearMemory(module *wasm.Module) ([]byte, error) {
+	var mem []byte
+	for _, segment := range module.DataSection {
+		expr := segment.OffsetExpression
+		if expr == nil || expr.Opcode != wasm.OpcodeI32Const {
+			continue
+		}
+		offset, _, err := leb128.DecodeInt32(bytes.NewReader(expr.Data))
+		if err != nil {
+			return nil, err
+		}
+		if offset < 0 {
+			continue
+		}
+		end := int(offset) + len(segment.Init)
+		if end > len(mem) {
+			mem = append(mem, make([]byte, end-len(mem))...)
+		}
+		copy(mem[offset:], segment.Init)
+	}
+	return mem, nil
+}
+
+// parsePclntab parses the pclntab header, ncode is used to reject
+// tables that don't describe the module.
+func parsePclntab(data []byte, ncode int) (*pclntab, error) {
+	table := &pclntab{
+		order: binary.LittleEndian,
+		go118: data[0] == 0xf0,
+		minLC: uint64(data[6]),
+	}
+	word := func(i int) uint64 {
+		off := 8 + i*8
+		if off+8 > len(data) {
+			return 0
+		}
+		return table.order.Uint64(data[off:])
+	}
+	slice := func(from uint64) []byte {
+		if from > uint64(len(data)) {
+			return nil
+		}
+		return data[from:]
+	}
+
+	table.nfunc = int(word(0))
+	table.funcnametab = slice(word(3))
+	table.cutab = slice(word(4))
+	table.filetab = slice(word(5))
+	table.pctab = slice(word(6))
+	table.functab = slice(word(7))
+	if table.nfunc <= 0 || table.nfunc > ncode || len(table.functab) < (table.nfunc+1)*8 {
+		return nil, errors.New("pclntab functab truncated")
+	}
+	return table, nil
+}
+
+// goFunc is a Go function in the pclntab.
+type goFunc struct {
+	table *pclntab
+	name  string
+	// code is the index in the code section.
+	code     int
+	cuOffset uint32
+	pcfile   uint32
+	pcln     uint32
+}
+
+// funcs returns the functions by their index in the code section.
+func (table *pclntab) funcs() map[int]*goFunc {
+	funcs := make(map[int]*goFunc, table.nfunc)
+	for i := 0; i < table.nfunc; i++ {
+		pcf := table.order.Uint32(table.functab[i*8:])
+		funcOff := table.order.Uint32(table.functab[i*8+4:])
+		if pcf < funcValueOffset || uint64(funcOff) >= uint64(len(table.functab)) {
+			continue
+		}
+
+		header := 44
+		if table.go118 {
+			header = 40
+		}
+		fn := table.functab[funcOff:]
+		if len(fn) < header {
+			continue
+		}
+		code := int(pcf - funcValueOffset)
+		funcs[code] = &goFunc{
+			table:    table,
+			name:     table.funcName(table.order.Uint32(fn[4:])),
+			code:     code,
+			pcfile:   table.order.Uint32(fn[20:]),
+			pcln:     table.order.Uint32(fn[24:]),
+			cuOffset: table.order.Uint32(fn[32:]),
+		}
+	}
+	return funcs
+}
+
+// funcName reads a name from the function name table.
+func (table *pclntab) funcName(off uint32) string {
+	return cstring(table.funcnametab, off)
+}
+
+// fileName returns the name of the file with the index in the compilation
+// unit of the function.
+func (fn *goFunc) fileName(index int32) string {
+	table := fn.table
+	at := (uint64(fn.cuOffset) + uint64(index)) * 4
+	if index < 0 || at+4 > uint64(len(table.cutab)) {
+		return ""
+	}
+	off := table.order.Uint32(table.cutab[at:])
+	if off == ^uint32(0) {
+		return ""
+	}
+	return cstring(table.filetab, off)
+}
+
+// goLine is the source position of a resume point.
+type goLine struct {
+	file string
+	line int
+}
+
+// lines returns the source position of each PC_B of the function.
+func (fn *goFunc) lines() []goLine {
+	var lines []goLine
+	fn.table.runs(fn.pcln, func(start, end uint64, line int32) {
+		for pc := start; pc < end && pc < 1<<16; pc++ {
+			lines = append(lines, goLine{line: int(line)})
+		}
+	})
+	fn.table.runs(fn.pcfile, func(start, end uint64, index int32) {
+		name := fn.fileName(index)
+		for pc := start; pc < end && pc < uint64(len(lines)); pc++ {
+			lines[pc].file = name
+		}
+	})
+	return lines
+}
+
+// runs calls f for each range [start, end) of PC_B values of the pc-value
+// table at off.
+func (table *pclntab) runs(off uint32, f func(start, end uint64, value int32)) {
+	if off == 0 || uint64(off) >= uint64(len(table.pctab)) {
+		return
+	}
+	p := table.pctab[off:]
+	value, at := int32(-1), uint64(0)
+	first := true
+	for {
+		uvdelta, n := binary.Uvarint(p)
+		if n <= 0 || (uvdelta == 0 && !first) {
+			return
+		}
+		p = p[n:]
+		first = false
+
+		if uvdelta&1 != 0 {
+			uvdelta = ^(uvdelta >> 1)
+		} else {
+			uvdelta >>= 1
+		}
+		value += int32(uvdelta)
+
+		pcdelta, n := binary.Uvarint(p)
+		if n <= 0 {
+			return
+		}
+		p = p[n:]
+		start := at
+		at += pcdelta * table.minLC
+		f(start, at, value)
+	}
+}
+
+// cstring reads a NUL terminated string at off.
+func cstring(data []byte, off uint32) string {
+	if uint64(off) >= uint64(len(data)) {
+		return ""
+	}
+	s := data[off:]
+	if end := bytes.IndexByte(s, 0); end >= 0 {
+		s = s[:end]
+	}
+	return string(s)
+}
+
+// positions returns the source position of each instruction of the
+// function, the line is 0 when it's unknown.
+func (fn *goFunc) positions(insts []instruction) []goLine {
+	lines := fn.lines()
+	pcs := resumePoints(insts, funcValueOffset+uint32(fn.code))
+	positions := make([]goLine, len(insts))
+	for i, pc := range pcs {
+		if pc < len(lines) {
+			positions[i] = lines[pc]
+		}
+	}
+	return positions
+}
+
+// isDispatch reports whether the br_table can select the resume points,
+// undecodable bytes keep their opcode but have no labels and there can't be
+// more resume points than PC_Bs in a well-formed module.
+func isDispatch(inst instruction) bool {
+	if inst.invalid() || len(inst.labels) == 0 {
+		return false
+	}
+	last := inst.labels[len(inst.labels)-1]
+	return uint64(last) < uint64(len(inst.labels))
+}
+
+// resumePoints returns the PC_B of each instruction of the Go function
+// with the PC_F pcf.
+//
+// The function starts with a block for each resume point and a br_table on
+// PC_B, which jumps to the end of the block of the selected resume point,
+// and the code of the resume point follows that end. Within a resume point
+// PC_B advances without a trace in the code, except before calls, which
+// store the return address. The rest is attributed to the last known PC_B.
+func resumePoints(insts []instruction, pcf uint32) []int {
+	pcs := make([]int, len(insts))
+
+	// The prologue only sets up the blocks and reads PC_B, which is the
+	// first parameter.
+	dispatch := -1
+prologue:
+	for i, inst := range insts {
+		switch inst.opcode {
+		case wasm.OpcodeGlobalGet, wasm.OpcodeLocalSet, wasm.OpcodeLocalGet,
+			wasm.OpcodeBlock, wasm.OpcodeLoop:
+		case wasm.OpcodeBrTable:
+			if i > 0 && insts[i-1].opcode == wasm.OpcodeLocalGet && insts[i-1].immediate == 0 && isDispatch(inst) {
+				dispatch = i
+			}
+			break prologue
+		default:
+			break prologue
+		}
+	}
+
+	// starts[r] is the first PC_B of resume point r, the labels of the
+	// br_table are the resume points of each PC_B and the default label
+	// the last one.
+	var starts []int
+	if dispatch >= 0 {
+		labels := insts[dispatch].labels
+		table, last := labels[:len(labels)-1], labels[len(labels)-1]
+		starts = make([]int, last+1)
+		for r := range starts {
+			starts[r] = -1
+		}
+		for pc, r := range table {
+			if r <= last && starts[r] < 0 {
+				starts[r] = pc
+			}
+		}
+		if starts[last] < 0 {
+			starts[last] = len(table)
+		}
+	}
+
+	// block is an open block, resume is the resume point that starts at
+	// its end, or -1.
+	type block struct {
+		opcode wasm.Opcode
+		resume int
+	}
+	var blocks []block
+	pc := 0
+	for i, inst := range insts {
+		switch inst.opcode {
+		case wasm.OpcodeBlock, wasm.OpcodeLoop, wasm.OpcodeIf:
+			blocks = append(blocks, block{opcode: inst.opcode, resume: -1})
+		case wasm.OpcodeBrTable:
+			if i == dispatch {
+				// The innermost block ends at the first resume point.
+				r := 0
+				for k := len(blocks) - 1; k >= 0 && blocks[k].opcode == wasm.OpcodeBlock && r < len(starts); k-- {
+					blocks[k].resume = r
+					r++
+				}
+			}
+		case wasm.OpcodeEnd:
+			if len(blocks) == 0 {
+				break
+			}
+			r := blocks[len(blocks)-1].resume
+			blocks = blocks[:len(blocks)-1]
+			if r >= 0 && starts[r] >= 0 {
+				pc = starts[r]
+			}
+		case wasm.OpcodeI64Const:
+			// The return address of a call, the call belongs to the PC_B
+			// before the resume point.
+			if uint64(inst.immediate)>>16 == uint64(pcf) && i+1 < len(insts) && insts[i+1].opcode == wasm.OpcodeI64Store {
+				pc = max(int(inst.immediate&0xffff)-1, 0)
+			}
+		}
+		pcs[i] = pc
+	}
+	return pcs
+}
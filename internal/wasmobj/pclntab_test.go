@@ -0,0 +1,161 @@
+package wasmobj
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/tetratelabs/wabin/wasm"
+
+	"loov.dev/lensm/internal/disasm"
+)
+
+func TestLoad_GoPclntab(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a test module")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "main.go")
+	err := os.WriteFile(src, []byte(`package main
+
+func main() { println(add(1, 2)) }
+
+//go:noinline
+func add(a, b int) int {
+	if a > b {
+		return a - b
+	}
+	println("x")
+	return a + b
+}
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ldflags := range []string{"", "-s -w"} {
+		bin := filepath.Join(dir, "main.wasm")
+		cmd := exec.Command("go", "build", "-ldflags="+ldflags, "-o", bin, src)
+		cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("go build: %v\n%s", err, out)
+		}
+
+		file, err := Load(bin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var add, main disasm.Func
+		for _, fn := range file.Funcs() {
+			switch fn.Name() {
+			case "main.add":
+				add = fn
+			case "main.main":
+				main = fn
+			}
+		}
+		if add == nil || main == nil {
+			t.Fatalf("ldflags %q: main.add or main.main not found", ldflags)
+		}
+
+		code, err := add.Load(disasm.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code.File != src {
+			t.Errorf("ldflags %q: code.File = %q, want %q", ldflags, code.File, src)
+		}
+		var lines []int
+		for _, inst := range code.Insts {
+			if inst.Line > 0 && !slices.Contains(lines, inst.Line) {
+				lines = append(lines, inst.Line)
+			}
+		}
+		// The lines within a resume point can't be told apart, the
+		// comparison shares the resume point with the prologue.
+		if want := []int{6, 8, 10, 11}; !slices.Equal(lines, want) {
+			t.Errorf("ldflags %q: lines = %v, want %v", ldflags, lines, want)
+		}
+
+		code, err = main.Load(disasm.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		calls := false
+		for _, inst := range code.Insts {
+			if inst.Call == "main.add" {
+				calls = inst.Line == 3
+			}
+		}
+		if !calls {
+			t.Errorf("ldflags %q: call to main.add at line 3 not found", ldflags)
+		}
+	}
+}
+
+func TestResumePoints(t *testing.T) {
+	const pcf = 0x1001
+	// A function with two calls, the last resume point is only the
+	// default label of the br_table.
+	insts := []instruction{
+		{opcode: wasm.OpcodeBlock},
+		{opcode: wasm.OpcodeLoop},
+		{opcode: wasm.OpcodeBlock},
+		{opcode: wasm.OpcodeBlock},
+		{opcode: wasm.OpcodeBlock},
+		{opcode: wasm.OpcodeLocalGet, immediate: 0},
+		{opcode: wasm.OpcodeBrTable, name: "br_table", labels: []uint32{0, 0, 1, 2}},
+		{opcode: wasm.OpcodeEnd}, // resume point 0
+		{opcode: wasm.OpcodeI64Const, immediate: pcf<<16 + 2},
+		{opcode: wasm.OpcodeI64Store},
+		{opcode: wasm.OpcodeCall, hasCallee: true},
+		{opcode: wasm.OpcodeEnd}, // resume point 1
+		{opcode: wasm.OpcodeI64Const, immediate: pcf<<16 + 3},
+		{opcode: wasm.OpcodeI64Store},
+		{opcode: wasm.OpcodeCall, hasCallee: true},
+		{opcode: wasm.OpcodeEnd}, // resume point 2
+		{opcode: wasm.OpcodeReturn},
+		{opcode: wasm.OpcodeEnd},
+		{opcode: wasm.OpcodeEnd},
+	}
+	want := []int{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 2, 2, 2, 2, 3, 3, 3, 3}
+	if got := resumePoints(insts, pcf); !slices.Equal(got, want) {
+		t.Errorf("resumePoints = %v, want %v", got, want)
+	}
+}
+
+func TestResumePointsMalformed(t *testing.T) {
+	tests := map[string][]instruction{
+		"undecodable br_table": {
+			{opcode: wasm.OpcodeLocalGet, name: "local.get", immediate: 0},
+			{opcode: wasm.OpcodeBrTable},
+			{opcode: wasm.OpcodeEnd, name: "end"},
+		},
+		"default label out of range": {
+			{opcode: wasm.OpcodeLocalGet, name: "local.get", immediate: 0},
+			{opcode: wasm.OpcodeBrTable, name: "br_table", labels: []uint32{0, 1 << 31}},
+			{opcode: wasm.OpcodeEnd, name: "end"},
+		},
+	}
+	for name, insts := range tests {
+		if got := resumePoints(insts, 0x1001); !slices.Equal(got, []int{0, 0, 0}) {
+			t.Errorf("%s: resumePoints = %v", name, got)
+		}
+	}
+}
+
+func FuzzResumePoints(f *testing.F) {
+	f.Add([]byte{0x20, 0x00, 0x0e})
+	f.Add([]byte{0x02, 0x40, 0x20, 0x00, 0x0e, 0x02, 0x00, 0x00, 0x01, 0x0b, 0x0b})
+	f.Add([]byte{0x20, 0x00, 0x0e, 0x01, 0x00, 0xff, 0xff, 0xff, 0xff, 0x0f})
+	names := func(wasm.Index) string { return "" }
+	f.Fuzz(func(t *testing.T, body []byte) {
+		insts := decodeBody(body, names)
+		if got := resumePoints(insts, 0x1001); len(got) != len(insts) {
+			t.Fatalf("got %d PC_Bs for %d instructions", len(got), len(insts))
+		}
+	})
+}
@@ -6,8 +6,10 @@ import (
 	"time"
 
 	"loov.dev/lensm/internal/disasm"
-	"loov.dev/lensm/internal/goobj"
-	"loov.dev/lensm/internal/wasmobj"
+
+	// Supported file formats.
+	_ "loov.dev/lensm/internal/goobj"
+	_ "loov.dev/lensm/internal/wasmobj"
 )
 
 type fileLoadRequest struct {
@@ -21,11 +23,31 @@ type fileLoadResult struct {
 	err        error
 }
 
-func loadDisasmFile(path string) (disasm.File, error) {
-	if workInProgressWASM {
-		return wasmobj.Load(path)
+// fileStamp identifies a version of the loaded file, watch mode reloads
+// it when the stamp changes.
+type fileStamp struct {
+	modTime time.Time
+	// files is a hash of the names of the sources that a build reads, so
+	// that removing or renaming one is noticed.
+	files uint64
+}
+
+func (stamp fileStamp) equal(other fileStamp) bool {
+	return stamp.modTime.Equal(other.modTime) && stamp.files == other.files
+}
+
+// statFile returns the stamp of the file at path.
+func statFile(path string) (fileStamp, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
 	}
-	return goobj.Load(path)
+	return fileStamp{modTime: stat.ModTime()}, nil
+}
+
+// loadDisasmFile opens path with the format registered for its magic bytes.
+func loadDisasmFile(path string) (disasm.File, error) {
+	return disasm.Open(path)
 }
 
 // loader loads disassembly files on its own goroutine and, when watch
@@ -33,15 +55,19 @@ func loadDisasmFile(path string) (disasm.File, error) {
 // a binary is not reloaded mid-write.
 type loader struct {
 	loadFile func(string) (disasm.File, error)
+	stamp    func(string) (fileStamp, error)
 	watch    bool
 	requests chan fileLoadRequest
 	results  chan fileLoadResult
 	stop     chan struct{}
 }
 
-func newLoader(loadFile func(string) (disasm.File, error), watch bool) *loader {
+// newLoader starts a loader, stamp reports when the file at path needs to
+// be reloaded in watch mode.
+func newLoader(loadFile func(string) (disasm.File, error), stamp func(string) (fileStamp, error), watch bool) *loader {
 	l := &loader{
 		loadFile: loadFile,
+		stamp:    stamp,
 		watch:    watch,
 		requests: make(chan fileLoadRequest, 1),
 		results:  make(chan fileLoadResult, 1),
@@ -79,8 +105,8 @@ func (l *loader) finish(result fileLoadResult) {
 }
 
 func (l *loader) run() {
-	var lastModTime time.Time
-	var pendingModTime time.Time
+	var lastStamp fileStamp
+	var pendingStamp fileStamp
 	var pendingSince time.Time
 	var path string
 	var generation uint64
@@ -92,17 +118,17 @@ func (l *loader) run() {
 			return
 		}
 
-		stat, err := os.Stat(path)
+		stamp, err := l.stamp(path)
 		if err != nil {
 			l.finish(fileLoadResult{generation: generation, err: err})
 			return
 		}
-		if !force && stat.ModTime().Equal(lastModTime) {
+		if !force && stamp.equal(lastStamp) {
 			return
 		}
 		if !force {
-			if !stat.ModTime().Equal(pendingModTime) {
-				pendingModTime = stat.ModTime()
+			if !stamp.equal(pendingStamp) {
+				pendingStamp = stamp
 				pendingSince = now
 				return
 			}
@@ -110,10 +136,14 @@ func (l *loader) run() {
 				return
 			}
 		}
-		lastModTime = stat.ModTime()
-		pendingModTime = time.Time{}
+		lastStamp = stamp
+		pendingStamp = fileStamp{}
 
 		file, err := l.loadFile(path)
+		// The callers are usually wanted soon after opening.
+		if index, ok := file.(disasm.CallIndex); ok && err == nil {
+			index.IndexCalls()
+		}
 		l.finish(fileLoadResult{generation: generation, file: file, err: err})
 	}
 
@@ -122,8 +152,8 @@ func (l *loader) run() {
 		case req := <-l.requests:
 			path = strings.TrimSpace(req.path)
 			generation = req.generation
-			lastModTime = time.Time{}
-			pendingModTime = time.Time{}
+			lastStamp = fileStamp{}
+			pendingStamp = fileStamp{}
 			load(true, time.Now())
 		case now := <-tick.C:
 			if l.watch {
@@ -6,6 +6,7 @@ import (
 	"image"
 	"log"
 	"os"
+	"regexp"
 	"runtime/pprof"
 
 	"gioui.org/app"
@@ -13,16 +14,66 @@ import (
 	"gioui.org/unit"
 	"gioui.org/widget/material"
 
+	"loov.dev/lensm/internal/disasm"
 	"loov.dev/lensm/internal/gui"
 	"loov.dev/lensm/internal/mcp"
+	"loov.dev/lensm/internal/profile"
 )
 
 func main() {
 	if len(os.Args) > 1 && os.Args[1] == "mcp" {
-		workInProgressWASM = os.Getenv("LENSM_EXPERIMENT_WASM") != ""
 		os.Exit(mcp.RunCommand(loadDisasmFile, os.Args[2:]))
 	}
 
+	// `lensm diff old new` compares two builds, with -gui in a window.
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		diff, code := parseDiffCommand(os.Args[2:])
+		if diff == nil {
+			os.Exit(code)
+		}
+		if !diff.GUI {
+			os.Exit(runDiff(diff, os.Stdout))
+		}
+		if err := diff.open(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			_ = diff.Close()
+			os.Exit(1)
+		}
+
+		windows := &gui.Windows{}
+		theme := material.NewTheme()
+		theme.Shaper = text.NewShaper(text.WithCollection(gui.LoadFonts("")))
+		windows.Open("lensm diff", image.Pt(1400, 900), NewDiffUI(theme, diff).Run)
+		go func() {
+			windows.Wait()
+			os.Exit(0)
+		}()
+		app.Main()
+	}
+
+	// `lensm size exe` reports where the bytes go, `lensm size old new`
+	// compares two builds.
+	if len(os.Args) > 1 && os.Args[1] == "size" {
+		size, code := parseSizeCommand(os.Args[2:])
+		if size == nil {
+			os.Exit(code)
+		}
+		os.Exit(runSize(size, os.Stdout))
+	}
+
+	// `lensm build [flags] packages` compiles the packages and opens
+	// the result, `lensm test` does the same for the test binaries.
+	// The GUI flags are accepted as well.
+	var buildConfig *BuildConfig
+	if len(os.Args) > 1 && (os.Args[1] == "build" || os.Args[1] == "test") {
+		buildConfig = &BuildConfig{Test: os.Args[1] == "test"}
+		buildConfig.registerFlags(flag.CommandLine)
+		if buildConfig.Test {
+			flag.StringVar(&buildConfig.Run, "run", "", "show only tests and benchmarks matching regexp")
+		}
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+
 	cpuprofile := flag.String("cpuprofile", "", "enable cpu profiling")
 	defaults := DefaultAppSettings()
 	textSize := flag.Int("text-size", defaults.TextSize, "default font size")
@@ -31,8 +82,14 @@ func main() {
 	context := flag.Int("context", 3, "source line context")
 	comments := flag.String("comments", "", "comments sidecar path")
 	font := flag.String("font", "", "user font")
-
-	workInProgressWASM = os.Getenv("LENSM_EXPERIMENT_WASM") != ""
+	arch := flag.String("arch", "", "architecture of an universal binary")
+	profilePath := flag.String("profile", "", "overlay a pprof CPU profile")
+	var sourceRules []disasm.PathRule
+	flag.Func("source-map", "rewrite source path prefix `from=to`, can be repeated", func(s string) error {
+		rule, err := disasm.ParsePathRule(s)
+		sourceRules = append(sourceRules, rule)
+		return err
+	})
 
 	flag.Parse()
 	exePath := flag.Arg(0)
@@ -44,7 +101,31 @@ func main() {
 		}
 	})
 
-	if flag.NArg() > 1 {
+	var build *builder
+	if buildConfig != nil {
+		if flag.NArg() == 0 {
+			if buildConfig.Test {
+				fmt.Fprintln(os.Stderr, "lensm test [-run regexp] [flags] packages")
+			} else {
+				fmt.Fprintln(os.Stderr, "lensm build [flags] packages")
+			}
+			flag.Usage()
+			os.Exit(2)
+		}
+		if _, err := regexp.Compile(buildConfig.Run); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -run: %v\n", err)
+			os.Exit(2)
+		}
+		buildConfig.Packages = flag.Args()
+
+		var err error
+		build, err = newBuilder(*buildConfig)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		exePath = build.Dir
+	} else if flag.NArg() > 1 {
 		fmt.Fprintln(os.Stderr, "lensm [exePath]")
 		flag.Usage()
 		os.Exit(2)
@@ -68,13 +149,24 @@ func main() {
 		Watch:        *watch,
 		Context:      *context,
 		CommentsPath: *comments,
+		SourceRules:  sourceRules,
+		Build:        build,
+		Arch:         *arch,
 	}
 	ui.Funcs.SetFilter(*filter)
+	if *profilePath != "" {
+		p, err := profile.Load(*profilePath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		ui.setProfile(*profilePath, p)
+	}
 
 	windows.Open("lensm", image.Pt(1400, 900), ui.Run)
 
 	go func() {
-		profile(*cpuprofile, windows.Wait)
+		withCPUProfile(*cpuprofile, windows.Wait)
 		os.Exit(0)
 	}()
 
@@ -82,7 +174,7 @@ func main() {
 	app.Main()
 }
 
-func profile(cpuprofile string, fn func()) {
+func withCPUProfile(cpuprofile string, fn func()) {
 	if cpuprofile != "" {
 		f, err := os.Create(cpuprofile)
 		if err != nil {
@@ -23,6 +23,8 @@ type AppSettings struct {
 	LastPath      string   `json:"last_path,omitempty"`
 	OpenTabs      []string `json:"open_tabs,omitempty"`
 	ActiveTab     string   `json:"active_tab,omitempty"`
+	// SourceMaps contains the source path rules, "from=to", per binary.
+	SourceMaps map[string][]string `json:"source_maps,omitempty"`
 }
 
 func DefaultAppSettings() AppSettings {
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"loov.dev/lensm/internal/binsize"
+	"loov.dev/lensm/internal/disasm"
+)
+
+// sizeCommand reports where the bytes of an executable go, see
+// `lensm size -h`.
+type sizeCommand struct {
+	// Paths is the executable, or the old and the new build to compare.
+	Paths []string
+	By    binsize.By
+	Sort  binsize.Key
+	// Limit is the number of the listed entries, zero lists all.
+	Limit int
+	JSON  bool
+	Arch  string
+}
+
+// parseSizeCommand parses the arguments of `lensm size`, it returns the exit
+// code when they are invalid.
+func parseSizeCommand(args []string) (*sizeCommand, int) {
+	cmd := &sizeCommand{}
+	fs := flag.NewFlagSet("lensm size", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	by := fs.String("by", "package", "group by package, symbol or generic")
+	sort := fs.String("sort", "total", "sort by total, text, rodata, data, pclntab or name")
+	fs.IntVar(&cmd.Limit, "n", 30, "number of the listed entries, 0 lists all")
+	fs.BoolVar(&cmd.JSON, "json", false, "print JSON")
+	fs.StringVar(&cmd.Arch, "arch", "", "architecture of an universal binary")
+	if err := fs.Parse(args); err != nil {
+		return nil, 2
+	}
+	var err error
+	if cmd.By, err = binsize.ParseBy(*by); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, 2
+	}
+	if cmd.Sort, err = binsize.ParseKey(*sort); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, 2
+	}
+	if fs.NArg() != 1 && fs.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: lensm size [-by package|symbol|generic] [-sort column] [-n count] [-json] [-arch goarch] <exe> [<new exe>]")
+		return nil, 2
+	}
+	cmd.Paths = fs.Args()
+	return cmd, 0
+}
+
+// runSize prints the report, or compares two builds, it returns the exit
+// code.
+func runSize(cmd *sizeCommand, w io.Writer) int {
+	var reports []*binsize.Report
+	for _, path := range cmd.Paths {
+		report, err := loadSizeReport(path, cmd.Arch)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		reports = append(reports, report)
+	}
+
+	var err error
+	if len(reports) == 2 {
+		err = printSizeCompare(w, cmd, reports[0], reports[1])
+	} else {
+		err = printSizeReport(w, cmd, reports[0])
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// loadSizeReport sums the sizes of the symbols in the executable at path.
+func loadSizeReport(path, arch string) (*binsize.Report, error) {
+	file, err := loadDisasmFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	if err := disasm.SelectArch(file, arch); err != nil {
+		return nil, err
+	}
+	sizes, err := disasm.SymbolSizes(file)
+	if err != nil {
+		return nil, err
+	}
+	return binsize.New(sizes), nil
+}
+
+// limit returns the first n items, all of them when n is zero.
+func limit[T any](items []T, n int) []T {
+	if n > 0 && n < len(items) {
+		return items[:n]
+	}
+	return items
+}
+
+// printSizeReport prints the totals and the largest entries.
+func printSizeReport(w io.Writer, cmd *sizeCommand, report *binsize.Report) error {
+	entries := report.Entries(cmd.By)
+	binsize.Sort(entries, cmd.Sort)
+	entries = limit(entries, cmd.Limit)
+	if cmd.JSON {
+		return writeJSON(w, struct {
+			Total   binsize.Entry    `json:"total"`
+			By      string           `json:"by"`
+			Entries []*binsize.Entry `json:"entries"`
+		}{report.Total, cmd.By.String(), entries})
+	}
+
+	total := &report.Total
+	fmt.Fprintf(w, "%s in %d symbols: text %s, rodata %s, data %s, pclntab %s\n\n",
+		humanBytes(total.Size(binsize.Total)), total.Count, humanBytes(total.Text),
+		humanBytes(total.ROData), humanBytes(total.Data), humanBytes(total.PCLN))
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "TOTAL\t%%\tTEXT\tRODATA\tDATA\tPCLNTAB\tCOUNT\t\t%s\n", strings.ToUpper(cmd.By.String()))
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t\t%s\n",
+			humanBytes(e.Size(binsize.Total)), formatPercent(e.Size(binsize.Total), total.Size(binsize.Total)),
+			humanBytes(e.Text), humanBytes(e.ROData), humanBytes(e.Data), humanBytes(e.PCLN), e.Count, e.Name)
+	}
+	return tw.Flush()
+}
+
+// printSizeCompare prints the change of the totals and the entries that
+// changed the most.
+func printSizeCompare(w io.Writer, cmd *sizeCommand, before, after *binsize.Report) error {
+	deltas := binsize.Compare(before.Entries(cmd.By), after.Entries(cmd.By))
+	binsize.SortDeltas(deltas, cmd.Sort)
+	deltas = limit(deltas, cmd.Limit)
+	if cmd.JSON {
+		type deltaJSON struct {
+			Name   string        `json:"name"`
+			Old    binsize.Entry `json:"old"`
+			New    binsize.Entry `json:"new"`
+			Change int64         `json:"change"`
+		}
+		changes := make([]deltaJSON, len(deltas))
+		for i, d := range deltas {
+			changes[i] = deltaJSON{d.Name, d.Old, d.New, d.Change(binsize.Total)}
+		}
+		return writeJSON(w, struct {
+			Old     binsize.Entry `json:"old"`
+			New     binsize.Entry `json:"new"`
+			By      string        `json:"by"`
+			Entries []deltaJSON   `json:"entries"`
+		}{before.Total, after.Total, cmd.By.String(), changes})
+	}
+
+	total := &binsize.Delta{Old: before.Total, New: after.Total}
+	fmt.Fprintf(w, "%s → %s (%s): text %s, rodata %s, data %s, pclntab %s\n\n",
+		humanBytes(before.Total.Size(binsize.Total)), humanBytes(after.Total.Size(binsize.Total)),
+		humanChange(total.Change(binsize.Total)), humanChange(total.Change(binsize.Text)),
+		humanChange(total.Change(binsize.ROData)), humanChange(total.Change(binsize.Data)),
+		humanChange(total.Change(binsize.PCLN)))
+	if len(deltas) == 0 {
+		fmt.Fprintln(w, "no changes")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "CHANGE\tOLD\tNEW\tTEXT\tRODATA\tDATA\tPCLNTAB\t\t%s\n", strings.ToUpper(cmd.By.String()))
+	for _, d := range deltas {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\t%s\n",
+			humanChange(d.Change(binsize.Total)), formatEntrySize(&d.Old), formatEntrySize(&d.New),
+			humanChange(d.Change(binsize.Text)), humanChange(d.Change(binsize.ROData)),
+			humanChange(d.Change(binsize.Data)), humanChange(d.Change(binsize.PCLN)), d.Name)
+	}
+	return tw.Flush()
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// formatEntrySize formats the total size, "-" when the entry is missing.
+func formatEntrySize(e *binsize.Entry) string {
+	if e.Count == 0 {
+		return "-"
+	}
+	return humanBytes(e.Size(binsize.Total))
+}
+
+// humanBytes formats a size with a binary unit, e.g. "1.5 MB".
+func humanBytes(size uint64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
+
+// humanChange formats a change in size, e.g. "+400.0 KB".
+func humanChange(change int64) string {
+	switch {
+	case change > 0:
+		return "+" + humanBytes(uint64(change))
+	case change < 0:
+		return "-" + humanBytes(uint64(-change))
+	default:
+		return "0"
+	}
+}
+
+// formatPercent formats the share of size in total.
+func formatPercent(size, total uint64) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", float64(size)*100/float64(total))
+}